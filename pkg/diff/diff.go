@@ -4,6 +4,7 @@ import "github.com/pkg/errors"
 
 type Kind string
 
+// Short panics on a Kind other than the constants below, since diffs are only ever built with those
 func (d Kind) Short() string {
 	switch d {
 	case KindAdd:
@@ -16,13 +16,15 @@ func (d *JsonDiff) Add(e *Node) {
 	d.Changes = append(d.Changes, e)
 }
 
-func CompareJson(a interface{}, b interface{}) *JsonDiff {
+func CompareJson(a interface{}, b interface{}) (*JsonDiff, error) {
 	diffs := &JsonDiff{}
-	CompareJsonHelper(a, b, []string{}, diffs)
-	return diffs
+	if err := CompareJsonHelper(a, b, []string{}, diffs); err != nil {
+		return nil, err
+	}
+	return diffs, nil
 }
 
-func CompareJsonHelper(a interface{}, b interface{}, pathContext []string, diffs *JsonDiff) {
+func CompareJsonHelper(a interface{}, b interface{}, pathContext []string, diffs *JsonDiff) error {
 	// make a copy to avoid aliasing
 	//path := CopySlice(pathContext)
 	//path := append([]string{}, pathContext...) // TODO this doesn't seem to make a deep copy?
@@ -43,7 +45,9 @@ func CompareJsonHelper(a interface{}, b interface{}, pathContext []string, diffs
 				aKeys := maps.Keys(aVal)
 				sort.Strings(aKeys)
 				for _, k := range aKeys {
-					CompareJsonHelper(aVal[k], bVal[k], append(path, fmt.Sprintf(`%s`, k)), diffs)
+					if err := CompareJsonHelper(aVal[k], bVal[k], append(path, fmt.Sprintf(`%s`, k)), diffs); err != nil {
+						return err
+					}
 				}
 				bKeys := maps.Keys(bVal)
 				sort.Strings(bKeys)
@@ -69,7 +73,9 @@ func CompareJsonHelper(a interface{}, b interface{}, pathContext []string, diffs
 					} else if i >= len(bVal) {
 						diffs.Add(&Node{Kind: KindRemove, Old: aSub, Path: newPath})
 					} else {
-						CompareJsonHelper(aSub, bVal[i], newPath, diffs)
+						if err := CompareJsonHelper(aSub, bVal[i], newPath, diffs); err != nil {
+							return err
+						}
 					}
 				}
 			default:
@@ -84,6 +90,15 @@ func CompareJsonHelper(a interface{}, b interface{}, pathContext []string, diffs
 			default:
 				diffs.Add(&Node{Kind: KindChange, Old: aVal, New: bVal, Path: path})
 			}
+		case float64:
+			switch bVal := b.(type) {
+			case float64:
+				if aVal != bVal {
+					diffs.Add(&Node{Kind: KindChange, Old: aVal, New: bVal, Path: path})
+				}
+			default:
+				diffs.Add(&Node{Kind: KindChange, Old: aVal, New: bVal, Path: path})
+			}
 		case string:
 			switch bVal := b.(type) {
 			case string:
@@ -104,7 +119,8 @@ func CompareJsonHelper(a interface{}, b interface{}, pathContext []string, diffs
 			}
 		//case types.Nil: // TODO is this necessary?
 		default:
-			panic(errors.Errorf("unrecognized type: %s, %T, %+v", path, aVal, aVal))
+			return errors.Errorf("unrecognized type: %s, %T, %+v", path, aVal, aVal)
 		}
 	}
+	return nil
 }
@@ -0,0 +1,139 @@
+package schema
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mattfenwick/collections/pkg/set"
+	"github.com/mattfenwick/collections/pkg/slice"
+	"github.com/mattfenwick/kubectl-schema/pkg/diff"
+	"github.com/mattfenwick/kubectl-schema/pkg/swagger"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/maps"
+)
+
+// ErrNotFound is returned, wrapped, when a catalog has no definition for a GVK or name
+var ErrNotFound = errors.New("not found")
+
+// Catalog answers questions about a single spec.  Definitions are resolved the first time they're
+// needed, and then cached; a Catalog is safe for concurrent use.
+type Catalog struct {
+	KubeVersion swagger.KubeVersion
+	Spec        *swagger.KubeSpec
+
+	lock        sync.Mutex
+	definitions map[string]*swagger.ResolvedType
+}
+
+func NewCatalog(kubeVersion swagger.KubeVersion, spec *swagger.KubeSpec) *Catalog {
+	return &Catalog{KubeVersion: kubeVersion, Spec: spec}
+}
+
+// GVKs lists each GVK in the spec once, sorted by group, version and kind
+func (c *Catalog) GVKs() []*swagger.GVK {
+	seen := set.NewSet[string](nil)
+	var gvks []*swagger.GVK
+	for _, name := range slice.Sort(maps.Keys(c.Spec.Definitions)) {
+		for _, gvk := range c.Spec.Definitions[name].XKubernetesGroupVersionKind {
+			if !seen.Contains(gvk.ToString()) {
+				seen.Add(gvk.ToString())
+				gvks = append(gvks, gvk)
+			}
+		}
+	}
+	return slice.SortOnBy(func(g *swagger.GVK) []string { return []string{g.Group, g.Version, g.Kind} }, slice.ComparePairwise[string](), gvks)
+}
+
+// DefinitionName finds the definition for a GVK.  If several definitions claim the same GVK, the
+// first one by name wins.
+func (c *Catalog) DefinitionName(gvk *swagger.GVK) (string, error) {
+	for _, name := range slice.Sort(maps.Keys(c.Spec.Definitions)) {
+		for _, candidate := range c.Spec.Definitions[name].XKubernetesGroupVersionKind {
+			if *candidate == *gvk {
+				return name, nil
+			}
+		}
+	}
+	return "", errors.Wrapf(ErrNotFound, "gvk %s in kube version %s", gvk.ToString(), c.KubeVersion.ToString())
+}
+
+// Resolve resolves the definition for a GVK
+func (c *Catalog) Resolve(ctx context.Context, gvk *swagger.GVK) (*swagger.ResolvedType, error) {
+	name, err := c.DefinitionName(gvk)
+	if err != nil {
+		return nil, err
+	}
+	return c.ResolveDefinition(ctx, name)
+}
+
+// ResolveDefinition resolves a definition by name.  This is how to follow up on `Circular` markers.
+func (c *Catalog) ResolveDefinition(ctx context.Context, name string) (*swagger.ResolvedType, error) {
+	definitions, err := c.resolveDefinitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resolved, ok := definitions[name]
+	if !ok {
+		return nil, errors.Wrapf(ErrNotFound, "definition %s in kube version %s", name, c.KubeVersion.ToString())
+	}
+	return resolved, nil
+}
+
+func (c *Catalog) resolveDefinitions(ctx context.Context) (map[string]*swagger.ResolvedType, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.definitions == nil {
+		definitions, err := c.Spec.ResolveDefinitions()
+		if err != nil {
+			return nil, err
+		}
+		c.definitions = definitions
+	}
+	return c.definitions, nil
+}
+
+// Walk calls visit for a kind and then each of its fields, depth first, with fields sorted by name.
+// Array items show up as "[]" in paths.  Walking stops at the first error from visit, or when ctx
+// is done.
+func (c *Catalog) Walk(ctx context.Context, gvk *swagger.GVK, visit func(path []string, resolved *swagger.ResolvedType) error) error {
+	resolved, err := c.Resolve(ctx, gvk)
+	if err != nil {
+		return err
+	}
+	for _, pair := range resolved.Paths([]string{}) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := visit(pair.Fst, pair.Snd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Comparison is the difference between two kinds: every change, and the subset of changes which
+// could make objects valid under the old schema invalid under the new one
+type Comparison struct {
+	Changes         []*diff.Node
+	BreakingChanges []*swagger.BreakingChange
+}
+
+// Compare compares a kind from one catalog to a kind from another; the catalogs and kinds may be
+// the same, or different
+func Compare(ctx context.Context, oldCatalog *Catalog, oldGVK *swagger.GVK, newCatalog *Catalog, newGVK *swagger.GVK) (*Comparison, error) {
+	oldType, err := oldCatalog.Resolve(ctx, oldGVK)
+	if err != nil {
+		return nil, err
+	}
+	newType, err := newCatalog.Resolve(ctx, newGVK)
+	if err != nil {
+		return nil, err
+	}
+	return &Comparison{
+		Changes:         swagger.CompareResolvedResources(oldType, newType).Changes,
+		BreakingChanges: swagger.FindBreakingChanges(oldType, newType),
+	}, nil
+}
@@ -0,0 +1,91 @@
+package schema
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mattfenwick/collections/pkg/slice"
+	"github.com/mattfenwick/kubectl-schema/pkg/diff"
+	"github.com/mattfenwick/kubectl-schema/pkg/swagger"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+func RunCatalogTests() {
+	makeCatalog := func(version string, specProperties map[string]*swagger.SpecType) *Catalog {
+		return NewCatalog(swagger.MustVersion(version), &swagger.KubeSpec{Definitions: map[string]*swagger.SpecType{
+			"io.k8s.api.apps.v1.Deployment": {
+				Type: "object",
+				Properties: map[string]*swagger.SpecType{
+					"kind": {Type: "string"},
+					"spec": {Ref: "#/definitions/io.k8s.api.apps.v1.DeploymentSpec"},
+				},
+				XKubernetesGroupVersionKind: []*swagger.GVK{{Group: "apps", Version: "v1", Kind: "Deployment"}},
+			},
+			"io.k8s.api.apps.v1.DeploymentSpec": {Type: "object", Properties: specProperties},
+			"io.k8s.api.core.v1.Pod": {
+				Type:                        "object",
+				XKubernetesGroupVersionKind: []*swagger.GVK{{Group: "", Version: "v1", Kind: "Pod"}},
+			},
+		}})
+	}
+	deployment := &swagger.GVK{Group: "apps", Version: "v1", Kind: "Deployment"}
+	oldCatalog := makeCatalog("1.29.0", map[string]*swagger.SpecType{
+		"replicas": {Type: "integer"},
+		"args":     {Type: "array", Items: &swagger.SpecType{Type: "string"}},
+	})
+	newCatalog := makeCatalog("1.30.0", map[string]*swagger.SpecType{
+		"replicas": {Type: "string"},
+		"paused":   {Type: "boolean"},
+	})
+
+	Describe("Catalog", func() {
+		It("lists GVKs sorted by group, version and kind", func() {
+			Expect(slice.Map(func(g *swagger.GVK) string { return g.ToString() }, oldCatalog.GVKs())).
+				To(Equal([]string{"v1.Pod", "apps.v1.Deployment"}))
+		})
+
+		It("resolves kinds", func() {
+			resolved, err := oldCatalog.Resolve(context.Background(), deployment)
+			Expect(err).To(Succeed())
+			Expect(resolved.Object.Properties["spec"].Object.Properties["replicas"].Primitive).To(Equal("integer"))
+
+			_, err = oldCatalog.Resolve(context.Background(), &swagger.GVK{Group: "apps", Version: "v1", Kind: "StatefulSet"})
+			Expect(errors.Is(err, ErrNotFound)).To(BeTrue())
+		})
+
+		It("walks paths", func() {
+			var paths []string
+			err := oldCatalog.Walk(context.Background(), deployment, func(path []string, resolved *swagger.ResolvedType) error {
+				paths = append(paths, strings.Join(path, ".")+" "+resolved.TypeName())
+				return nil
+			})
+			Expect(err).To(Succeed())
+			Expect(paths).To(Equal([]string{
+				" object",
+				"kind string",
+				"spec object",
+				"spec.args array",
+				"spec.args.[] string",
+				"spec.replicas integer",
+			}))
+		})
+
+		It("stops walking when the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			err := oldCatalog.Walk(ctx, deployment, func(path []string, resolved *swagger.ResolvedType) error { return nil })
+			Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+		})
+
+		It("compares kinds", func() {
+			comparison, err := Compare(context.Background(), oldCatalog, deployment, newCatalog, deployment)
+			Expect(err).To(Succeed())
+			Expect(slice.Map(func(n *diff.Node) string { return n.Kind.Short() + " " + strings.Join(n.Path, ".") }, comparison.Changes)).
+				To(Equal([]string{"- spec.args", "<> spec.replicas", "+ spec.paused"}))
+			Expect(slice.Map(func(c *swagger.BreakingChange) string { return strings.Join(c.Path, ".") + ": " + c.Reason }, comparison.BreakingChanges)).
+				To(Equal([]string{"spec.args: field removed", "spec.replicas: type narrowed from integer to string"}))
+		})
+	})
+}
@@ -0,0 +1,49 @@
+// Package schema is for querying kubernetes schemas from Go code: load the spec for a kube version,
+// list its GVKs, resolve kinds, walk their fields, and compare kinds across versions.  Nothing is
+// printed; everything is returned as typed values.
+package schema
+
+import (
+	"context"
+
+	"github.com/mattfenwick/kubectl-schema/pkg/swagger"
+)
+
+// Client loads a Catalog for each kube version.  Upstream specs are read from the data directory
+// (see swagger.GetSpecsRootDirectory), and downloaded first if they're not there yet, using
+// Downloader or, if that's nil, swagger.DefaultSpecDownloader.  CRDs from CRDPaths -- yaml files,
+// or directories of them -- are merged into every Catalog.
+type Client struct {
+	CRDPaths   []string
+	Downloader *swagger.SpecDownloader
+}
+
+func NewClient(crdPaths []string) *Client {
+	return &Client{CRDPaths: crdPaths}
+}
+
+// Load reads and parses the spec for a kube version, such as "1.30.2"
+func (c *Client) Load(ctx context.Context, kubeVersion string) (*Catalog, error) {
+	version, err := swagger.ParseKubeVersion(kubeVersion)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := (&swagger.SpecSource{CRDPaths: c.CRDPaths, Downloader: c.Downloader}).Read(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+	return NewCatalog(version, spec), nil
+}
+
+// LoadAll loads catalogs in the same order as kubeVersions, stopping at the first error
+func (c *Client) LoadAll(ctx context.Context, kubeVersions []string) ([]*Catalog, error) {
+	var catalogs []*Catalog
+	for _, kubeVersion := range kubeVersions {
+		catalog, err := c.Load(ctx, kubeVersion)
+		if err != nil {
+			return nil, err
+		}
+		catalogs = append(catalogs, catalog)
+	}
+	return catalogs, nil
+}
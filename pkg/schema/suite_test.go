@@ -0,0 +1,15 @@
+package schema
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	gomega.RegisterFailHandler(Fail)
+
+	RunCatalogTests()
+
+	RunSpecs(t, "schema suite")
+}
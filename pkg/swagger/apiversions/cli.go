@@ -11,14 +11,14 @@ func SetupKindCommand() *cobra.Command {
 		Use:   "kind",
 		Short: "compare types from across swagger specs",
 		Args:  cobra.ExactArgs(0),
-		Run: func(cmd *cobra.Command, as []string) {
-			RunKind()
+		RunE: func(cmd *cobra.Command, as []string) error {
+			return RunKind()
 		},
 	}
 
 	return command
 }
 
-func RunKind() {
-	ParseKindResults()
+func RunKind() error {
+	return ParseKindResults()
 }
@@ -6,12 +6,11 @@ import (
 	"regexp"
 	"strings"
 
-	"github.com/mattfenwick/kubectl-schema/pkg/utils"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
-func ParseKindResults() {
+func ParseKindResults() error {
 	previousTable := &ResourcesTable{
 		Version: "",
 		Kinds:   map[string][]string{},
@@ -31,9 +30,13 @@ func ParseKindResults() {
 		//   instead of reading from static file
 
 		headers, rows, err := ReadCSV(fmt.Sprintf("../kube/data/v%s-api-resources.txt", version))
-		utils.Die(err)
+		if err != nil {
+			return err
+		}
 		rsTable, err := NewResourcesTable(version, headers, rows)
-		utils.Die(err)
+		if err != nil {
+			return err
+		}
 
 		fmt.Printf("%s\n", rsTable.KindResourcesTable())
 
@@ -52,6 +55,7 @@ func ParseKindResults() {
 
 		previousTable = rsTable
 	}
+	return nil
 }
 
 func ReadCSV(path string) ([]string, [][]string, error) {
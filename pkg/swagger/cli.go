@@ -2,20 +2,103 @@ package swagger
 
 import (
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/mattfenwick/collections/pkg/json"
 	"github.com/mattfenwick/kubectl-schema/pkg/utils"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 func RunRootSchemaCommand() {
-	command := SetupRootSchemaCommand()
-	utils.Die(errors.Wrapf(command.Execute(), "run root schema command"))
+	command, err := ExecuteRootSchemaCommand(SetupRootSchemaCommand())
+	var usageError *utils.UsageError
+	if errors.As(err, &usageError) {
+		fmt.Fprintf(os.Stderr, "Error: %s\nRun '%s --help' for usage.\n", err.Error(), command.CommandPath())
+	} else if err != nil && !errors.Is(err, utils.ErrDifferencesFound) {
+		logrus.Debugf("%+v", err)
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+	}
+	os.Exit(utils.ExitCode(err))
+}
+
+// ExecuteRootSchemaCommand runs a command, and turns cobra's errors for unknown commands -- which
+// includes positional arguments to the root command -- into usage errors
+func ExecuteRootSchemaCommand(root *cobra.Command) (*cobra.Command, error) {
+	command, err := root.ExecuteC()
+	if err != nil && strings.HasPrefix(err.Error(), "unknown command ") {
+		return command, utils.NewUsageError("%s", err.Error())
+	}
+	return command, err
+}
+
+// noArgs rejects positional arguments with a usage error
+func noArgs(cmd *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		return utils.NewUsageError("%s doesn't take arguments, found %+v", cmd.CommandPath(), args)
+	}
+	return nil
+}
+
+func describeSpecsRootDirectory() string {
+	dataDir, err := GetSpecsRootDirectory()
+	if err != nil {
+		return fmt.Sprintf("<unknown: %s>", err.Error())
+	}
+	return dataDir
 }
 
 type RootSchemaFlags struct {
-	Verbosity string
+	Verbosity       string
+	Config          string
+	Profile         string
+	SpecURLTemplate string
+	HTTPTimeout     time.Duration
+	HTTPRetries     int
+	CABundle        string
+	BearerTokenFile string
+}
+
+func (f *RootSchemaFlags) GetProfile() string {
+	if f.Profile != "" {
+		return f.Profile
+	}
+	return os.Getenv(ProfileEnvVar)
+}
+
+// ReadConfig reads and validates the config file
+func (f *RootSchemaFlags) ReadConfig(root *cobra.Command) (*ConfigFile, error) {
+	configPath, explicit, err := GetConfigPath(f.Config)
+	if err != nil {
+		return nil, err
+	}
+	config, err := ReadConfigFile(configPath, explicit)
+	if err != nil {
+		return nil, err
+	}
+	return config, config.Validate(root, f.GetProfile())
+}
+
+// SpecDownloader builds a downloader from flags and environment variables
+func (f *RootSchemaFlags) SpecDownloader() (*SpecDownloader, error) {
+	options := utils.DefaultHTTPOptions()
+	options.Timeout = f.HTTPTimeout
+	options.Retries = f.HTTPRetries
+	options.CABundle = f.CABundle
+	options.Progress = logDownloadProgress()
+	if f.BearerTokenFile != "" {
+		token, err := utils.ReadBearerTokenFile(f.BearerTokenFile)
+		if err != nil {
+			return nil, err
+		}
+		options.BearerToken = token
+	} else {
+		options.BearerToken = os.Getenv(BearerTokenEnvVar)
+	}
+	return NewSpecDownloader(f.SpecURLTemplate, options)
 }
 
 func SetupRootSchemaCommand() *cobra.Command {
@@ -30,19 +113,63 @@ This downloads specs to %s.
 
 The data directory can be changed using the %s environment variable; if this variable
 is not set, a directory underneath the home directory is created and used.
-`, GetSpecsRootDirectory(), DataDirEnvVar),
+
+Specs are downloaded from --spec-url-template, which may point at a mirror; it can also be set with
+the %s environment variable.  A bearer token for the mirror can be given with
+--bearer-token-file, or the %s environment variable.  Proxies are configured with the
+standard HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+
+Defaults for any flag can be set in a config file: config.yaml in the data directory, or the file
+given by --config or the %s environment variable.  Named profiles in the config file are
+selected with --profile, or the %s environment variable.  Run 'schema config' to see
+the effective value of every flag, and where it came from.
+`, describeSpecsRootDirectory(), DataDirEnvVar, SpecURLTemplateEnvVar, BearerTokenEnvVar, ConfigEnvVar, ProfileEnvVar),
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-			return utils.SetUpLogger(flags.Verbosity)
+			config, err := flags.ReadConfig(cmd.Root())
+			if err != nil {
+				return err
+			}
+			if err := config.Apply(cmd, flags.GetProfile()); err != nil {
+				return err
+			}
+			if err := utils.SetUpLogger(flags.Verbosity); err != nil {
+				return err
+			}
+			downloader, err := flags.SpecDownloader()
+			if err != nil {
+				return err
+			}
+			DefaultSpecDownloader = downloader
+			return nil
 		},
+		SilenceErrors: true,
+		SilenceUsage:  true,
 	}
+	command.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return utils.NewUsageError("%s", err.Error())
+	})
 
 	command.PersistentFlags().StringVarP(&flags.Verbosity, "verbosity", "v", "info", "log level; one of [info, debug, trace, warn, error, fatal, panic]")
+	command.PersistentFlags().StringVar(&flags.Config, "config", "", fmt.Sprintf("config file; defaults to $%s, or else config.yaml in the data directory", ConfigEnvVar))
+	command.PersistentFlags().StringVar(&flags.Profile, "profile", "", fmt.Sprintf("config file profile to use; defaults to $%s", ProfileEnvVar))
+	command.PersistentFlags().StringVar(&flags.SpecURLTemplate, "spec-url-template", DefaultSpecURLTemplate, "where to download specs from; {version} is replaced by the kube version")
+	command.PersistentFlags().DurationVar(&flags.HTTPTimeout, "http-timeout", utils.DefaultHTTPOptions().Timeout, "timeout for each attempt to download a spec; 0 means no timeout")
+	command.PersistentFlags().IntVar(&flags.HTTPRetries, "http-retries", utils.DefaultHTTPOptions().Retries, "how many times to retry a failed download, with exponential backoff")
+	command.PersistentFlags().StringVar(&flags.CABundle, "ca-bundle", "", "PEM file with extra certificates to trust when downloading specs")
+	command.PersistentFlags().StringVar(&flags.BearerTokenFile, "bearer-token-file", "", "file with a bearer token to send when downloading specs")
 
 	command.AddCommand(SetupVersionCommand())
 	command.AddCommand(SetupExplainCommand())
 	command.AddCommand(SetupCompareResourceCommand())
 	command.AddCommand(SetupShowResourcesCommand())
-	command.AddCommand(SetupConfigCommand())
+	command.AddCommand(SetupConfigCommand(flags))
+	command.AddCommand(SetupSkeletonCommand())
+	command.AddCommand(SetupSampleCommand())
+	command.AddCommand(SetupExportCommand())
+	command.AddCommand(SetupTrimCommand())
+	command.AddCommand(SetupLintCRDCommand())
+	command.AddCommand(SetupSpecAuditCommand())
+	command.AddCommand(SetupRBACCommand())
 
 	return command
 }
@@ -57,23 +184,26 @@ func SetupVersionCommand() *cobra.Command {
 	command := &cobra.Command{
 		Use:   "version",
 		Short: "print out version information",
-		Args:  cobra.ExactArgs(0),
-		Run: func(cmd *cobra.Command, as []string) {
-			RunVersionCommand()
+		Args:  noArgs,
+		RunE: func(cmd *cobra.Command, as []string) error {
+			return RunVersionCommand()
 		},
 	}
 
 	return command
 }
 
-func RunVersionCommand() {
+func RunVersionCommand() error {
 	jsonString, err := json.MarshalToString(map[string]string{
 		"Version":   version,
 		"GitSHA":    gitSHA,
 		"BuildTime": buildTime,
 	})
-	utils.Die(err)
+	if err != nil {
+		return err
+	}
 	fmt.Printf("kubectl-schema version: \n%s\n", jsonString)
+	return nil
 }
 
 func SetupExplainCommand() *cobra.Command {
@@ -82,18 +212,244 @@ func SetupExplainCommand() *cobra.Command {
 	command := &cobra.Command{
 		Use:   "explain",
 		Short: "explain resources from a swagger spec",
-		Args:  cobra.ExactArgs(0),
-		Run: func(cmd *cobra.Command, as []string) {
-			RunExplain(args)
+		Args:  noArgs,
+		RunE: func(cmd *cobra.Command, as []string) error {
+			return RunExplain(cmd.Context(), args)
 		},
 	}
 
-	command.Flags().StringVar(&args.Format, "format", "condensed", "output format; possible values: table, condensed")
-	command.Flags().StringSliceVar(&args.ApiVersions, "api-version", []string{}, "api versions to look for resource under; looks under all if not specified")
-	command.Flags().StringSliceVar(&args.Resources, "resource", []string{}, "kubernetes resources to explain")
+	command.Flags().StringVar(&args.Format, "format", "condensed", "output format; possible values: table, condensed, tree, json, yaml, pivot")
+	command.Flags().BoolVar(&args.DiffOnly, "diff-only", false, "with --format pivot, only show paths whose type differs between kube versions")
+	command.Flags().StringSliceVar(&args.ApiVersions, "api-version", []string{}, "api versions to look for resource under, as kubectl-style names, globs or /regular expressions/; looks under all if not specified")
+	command.Flags().StringSliceVar(&args.Resources, "resource", []string{}, "kubernetes resources to explain, as kubectl-style names, globs or /regular expressions/")
+	command.Flags().StringSliceVar(&args.ExcludeApiVersions, "exclude-api-version", []string{}, "api versions to leave out, as kubectl-style names, globs or /regular expressions/")
+	command.Flags().StringSliceVar(&args.ExcludeResources, "exclude-resource", []string{}, "resources to leave out, as kubectl-style names, globs or /regular expressions/")
 	command.Flags().StringSliceVar(&args.KubeVersions, "kube-version", []string{defaultKubeVersions[len(defaultKubeVersions)-1]}, "kubernetes spec versions")
 	command.Flags().IntVar(&args.Depth, "depth", 0, "number of layers to print; 0 is treated as unlimited")
-	command.Flags().StringSliceVar(&args.Paths, "path", []string{}, "paths to search under, components separated by '.'; if empty, all paths are searched")
+	command.Flags().StringSliceVar(&args.Paths, "path", []string{}, "paths to search under, components separated by '.'; components may be globs, and '**' matches any number of components; if empty, all paths are searched")
+	command.Flags().StringSliceVar(&args.ExcludePaths, "exclude-path", []string{}, "paths to leave out, along with everything under them; same syntax as --path")
+	command.Flags().StringSliceVar(&args.CRDs, "crd", []string{}, "CustomResourceDefinition yaml files, or directories of them, to merge into the spec")
+
+	return command
+}
+
+func SetupSkeletonCommand() *cobra.Command {
+	args := &SkeletonArgs{}
+
+	command := &cobra.Command{
+		Use:   "skeleton",
+		Short: "generate skeleton yaml manifests, with descriptions as comments, from a swagger spec",
+		Args:  noArgs,
+		RunE: func(cmd *cobra.Command, as []string) error {
+			return RunSkeleton(cmd.Context(), args)
+		},
+	}
+
+	command.Flags().StringSliceVar(&args.ApiVersions, "api-version", []string{}, "api versions to look for resource under, as kubectl-style names, globs or /regular expressions/; looks under all if not specified")
+	command.Flags().StringSliceVar(&args.Resources, "resource", []string{}, "kubernetes resources to generate skeletons for, as kubectl-style names, globs or /regular expressions/")
+	command.Flags().StringSliceVar(&args.ExcludeApiVersions, "exclude-api-version", []string{}, "api versions to leave out, as kubectl-style names, globs or /regular expressions/")
+	command.Flags().StringSliceVar(&args.ExcludeResources, "exclude-resource", []string{}, "resources to leave out, as kubectl-style names, globs or /regular expressions/")
+	command.Flags().StringVar(&args.KubeVersion, "kube-version", defaultKubeVersions[len(defaultKubeVersions)-1], "kubernetes spec version")
+	command.Flags().IntVar(&args.Depth, "depth", 0, "number of layers to include; 0 is treated as unlimited")
+	command.Flags().StringSliceVar(&args.Paths, "path", []string{}, "paths to include, components separated by '.'; components may be globs, and '**' matches any number of components; if empty, all paths are included")
+	command.Flags().StringSliceVar(&args.ExcludePaths, "exclude-path", []string{}, "paths to leave out, along with everything under them; same syntax as --path")
+	command.Flags().BoolVar(&args.RequiredOnly, "required-only", false, "if true, only include required fields (plus apiVersion and kind)")
+	command.Flags().StringSliceVar(&args.CRDs, "crd", []string{}, "CustomResourceDefinition yaml files, or directories of them, to merge into the spec")
+
+	return command
+}
+
+func SetupSampleCommand() *cobra.Command {
+	args := &SampleArgs{}
+
+	command := &cobra.Command{
+		Use:   "sample",
+		Short: "generate random objects which conform to a resource's schema",
+		Args:  noArgs,
+		RunE: func(cmd *cobra.Command, as []string) error {
+			args.SeedSet = cmd.Flags().Changed("seed")
+			return RunSample(cmd.Context(), args)
+		},
+	}
+
+	command.Flags().StringSliceVar(&args.ApiVersions, "api-version", []string{}, "api versions to look for resource under, as kubectl-style names, globs or /regular expressions/; looks under all if not specified")
+	command.Flags().StringSliceVar(&args.Resources, "resource", []string{}, "kubernetes resources to generate samples for, as kubectl-style names, globs or /regular expressions/")
+	command.Flags().StringSliceVar(&args.ExcludeApiVersions, "exclude-api-version", []string{}, "api versions to leave out, as kubectl-style names, globs or /regular expressions/")
+	command.Flags().StringSliceVar(&args.ExcludeResources, "exclude-resource", []string{}, "resources to leave out, as kubectl-style names, globs or /regular expressions/")
+	command.Flags().StringVar(&args.KubeVersion, "kube-version", defaultKubeVersions[len(defaultKubeVersions)-1], "kubernetes spec version")
+	command.Flags().IntVar(&args.Count, "count", 1, "number of samples to generate per resource and api version")
+	command.Flags().Int64Var(&args.Seed, "seed", 0, "seed for the random number generator; if not set, a seed based on the current time is used")
+	command.Flags().BoolVar(&args.RequiredOnly, "required-only", false, "if true, only fill in required fields (plus apiVersion and kind)")
+	command.Flags().IntVar(&args.Depth, "depth", 0, "number of layers to fill in; 0 is treated as unlimited")
+	command.Flags().IntVar(&args.MaxItems, "max-items", 2, "maximum number of items to put in arrays and maps")
+	command.Flags().StringVar(&args.Format, "format", "yaml", "output format; possible values: yaml, json")
+	command.Flags().StringSliceVar(&args.CRDs, "crd", []string{}, "CustomResourceDefinition yaml files, or directories of them, to merge into the spec")
+
+	return command
+}
+
+func SetupExportCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "export",
+		Short: "export schemas in other formats",
+		Args:  noArgs,
+	}
+
+	command.AddCommand(SetupExportJsonSchemaCommand())
+	command.AddCommand(SetupExportTypeScriptCommand())
+	command.AddCommand(SetupExportCueCommand())
+
+	return command
+}
+
+func SetupExportJsonSchemaCommand() *cobra.Command {
+	args := &ExportJsonSchemaArgs{}
+
+	command := &cobra.Command{
+		Use:   "jsonschema",
+		Short: "write standalone json schemas, one per kind and api version, in both regular and strict variants",
+		Args:  noArgs,
+		RunE: func(cmd *cobra.Command, as []string) error {
+			return RunExportJsonSchema(cmd.Context(), args)
+		},
+	}
+
+	command.Flags().StringSliceVar(&args.KubeVersions, "kube-version", []string{defaultKubeVersions[len(defaultKubeVersions)-1]}, "kubernetes spec versions")
+	command.Flags().StringSliceVar(&args.Resources, "resource", []string{}, "resources to include, as kubectl-style names, globs or /regular expressions/; if empty, include all")
+	command.Flags().StringSliceVar(&args.ApiVersions, "api-version", []string{}, "api versions to include, as kubectl-style names, globs or /regular expressions/; if empty, include all")
+	command.Flags().StringSliceVar(&args.ExcludeResources, "exclude-resource", []string{}, "resources to leave out, as kubectl-style names, globs or /regular expressions/")
+	command.Flags().StringSliceVar(&args.ExcludeApiVersions, "exclude-api-version", []string{}, "api versions to leave out, as kubectl-style names, globs or /regular expressions/")
+	command.Flags().StringVar(&args.OutputDir, "output-dir", "schemas", "directory to write schemas into")
+	command.Flags().StringSliceVar(&args.CRDs, "crd", []string{}, "CustomResourceDefinition yaml files, or directories of them, to merge into the spec")
+
+	return command
+}
+
+func setupExportCodeFlags(command *cobra.Command, args *ExportCodeArgs) {
+	command.Flags().StringVar(&args.KubeVersion, "kube-version", defaultKubeVersions[len(defaultKubeVersions)-1], "kubernetes spec version")
+	command.Flags().StringSliceVar(&args.Resources, "resource", []string{}, "resources to include, as kubectl-style names, globs or /regular expressions/; if empty, include all")
+	command.Flags().StringSliceVar(&args.ApiVersions, "api-version", []string{}, "api versions to include, as kubectl-style names, globs or /regular expressions/; if empty, include all")
+	command.Flags().StringSliceVar(&args.ExcludeResources, "exclude-resource", []string{}, "resources to leave out, as kubectl-style names, globs or /regular expressions/")
+	command.Flags().StringSliceVar(&args.ExcludeApiVersions, "exclude-api-version", []string{}, "api versions to leave out, as kubectl-style names, globs or /regular expressions/")
+	command.Flags().StringVar(&args.Output, "output", "", "file to write to; if empty, writes to stdout")
+	command.Flags().StringSliceVar(&args.CRDs, "crd", []string{}, "CustomResourceDefinition yaml files, or directories of them, to merge into the spec")
+}
+
+func SetupExportTypeScriptCommand() *cobra.Command {
+	args := &ExportCodeArgs{}
+
+	command := &cobra.Command{
+		Use:   "typescript",
+		Short: "generate typescript type definitions",
+		Args:  noArgs,
+		RunE: func(cmd *cobra.Command, as []string) error {
+			return RunExportTypeScript(cmd.Context(), args)
+		},
+	}
+
+	setupExportCodeFlags(command, args)
+
+	return command
+}
+
+func SetupExportCueCommand() *cobra.Command {
+	args := &ExportCodeArgs{}
+
+	command := &cobra.Command{
+		Use:   "cue",
+		Short: "generate cue definitions",
+		Args:  noArgs,
+		RunE: func(cmd *cobra.Command, as []string) error {
+			return RunExportCue(cmd.Context(), args)
+		},
+	}
+
+	setupExportCodeFlags(command, args)
+	command.Flags().StringVar(&args.Package, "package", "kube", "cue package name")
+
+	return command
+}
+
+func SetupTrimCommand() *cobra.Command {
+	args := &TrimArgs{}
+
+	command := &cobra.Command{
+		Use:   "trim",
+		Short: "write a minimal swagger spec with selected resources and the definitions they reference",
+		Args:  noArgs,
+		RunE: func(cmd *cobra.Command, as []string) error {
+			return RunTrim(cmd.Context(), args)
+		},
+	}
+
+	command.Flags().StringVar(&args.KubeVersion, "kube-version", defaultKubeVersions[len(defaultKubeVersions)-1], "kubernetes spec version")
+	command.Flags().StringSliceVar(&args.Resources, "resource", []string{}, "resources to include, as kubectl-style names, globs or /regular expressions/; if empty, include all")
+	command.Flags().StringSliceVar(&args.ApiVersions, "api-version", []string{}, "api versions to include, as kubectl-style names, globs or /regular expressions/; if empty, include all")
+	command.Flags().StringSliceVar(&args.ExcludeResources, "exclude-resource", []string{}, "resources to leave out, as kubectl-style names, globs or /regular expressions/")
+	command.Flags().StringSliceVar(&args.ExcludeApiVersions, "exclude-api-version", []string{}, "api versions to leave out, as kubectl-style names, globs or /regular expressions/")
+	command.Flags().StringVar(&args.Output, "output", "", "file to write to; if empty, writes to stdout")
+	command.Flags().StringSliceVar(&args.CRDs, "crd", []string{}, "CustomResourceDefinition yaml files, or directories of them, to merge into the spec")
+
+	return command
+}
+
+func SetupLintCRDCommand() *cobra.Command {
+	args := &LintCRDArgs{}
+
+	command := &cobra.Command{
+		Use:   "lint-crd",
+		Short: "check CustomResourceDefinition schemas against structural schema rules and kubernetes api conventions",
+		Args:  noArgs,
+		RunE: func(cmd *cobra.Command, as []string) error {
+			return RunLintCRD(args)
+		},
+	}
+
+	command.Flags().StringSliceVar(&args.CRDs, "crd", []string{}, "CustomResourceDefinition yaml files, or directories of them, to check")
+	command.Flags().StringSliceVar(&args.SkipRules, "skip-rule", []string{}, "rules to skip")
+
+	return command
+}
+
+func SetupRBACCommand() *cobra.Command {
+	args := &RBACArgs{}
+
+	command := &cobra.Command{
+		Use:   "rbac",
+		Short: "generate a ClusterRole or Role granting verbs on resources, using the plurals and api groups from a swagger spec",
+		Args:  noArgs,
+		RunE: func(cmd *cobra.Command, as []string) error {
+			return RunRBAC(cmd.Context(), args)
+		},
+	}
+
+	command.Flags().StringVar(&args.KubeVersion, "kube-version", defaultKubeVersions[len(defaultKubeVersions)-1], "kubernetes spec version")
+	command.Flags().StringSliceVar(&args.ApiVersions, "api-version", []string{}, "api versions to look for resources under, as kubectl-style names, globs or /regular expressions/; looks under all if not specified")
+	command.Flags().StringSliceVar(&args.Resources, "resource", []string{}, "kubernetes resources to grant access to, as kubectl-style names, globs or /regular expressions/")
+	command.Flags().StringSliceVar(&args.Manifests, "manifests", []string{}, "yaml files, or directories of them, whose resources to grant access to")
+	command.Flags().StringSliceVar(&args.Verbs, "verb", []string{"get", "list", "watch"}, "verbs to grant; verbs a resource doesn't support are left out, with a warning")
+	command.Flags().StringSliceVar(&args.Subresources, "subresource", []string{}, "subresources, such as status or scale, to also grant the verbs on")
+	command.Flags().StringVar(&args.Name, "name", "controller", "name of the ClusterRole or Role")
+	command.Flags().StringVar(&args.Namespace, "namespace", "", "if set, generate a Role in this namespace instead of a ClusterRole; cluster-scoped resources are left out")
+	command.Flags().StringSliceVar(&args.CRDs, "crd", []string{}, "CustomResourceDefinition yaml files, or directories of them, to merge into the spec")
+
+	return command
+}
+
+func SetupSpecAuditCommand() *cobra.Command {
+	args := &SpecAuditArgs{}
+
+	command := &cobra.Command{
+		Use:   "spec-audit",
+		Short: "report spec keys which aren't modeled, and check that parsing a spec round trips",
+		Args:  noArgs,
+		RunE: func(cmd *cobra.Command, as []string) error {
+			return RunSpecAudit(cmd.Context(), args)
+		},
+	}
+
+	command.Flags().StringSliceVar(&args.KubeVersions, "kube-version", LatestKubePatchVersionStrings, "kubernetes spec versions")
+	command.Flags().IntVar(&args.MaxPaths, "max-paths", 5, "number of paths to show for each unmodeled key; 0 shows all")
 
 	return command
 }
@@ -104,16 +460,29 @@ func SetupCompareResourceCommand() *cobra.Command {
 	command := &cobra.Command{
 		Use:   "compare",
 		Short: "compare types across kube versions",
-		Args:  cobra.ExactArgs(0),
-		Run: func(cmd *cobra.Command, as []string) {
-			RunCompareResource(args)
+		Args:  noArgs,
+		RunE: func(cmd *cobra.Command, as []string) error {
+			// the default resource filter is meant for kube versions, not CRDs
+			if len(args.CRDFiles) > 0 && !cmd.Flags().Changed("resource") {
+				args.Resources = nil
+			}
+			return RunCompareResource(cmd.Context(), args)
 		},
 	}
 
-	command.Flags().StringSliceVar(&args.ApiVersions, "api-version", []string{}, "api versions to use; if empty, uses all")
+	command.Flags().StringSliceVar(&args.ApiVersions, "api-version", []string{}, "api versions to use, as kubectl-style names, globs or /regular expressions/; if empty, uses all")
+	command.Flags().StringSliceVar(&args.ExcludeApiVersions, "exclude-api-version", []string{}, "api versions to leave out, as kubectl-style names, globs or /regular expressions/")
 
 	command.Flags().StringSliceVar(&args.KubeVersions, "kube-version", []string{defaultKubeVersions[0], defaultKubeVersions[len(defaultKubeVersions)-1]}, "two kubernetes versions to compare (must be exactly 2)")
-	command.Flags().StringSliceVar(&args.Resources, "resource", []string{"Pod"}, "resources to include; if empty, includes all")
+	command.Flags().StringSliceVar(&args.Resources, "resource", []string{"Pod"}, "resources to include, as kubectl-style names, globs or /regular expressions/; if empty, includes all")
+	command.Flags().StringSliceVar(&args.ExcludeResources, "exclude-resource", []string{}, "resources to leave out, as kubectl-style names, globs or /regular expressions/")
+	command.Flags().StringSliceVar(&args.Paths, "path", []string{}, "paths to show changes under, components separated by '.'; components may be globs, and '**' matches any number of components; if empty, all paths are shown")
+	command.Flags().StringSliceVar(&args.ExcludePaths, "exclude-path", []string{}, "paths to leave out, along with everything under them; same syntax as --path")
+	command.Flags().StringSliceVar(&args.CRDs, "crd", []string{}, "CustomResourceDefinition yaml files, or directories of them, to merge into the spec")
+
+	command.Flags().StringSliceVar(&args.CRDFiles, "crd-file", []string{}, "compare CustomResourceDefinitions instead of kube versions: with one file, compare consecutive versions of each CRD; with two files, compare the same versions across both files")
+	command.Flags().StringSliceVar(&args.CRDVersions, "crd-version", []string{}, "CRD versions to compare when using --crd-file; if empty, compares all")
+	command.Flags().BoolVar(&args.ExitCode, "exit-code", false, "if true, exit with status 1 if any differences are found, like git diff --exit-code")
 
 	return command
 }
@@ -124,34 +493,70 @@ func SetupShowResourcesCommand() *cobra.Command {
 	command := &cobra.Command{
 		Use:   "resources",
 		Short: "show available resources, by api-version and kubernetes version",
-		Args:  cobra.ExactArgs(0),
-		Run: func(cmd *cobra.Command, as []string) {
-			RunShowResources(args)
+		Args:  noArgs,
+		RunE: func(cmd *cobra.Command, as []string) error {
+			return RunShowResources(cmd.Context(), args)
 		},
 	}
 
 	command.Flags().BoolVar(&args.Diff, "diff", false, "if true, calculate a diff from kube version to kube version.  if false, simply print resources")
+	command.Flags().BoolVar(&args.Catalog, "catalog", false, "if true, show each resource's plural, scope, verbs, subresources and query parameters from the spec's paths, instead of grouping")
+	command.Flags().BoolVar(&args.Operations, "operations", false, "if true, show each resource's verbs, subresources and patch types from the spec's paths, instead of grouping; use with --diff to see when they change")
 
-	command.Flags().StringVar(&args.GroupBy, "group-by", "resource", "what to group by: valid values are 'resource' and 'api-version'")
+	command.Flags().StringVar(&args.GroupBy, "group-by", "resource", "what to group by: valid values are 'resource', 'api-version', 'group' and 'stability'")
+	command.Flags().StringSliceVar(&args.Groups, "group", []string{}, "api groups to include, as kubectl-style names, globs or /regular expressions/; the core group is 'core'; if empty, include all")
+	command.Flags().StringSliceVar(&args.KindClasses, "kind-class", []string{string(KindClassResource)}, "kinds to include, classified using the spec's paths: resource, subresource, list, options, event, other, or all")
+	command.Flags().StringSliceVar(&args.Stabilities, "stability", []string{}, "stability levels to include, derived from api versions: alpha, beta or ga; if empty, include all")
 	command.Flags().StringSliceVar(&args.KubeVersions, "kube-version", defaultKubeVersions, "kube versions to explain")
 
-	command.Flags().StringSliceVar(&args.Resources, "resource", []string{}, "resources to include; if empty, include all")
-	command.Flags().StringSliceVar(&args.ApiVersions, "api-version", []string{}, "api versions to include; if empty, include all")
+	command.Flags().StringSliceVar(&args.Resources, "resource", []string{}, "resources to include, as kubectl-style names, globs or /regular expressions/; if empty, include all")
+	command.Flags().StringSliceVar(&args.ApiVersions, "api-version", []string{}, "api versions to include, as kubectl-style names, globs or /regular expressions/; if empty, include all")
+	command.Flags().StringSliceVar(&args.ExcludeResources, "exclude-resource", []string{}, "resources to leave out, as kubectl-style names, globs or /regular expressions/")
+	command.Flags().StringSliceVar(&args.ExcludeApiVersions, "exclude-api-version", []string{}, "api versions to leave out, as kubectl-style names, globs or /regular expressions/")
 
-	command.Flags().StringVar(&args.Format, "format", "table", "format to use for output: valid values are 'table' and 'markdown'")
+	command.Flags().StringVar(&args.Format, "format", "table", "format to use for output: valid values are 'table', 'markdown', 'json', 'yaml', 'csv' and 'tsv'")
+	command.Flags().StringSliceVar(&args.CRDs, "crd", []string{}, "CustomResourceDefinition yaml files, or directories of them, to merge into the spec")
 
 	return command
 }
 
-func SetupConfigCommand() *cobra.Command {
+func SetupConfigCommand(flags *RootSchemaFlags) *cobra.Command {
 	command := &cobra.Command{
 		Use:   "config",
-		Short: "show compiled-in configuration",
-		Args:  cobra.ExactArgs(0),
-		Run: func(cmd *cobra.Command, as []string) {
-			fmt.Printf("default kube versions:\n%s\n", json.MustMarshalToString(defaultKubeVersions))
-			fmt.Printf("kube patch versions:\n%s\n", json.MustMarshalToString(LatestKubePatchVersionStrings))
+		Short: "show the effective configuration: flag values for every command, and where they come from",
+		Args:  noArgs,
+		RunE: func(cmd *cobra.Command, as []string) error {
+			return RunConfig(cmd.Root(), flags)
 		},
 	}
 	return command
 }
+
+func RunConfig(root *cobra.Command, flags *RootSchemaFlags) error {
+	configPath, _, err := GetConfigPath(flags.Config)
+	if err != nil {
+		return err
+	}
+	config, err := flags.ReadConfig(root)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("config file: %s\n", configPath)
+	fmt.Printf("profile: %s\n", flags.GetProfile())
+	fmt.Printf("kube patch versions:\n%s\n\n", strings.Join(LatestKubePatchVersionStrings, ", "))
+
+	var rows [][]string
+	for _, value := range config.Resolve(root, flags.GetProfile()) {
+		command := value.Command
+		if command == "" {
+			command = "(global)"
+		}
+		rows = append(rows, []string{command, value.Flag, value.Value, value.Source})
+	}
+	table, err := NewRawTable([]string{"Command", "Flag", "Value", "Source"}, rows)
+	if err != nil {
+		return err
+	}
+	fmt.Println(table.ToFormattedTable())
+	return nil
+}
@@ -0,0 +1,32 @@
+package swagger
+
+import (
+	"github.com/mattfenwick/kubectl-schema/pkg/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func RunCLITests() {
+	Describe("CLI", func() {
+		execute := func(args ...string) error {
+			root := SetupRootSchemaCommand()
+			root.SetArgs(args)
+			_, err := ExecuteRootSchemaCommand(root)
+			return err
+		}
+
+		It("exits with the usage code for unknown commands, arguments and flags", func() {
+			for _, args := range [][]string{
+				{"nope"},
+				{"stray", "arguments"},
+				{"resources", "extra"},
+				{"resources", "--no-such-flag"},
+			} {
+				err := execute(args...)
+				Expect(err).NotTo(Succeed(), "%+v", args)
+				Expect(utils.ExitCode(err)).To(Equal(utils.ExitCodeUsage), "%+v", args)
+			}
+			Expect(execute("nope")).To(MatchError(ContainSubstring(`unknown command "nope" for "schema"`)))
+		})
+	})
+}
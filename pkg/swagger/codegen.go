@@ -0,0 +1,371 @@
+package swagger
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mattfenwick/collections/pkg/file"
+	"github.com/mattfenwick/collections/pkg/set"
+	"github.com/mattfenwick/collections/pkg/slice"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/maps"
+)
+
+type ExportCodeArgs struct {
+	KubeVersion string
+	ApiVersions []string
+	Resources   []string
+	Output      string
+	Package     string
+	CRDs        []string
+	// ExcludeApiVersions and ExcludeResources are patterns to leave out, even if they're included
+	ExcludeApiVersions []string
+	ExcludeResources   []string
+}
+
+func RunExportTypeScript(ctx context.Context, args *ExportCodeArgs) error {
+	return runExportCode(ctx, args, &typeScriptLanguage{})
+}
+
+func RunExportCue(ctx context.Context, args *ExportCodeArgs) error {
+	return runExportCode(ctx, args, &cueLanguage{Package: args.Package})
+}
+
+func runExportCode(ctx context.Context, args *ExportCodeArgs, language CodeLanguage) error {
+	allowApiVersion, err := apiVersionFilter(args.ApiVersions, args.ExcludeApiVersions)
+	if err != nil {
+		return err
+	}
+	resources, err := newResourceFilter(args.Resources, args.ExcludeResources)
+	if err != nil {
+		return err
+	}
+
+	kubeVersion, err := ParseKubeVersion(args.KubeVersion)
+	if err != nil {
+		return err
+	}
+	spec, err := (&SpecSource{CRDPaths: args.CRDs}).Read(ctx, kubeVersion)
+	if err != nil {
+		return err
+	}
+	definitions, err := spec.ResolveDefinitions()
+	if err != nil {
+		return err
+	}
+	allowResource, err := resolveResourceFilter(resources, spec)
+	if err != nil {
+		return err
+	}
+
+	var roots []string
+	for _, name := range slice.Sort(maps.Keys(spec.Definitions)) {
+		if len(spec.Definitions[name].XKubernetesGroupVersionKind) == 0 {
+			continue
+		}
+		gvk, err := ParseGVK(name)
+		if err != nil {
+			return err
+		}
+		if allowApiVersion(gvk.GroupVersion()) && allowResource(gvk.Kind, spec.Definitions[name].XKubernetesGroupVersionKind) {
+			roots = append(roots, name)
+		}
+	}
+
+	code, err := GenerateCode(language, args.KubeVersion, definitions, roots)
+	if err != nil {
+		return err
+	}
+	if args.Output == "" {
+		fmt.Print(code)
+		return nil
+	}
+	return errors.Wrapf(file.WriteString(args.Output, code, 0644), "unable to write %s", args.Output)
+}
+
+// CodeLanguage knows how to spell types in a target language
+type CodeLanguage interface {
+	Header(kubeVersion string) string
+	Indent() string
+	Declaration(name string, resolved *ResolvedType, body string) string
+	Reference(name string) string
+	Primitive(primitive string, format string) (string, error)
+	Array(item string) string
+	Map(value string) string
+	OpenObject() string
+	Field(name string, required bool, fieldType string) string
+	// OpenField allows fields other than the ones which are listed
+	OpenField() string
+	Unknown() string
+}
+
+// GenerateCode declares a named type for each root definition, along with every definition which
+// they refer to, each of which is declared once and referred to by name.  Everything else is
+// inlined.  Output is sorted so that it's stable.
+func GenerateCode(language CodeLanguage, kubeVersion string, definitions map[string]*ResolvedType, roots []string) (string, error) {
+	generator := &codeGenerator{
+		language:    language,
+		definitions: definitions,
+		declared:    set.NewSet[string](nil),
+	}
+	for _, name := range roots {
+		generator.enqueue(name)
+	}
+
+	declarations := []string{language.Header(kubeVersion)}
+	for len(generator.pending) > 0 {
+		name := generator.pending[0]
+		generator.pending = generator.pending[1:]
+		resolved, ok := definitions[name]
+		if !ok {
+			return "", errors.Errorf("unable to find definition for %s", name)
+		}
+		body, err := generator.renderShape(resolved, 0)
+		if err != nil {
+			return "", errors.Wrapf(err, "in definition %s", name)
+		}
+		declarations = append(declarations, codeComment(language, resolved.Description, 0)+language.Declaration(CodeTypeName(name), resolved, body))
+	}
+	return strings.Join(declarations, "\n\n") + "\n", nil
+}
+
+type codeGenerator struct {
+	language    CodeLanguage
+	definitions map[string]*ResolvedType
+	declared    *set.Set[string]
+	pending     []string
+}
+
+func (g *codeGenerator) enqueue(name string) {
+	if !g.declared.Contains(name) {
+		g.declared.Add(name)
+		g.pending = append(g.pending, name)
+	}
+}
+
+// render refers to definitions by name, and spells out everything else
+func (g *codeGenerator) render(resolved *ResolvedType, depth int) (string, error) {
+	name := resolved.Definition
+	if name == "" {
+		name = resolved.Circular
+	}
+	if name != "" {
+		g.enqueue(name)
+		return g.language.Reference(CodeTypeName(name)), nil
+	}
+	return g.renderShape(resolved, depth)
+}
+
+func (g *codeGenerator) renderShape(resolved *ResolvedType, depth int) (string, error) {
+	if resolved.Primitive != "" {
+		return g.language.Primitive(resolved.Primitive, resolved.Format)
+	} else if resolved.Array != nil {
+		item, err := g.render(resolved.Array, depth)
+		if err != nil {
+			return "", err
+		}
+		return g.language.Array(item), nil
+	} else if resolved.Object != nil {
+		obj := resolved.Object
+		if len(obj.Properties) == 0 {
+			if obj.AdditionalProperties == nil {
+				return g.language.OpenObject(), nil
+			}
+			value, err := g.render(obj.AdditionalProperties, depth)
+			if err != nil {
+				return "", err
+			}
+			return g.language.Map(value), nil
+		}
+		indent := strings.Repeat(g.language.Indent(), depth+1)
+		lines := []string{"{"}
+		for _, field := range slice.Sort(maps.Keys(obj.Properties)) {
+			prop := obj.Properties[field]
+			fieldType, err := g.render(prop, depth+1)
+			if err != nil {
+				return "", errors.Wrapf(err, "at %s", field)
+			}
+			lines = append(lines, codeComment(g.language, prop.Description, depth+1)+
+				indent+g.language.Field(field, obj.IsRequired(field), fieldType))
+		}
+		// the type of additional properties would have to fit the listed fields too, so it's left out
+		if obj.AdditionalProperties != nil || resolved.Constraints.preservesUnknownFields() {
+			lines = append(lines, indent+g.language.OpenField())
+		}
+		lines = append(lines, strings.Repeat(g.language.Indent(), depth)+"}")
+		return strings.Join(lines, "\n"), nil
+	}
+	// empty types, and types without any shape, allow anything
+	return g.language.Unknown(), nil
+}
+
+func codeComment(language CodeLanguage, text string, depth int) string {
+	if text == "" {
+		return ""
+	}
+	indent := strings.Repeat(language.Indent(), depth)
+	lines := strings.Split(wrapText(text, 100), "\n")
+	return strings.Join(slice.Map(func(line string) string {
+		return strings.TrimRight(indent+"// "+line, " ")
+	}, lines), "\n") + "\n"
+}
+
+var codeTypeNameSeparator = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// CodeTypeName turns a definition name such as `io.k8s.api.core.v1.Pod` into `IoK8sApiCoreV1Pod`
+func CodeTypeName(definitionName string) string {
+	var name strings.Builder
+	for _, piece := range codeTypeNameSeparator.Split(definitionName, -1) {
+		if piece != "" {
+			name.WriteString(strings.ToUpper(piece[:1]) + piece[1:])
+		}
+	}
+	return name.String()
+}
+
+var (
+	typeScriptIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+	cueIdentifier        = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+)
+
+func quoteUnlessIdentifier(name string, identifier *regexp.Regexp) string {
+	if identifier.MatchString(name) {
+		return name
+	}
+	return strconv.Quote(name)
+}
+
+type typeScriptLanguage struct{}
+
+func (t *typeScriptLanguage) Header(kubeVersion string) string {
+	return fmt.Sprintf("// Code generated by kubectl-schema from kubernetes %s; DO NOT EDIT.", kubeVersion)
+}
+
+func (t *typeScriptLanguage) Indent() string {
+	return "  "
+}
+
+func (t *typeScriptLanguage) Declaration(name string, resolved *ResolvedType, body string) string {
+	if resolved.Object != nil && len(resolved.Object.Properties) > 0 {
+		return fmt.Sprintf("export interface %s %s", name, body)
+	}
+	return fmt.Sprintf("export type %s = %s;", name, body)
+}
+
+func (t *typeScriptLanguage) Reference(name string) string {
+	return name
+}
+
+func (t *typeScriptLanguage) Primitive(primitive string, format string) (string, error) {
+	switch primitive {
+	case "boolean":
+		return "boolean", nil
+	case "integer", "number":
+		return "number", nil
+	case "string":
+		if format == "int-or-string" {
+			return "number | string", nil
+		}
+		return "string", nil
+	default:
+		return "", errors.Errorf("invalid primitive type: %s", primitive)
+	}
+}
+
+func (t *typeScriptLanguage) Array(item string) string {
+	return fmt.Sprintf("Array<%s>", item)
+}
+
+func (t *typeScriptLanguage) Map(value string) string {
+	return fmt.Sprintf("{ [key: string]: %s }", value)
+}
+
+func (t *typeScriptLanguage) OpenObject() string {
+	return t.Map(t.Unknown())
+}
+
+func (t *typeScriptLanguage) Field(name string, required bool, fieldType string) string {
+	optional := "?"
+	if required {
+		optional = ""
+	}
+	return fmt.Sprintf("%s%s: %s;", quoteUnlessIdentifier(name, typeScriptIdentifier), optional, fieldType)
+}
+
+func (t *typeScriptLanguage) OpenField() string {
+	return fmt.Sprintf("[key: string]: %s;", t.Unknown())
+}
+
+func (t *typeScriptLanguage) Unknown() string {
+	return "unknown"
+}
+
+type cueLanguage struct {
+	Package string
+}
+
+func (c *cueLanguage) Header(kubeVersion string) string {
+	return fmt.Sprintf("// Code generated by kubectl-schema from kubernetes %s; DO NOT EDIT.\n\npackage %s", kubeVersion, c.Package)
+}
+
+func (c *cueLanguage) Indent() string {
+	return "\t"
+}
+
+func (c *cueLanguage) Declaration(name string, resolved *ResolvedType, body string) string {
+	return fmt.Sprintf("%s: %s", c.Reference(name), body)
+}
+
+// Reference names a definition; definitions are closed, and start with #
+func (c *cueLanguage) Reference(name string) string {
+	return "#" + name
+}
+
+func (c *cueLanguage) Primitive(primitive string, format string) (string, error) {
+	switch primitive {
+	case "boolean":
+		return "bool", nil
+	case "integer":
+		return "int", nil
+	case "number":
+		return "number", nil
+	case "string":
+		if format == "int-or-string" {
+			return "int | string", nil
+		}
+		return "string", nil
+	default:
+		return "", errors.Errorf("invalid primitive type: %s", primitive)
+	}
+}
+
+func (c *cueLanguage) Array(item string) string {
+	return fmt.Sprintf("[...%s]", item)
+}
+
+func (c *cueLanguage) Map(value string) string {
+	return fmt.Sprintf("{[string]: %s}", value)
+}
+
+func (c *cueLanguage) OpenObject() string {
+	return "{...}"
+}
+
+func (c *cueLanguage) Field(name string, required bool, fieldType string) string {
+	optional := "?"
+	if required {
+		optional = ""
+	}
+	return fmt.Sprintf("%s%s: %s", quoteUnlessIdentifier(name, cueIdentifier), optional, fieldType)
+}
+
+func (c *cueLanguage) OpenField() string {
+	return "..."
+}
+
+func (c *cueLanguage) Unknown() string {
+	return "_"
+}
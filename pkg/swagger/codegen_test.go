@@ -0,0 +1,153 @@
+package swagger
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func RunCodegenTests() {
+	preserveUnknownFields := true
+	spec := &KubeSpec{Definitions: map[string]*SpecType{
+		"com.example.v1.Widget": {
+			Description: "Widget is a thing.",
+			Type:        "object",
+			Properties: map[string]*SpecType{
+				"apiVersion": {Type: "string"},
+				"kind":       {Type: "string"},
+				"spec":       {Ref: "#/definitions/com.example.v1.WidgetSpec", Description: "Desired state."},
+			},
+			XKubernetesGroupVersionKind: []*GVK{{Group: "example.com", Version: "v1", Kind: "Widget"}},
+		},
+		"com.example.v1.WidgetSpec": {
+			Type:     "object",
+			Required: []string{"size"},
+			Properties: map[string]*SpecType{
+				"size":     {Type: "integer", Format: "int32", Description: "How big it is."},
+				"port":     {Type: "string", Format: "int-or-string"},
+				"labels":   {Type: "object", AdditionalProperties: &SpecType{Type: "string"}},
+				"extra":    {Type: "object"},
+				"x-custom": {Type: "boolean"},
+				"parts":    {Type: "array", Items: &SpecType{Ref: "#/definitions/com.example.v1.WidgetSpec"}},
+				"owner":    {Ref: "#/definitions/com.example.v1.Owner"},
+				"settings": {Type: "object", Properties: map[string]*SpecType{"mode": {Type: "string"}}, AdditionalProperties: &SpecType{Type: "string"}},
+				"raw":      {Type: "object", Properties: map[string]*SpecType{"version": {Type: "string"}}, XKubernetesPreserveUnknownFields: &preserveUnknownFields},
+			},
+		},
+		"com.example.v1.Owner": {
+			Type: "object",
+			Properties: map[string]*SpecType{
+				"name":   {Type: "string"},
+				"widget": {Ref: "#/definitions/com.example.v1.WidgetSpec"},
+			},
+		},
+	}}
+	roots := []string{"com.example.v1.Widget"}
+	generate := func(language CodeLanguage) string {
+		definitions, err := spec.ResolveDefinitions()
+		Expect(err).To(Succeed())
+		code, err := GenerateCode(language, "1.30.2", definitions, roots)
+		Expect(err).To(Succeed())
+		return code
+	}
+
+	Describe("Codegen", func() {
+		It("generates TypeScript", func() {
+			Expect(generate(&typeScriptLanguage{})).To(Equal(codegenTypeScript[1:]))
+		})
+		It("generates CUE", func() {
+			Expect(generate(&cueLanguage{Package: "widgets"})).To(Equal(codegenCue[1:]))
+		})
+		It("generates the same declarations every time", func() {
+			for i := 0; i < 20; i++ {
+				Expect(generate(&typeScriptLanguage{})).To(Equal(codegenTypeScript[1:]))
+			}
+		})
+		It("returns errors for missing definitions and unknown primitives", func() {
+			_, err := GenerateCode(&typeScriptLanguage{}, "1.30.2", map[string]*ResolvedType{}, []string{"com.example.v1.Widget"})
+			Expect(err).To(MatchError("unable to find definition for com.example.v1.Widget"))
+			definitions := map[string]*ResolvedType{"com.example.v1.Widget": {Object: &ResolvedObject{
+				Properties: map[string]*ResolvedType{"size": {Primitive: "float"}},
+			}}}
+			_, err = GenerateCode(&cueLanguage{Package: "widgets"}, "1.30.2", definitions, []string{"com.example.v1.Widget"})
+			Expect(err).To(MatchError("in definition com.example.v1.Widget: at size: invalid primitive type: float"))
+		})
+		It("names types after their definitions", func() {
+			Expect(CodeTypeName("io.k8s.api.core.v1.Pod")).To(Equal("IoK8sApiCoreV1Pod"))
+			Expect(CodeTypeName("io.k8s.apimachinery.pkg.api.resource.Quantity")).To(Equal("IoK8sApimachineryPkgApiResourceQuantity"))
+		})
+	})
+}
+
+var codegenTypeScript = `
+// Code generated by kubectl-schema from kubernetes 1.30.2; DO NOT EDIT.
+
+// Widget is a thing.
+export interface ComExampleV1Widget {
+  apiVersion?: string;
+  kind?: string;
+  // Desired state.
+  spec?: ComExampleV1WidgetSpec;
+}
+
+export interface ComExampleV1WidgetSpec {
+  extra?: { [key: string]: unknown };
+  labels?: { [key: string]: string };
+  owner?: ComExampleV1Owner;
+  parts?: Array<ComExampleV1WidgetSpec>;
+  port?: number | string;
+  raw?: {
+    version?: string;
+    [key: string]: unknown;
+  };
+  settings?: {
+    mode?: string;
+    [key: string]: unknown;
+  };
+  // How big it is.
+  size: number;
+  "x-custom"?: boolean;
+}
+
+export interface ComExampleV1Owner {
+  name?: string;
+  widget?: ComExampleV1WidgetSpec;
+}
+`
+
+var codegenCue = `
+// Code generated by kubectl-schema from kubernetes 1.30.2; DO NOT EDIT.
+
+package widgets
+
+// Widget is a thing.
+#ComExampleV1Widget: {
+	apiVersion?: string
+	kind?: string
+	// Desired state.
+	spec?: #ComExampleV1WidgetSpec
+}
+
+#ComExampleV1WidgetSpec: {
+	extra?: {...}
+	labels?: {[string]: string}
+	owner?: #ComExampleV1Owner
+	parts?: [...#ComExampleV1WidgetSpec]
+	port?: int | string
+	raw?: {
+		version?: string
+		...
+	}
+	settings?: {
+		mode?: string
+		...
+	}
+	// How big it is.
+	size: int
+	"x-custom"?: bool
+}
+
+#ComExampleV1Owner: {
+	name?: string
+	widget?: #ComExampleV1WidgetSpec
+}
+`
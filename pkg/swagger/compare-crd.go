@@ -0,0 +1,284 @@
+package swagger
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattfenwick/collections/pkg/set"
+	"github.com/mattfenwick/collections/pkg/slice"
+	"github.com/mattfenwick/kubectl-schema/pkg/diff"
+	"github.com/mattfenwick/kubectl-schema/pkg/utils"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/maps"
+)
+
+// RunCompareCustomResourceDefinitions compares CRD schemas instead of kube versions.  With one file,
+// consecutive versions of each CRD are compared; with two files, each version in the first file
+// is compared to the same version in the second file.
+func RunCompareCustomResourceDefinitions(args *CompareResourceArgs) error {
+	if len(args.CRDFiles) > 2 {
+		return utils.NewUsageError("expected 1 or 2 crd files, found %d: %+v", len(args.CRDFiles), args.CRDFiles)
+	}
+	resources, err := newResourceFilter(args.Resources, args.ExcludeResources)
+	if err != nil {
+		return err
+	}
+	allowVersion, err := nameFilter("crd-version", args.CRDVersions, "", nil)
+	if err != nil {
+		return err
+	}
+	paths, err := newPathFilter(args.Paths, args.ExcludePaths, 0)
+	if err != nil {
+		return err
+	}
+	foundChanges := false
+
+	crds1, err := ReadCustomResourceDefinitions(args.CRDFiles[:1])
+	if err != nil {
+		return err
+	}
+	allowResource, err := resources.Resolve(CustomResourceDefinitionNameIndex(crds1))
+	if err != nil {
+		return err
+	}
+	allowCRD := func(crd *CustomResourceDefinition) bool {
+		gvks := slice.Map(func(v *CustomResourceDefinitionVersion) *GVK { return crd.GVK(v.Name) }, crd.Spec.Versions)
+		return allowResource(crd.Spec.Names.Kind, gvks)
+	}
+	if len(args.CRDFiles) == 1 {
+		for _, crd := range crds1 {
+			if !allowCRD(crd) {
+				continue
+			}
+			versions := slice.Filter(func(v *CustomResourceDefinitionVersion) bool { return allowVersion(v.Name) }, crd.Spec.Versions)
+			for i := 1; i < len(versions); i++ {
+				changed, err := printCustomResourceDefinitionComparison(paths, crd, versions[i-1].Name, crd, versions[i].Name)
+				if err != nil {
+					return err
+				}
+				foundChanges = foundChanges || changed
+			}
+		}
+		return differencesError(args.ExitCode, foundChanges)
+	}
+
+	crds2, err := ReadCustomResourceDefinitions(args.CRDFiles[1:])
+	if err != nil {
+		return err
+	}
+	crds2ByName := map[string]*CustomResourceDefinition{}
+	for _, crd := range crds2 {
+		crds2ByName[crd.Metadata.Name] = crd
+	}
+	for _, crd1 := range crds1 {
+		crd2, ok := crds2ByName[crd1.Metadata.Name]
+		if !ok || !allowCRD(crd1) {
+			continue
+		}
+		versions2 := set.FromSlice(slice.Map(func(v *CustomResourceDefinitionVersion) string { return v.Name }, crd2.Spec.Versions))
+		for _, version := range crd1.Spec.Versions {
+			if versions2.Contains(version.Name) && allowVersion(version.Name) {
+				changed, err := printCustomResourceDefinitionComparison(paths, crd1, version.Name, crd2, version.Name)
+				if err != nil {
+					return err
+				}
+				foundChanges = foundChanges || changed
+			}
+		}
+	}
+	return differencesError(args.ExitCode, foundChanges)
+}
+
+// printCustomResourceDefinitionComparison returns whether any changes under the allowed paths were found
+func printCustomResourceDefinitionComparison(paths *pathFilter, crd1 *CustomResourceDefinition, version1 string, crd2 *CustomResourceDefinition, version2 string) (bool, error) {
+	type1, err := ResolveCustomResourceDefinition(crd1, version1)
+	if err != nil {
+		return false, err
+	}
+	type2, err := ResolveCustomResourceDefinition(crd2, version2)
+	if err != nil {
+		return false, err
+	}
+
+	fmt.Printf("comparing %s: %s@%s vs. %s@%s\n", crd1.Spec.Names.Kind, crd1.Source, version1, crd2.Source, version2)
+	changes := compareAllowedPaths(paths, type1, type2)
+	for _, e := range changes {
+		fmt.Println(FormatResourceChange(e))
+	}
+	breakingChanges := slice.Filter(func(c *BreakingChange) bool { return paths.Allow(c.Path) }, FindBreakingChanges(type1, type2))
+	if len(breakingChanges) > 0 {
+		fmt.Printf("breaking changes:\n")
+		for _, change := range breakingChanges {
+			fmt.Printf("  %-40s    %s\n", strings.Join(change.Path, "."), change.Reason)
+		}
+	}
+	fmt.Println()
+	return len(changes) > 0, nil
+}
+
+// ResolveCustomResourceDefinition resolves a single version of a CRD, without any built-in types
+func ResolveCustomResourceDefinition(crd *CustomResourceDefinition, version string) (*ResolvedType, error) {
+	spec := &KubeSpec{Definitions: map[string]*SpecType{}}
+	for _, v := range crd.Spec.Versions {
+		if v.Name != version {
+			continue
+		}
+		specType, err := crd.SpecType(v)
+		if err != nil {
+			return nil, err
+		}
+		name := crd.DefinitionName(version)
+		spec.Definitions[name] = specType
+		definitions, err := spec.ResolveDefinitions()
+		if err != nil {
+			return nil, err
+		}
+		return definitions[name], nil
+	}
+	return nil, errors.Errorf("crd %s has no version %s", crd.Metadata.Name, version)
+}
+
+type BreakingChange struct {
+	Path   []string
+	Reason string
+}
+
+// FindBreakingChanges looks for changes which could make objects stored under the old schema
+// invalid under the new schema: removed fields, narrowed types, and newly required fields.
+func FindBreakingChanges(old *ResolvedType, new *ResolvedType) []*BreakingChange {
+	var changes []*BreakingChange
+	for _, node := range CompareResolvedResources(old, new).Changes {
+		oldKeyword, isOldKeyword := node.Old.(*ConstraintValue)
+		newKeyword, isNewKeyword := node.New.(*ConstraintValue)
+		if isOldKeyword || isNewKeyword {
+			if reason := constraintBreakingReason(oldKeyword, newKeyword); reason != "" {
+				changes = append(changes, &BreakingChange{Path: node.Path, Reason: reason})
+			}
+			continue
+		}
+		switch node.Kind {
+		case diff.KindRemove:
+			changes = append(changes, &BreakingChange{Path: node.Path, Reason: "field removed"})
+		case diff.KindChange:
+			oldType, newType := node.Old.(*ResolvedType), node.New.(*ResolvedType)
+			if !isWidening(oldType, newType) {
+				changes = append(changes, &BreakingChange{Path: node.Path, Reason: fmt.Sprintf("type narrowed from %s to %s", oldType.TypeName(), newType.TypeName())})
+			}
+		}
+	}
+	findNewlyRequired(old, new, []string{}, &changes)
+	return changes
+}
+
+func isWidening(old *ResolvedType, new *ResolvedType) bool {
+	return new.Empty || (old.Primitive == "integer" && new.Primitive == "number")
+}
+
+// constraintBreakingReason decides whether a keyword change could reject values which used to be valid.
+// Either side may be nil, for keywords which were added or removed.
+func constraintBreakingReason(old *ConstraintValue, new *ConstraintValue) string {
+	switch {
+	case old == nil:
+		switch new.Keyword {
+		case "default", "nullable", "x-kubernetes-int-or-string", "x-kubernetes-preserve-unknown-fields", "x-kubernetes-embedded-resource":
+			return ""
+		case validationsKeyword:
+			return fmt.Sprintf("validation rule added: %s", new.Value.(*ValidationRule).Rule)
+		}
+		return fmt.Sprintf("%s added", new.Keyword)
+	case new == nil:
+		switch old.Keyword {
+		case "nullable", "x-kubernetes-int-or-string", "x-kubernetes-preserve-unknown-fields":
+			return fmt.Sprintf("%s removed", old.Keyword)
+		}
+		return ""
+	}
+	switch old.Keyword {
+	case "minimum", "minLength", "minItems", "minProperties":
+		increased, err := isNumberGreater(new.Value, old.Value)
+		if err != nil {
+			return fmt.Sprintf("%s changed from %s to %s", old.Keyword, old.String(), new.String())
+		} else if increased {
+			return fmt.Sprintf("%s increased from %s to %s", old.Keyword, old.String(), new.String())
+		}
+	case "maximum", "maxLength", "maxItems", "maxProperties":
+		decreased, err := isNumberGreater(old.Value, new.Value)
+		if err != nil {
+			return fmt.Sprintf("%s changed from %s to %s", old.Keyword, old.String(), new.String())
+		} else if decreased {
+			return fmt.Sprintf("%s decreased from %s to %s", old.Keyword, old.String(), new.String())
+		}
+	case validationsKeyword:
+		// same rule, different message or field path
+		return ""
+	case "enum":
+		newValues := set.FromSlice(slice.Map(ConstraintValueString, new.Value.([]interface{})))
+		removed := slice.Filter(func(v string) bool { return !newValues.Contains(v) }, slice.Map(ConstraintValueString, old.Value.([]interface{})))
+		if len(removed) > 0 {
+			return fmt.Sprintf("enum values removed: %s", strings.Join(removed, ", "))
+		}
+	case "default":
+		return ""
+	default:
+		return fmt.Sprintf("%s changed", old.Keyword)
+	}
+	return ""
+}
+
+// isNumberGreater is an error if either value isn't a number -- which shouldn't happen in a valid
+// schema, but CRDs aren't always valid
+func isNumberGreater(a interface{}, b interface{}) (bool, error) {
+	aFloat, err := toFloat(a)
+	if err != nil {
+		return false, err
+	}
+	bFloat, err := toFloat(b)
+	if err != nil {
+		return false, err
+	}
+	return aFloat > bFloat, nil
+}
+
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case interface{ Float64() (float64, error) }:
+		// such as json.Number
+		f, err := v.Float64()
+		return f, errors.Wrapf(err, "unable to parse number %v", v)
+	default:
+		return 0, errors.Errorf("expected number, found %T", value)
+	}
+}
+
+func findNewlyRequired(old *ResolvedType, new *ResolvedType, path []string, changes *[]*BreakingChange) {
+	if old == nil || new == nil {
+		return
+	}
+	if old.Array != nil && new.Array != nil {
+		findNewlyRequired(old.Array, new.Array, slice.Append(path, []string{"[]"}), changes)
+	}
+	if old.Object == nil || new.Object == nil {
+		return
+	}
+	for _, field := range slice.Sort(new.Object.Required) {
+		if !old.Object.IsRequired(field) {
+			*changes = append(*changes, &BreakingChange{Path: slice.Append(path, []string{field}), Reason: "field newly required"})
+		}
+	}
+	for _, field := range slice.Sort(maps.Keys(new.Object.Properties)) {
+		findNewlyRequired(old.Object.Properties[field], new.Object.Properties[field], slice.Append(path, []string{field}), changes)
+	}
+	if old.Object.AdditionalProperties != nil && new.Object.AdditionalProperties != nil {
+		findNewlyRequired(old.Object.AdditionalProperties, new.Object.AdditionalProperties, slice.Append(path, []string{"additionalProperties"}), changes)
+	}
+}
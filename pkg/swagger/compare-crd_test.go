@@ -0,0 +1,76 @@
+package swagger
+
+import (
+	gojson "encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func RunCompareCustomResourceDefinitionTests() {
+	resolveSpecType := func(specType *SpecType) *ResolvedType {
+		definitions, err := (&KubeSpec{Definitions: map[string]*SpecType{"com.example.v1.Widget": specType}}).ResolveDefinitions()
+		Expect(err).To(Succeed())
+		return definitions["com.example.v1.Widget"]
+	}
+	float := func(f float64) *float64 { return &f }
+	integer := func(i int64) *int64 { return &i }
+	widget := func(required []string, properties map[string]*SpecType) *ResolvedType {
+		return resolveSpecType(&SpecType{Type: "object", Required: required, Properties: properties})
+	}
+
+	Describe("Breaking changes", func() {
+		DescribeTable("finds changes which could invalidate stored objects",
+			func(old *ResolvedType, new *ResolvedType, expected []*BreakingChange) {
+				Expect(FindBreakingChanges(old, new)).To(Equal(expected))
+			},
+			Entry("newly required field",
+				widget(nil, map[string]*SpecType{"size": {Type: "integer"}}),
+				widget([]string{"size"}, map[string]*SpecType{"size": {Type: "integer"}}),
+				[]*BreakingChange{{Path: []string{"size"}, Reason: "field newly required"}}),
+			Entry("newly required nested field",
+				widget(nil, map[string]*SpecType{"parts": {Type: "array", Items: &SpecType{Type: "object", Properties: map[string]*SpecType{"name": {Type: "string"}}}}}),
+				widget(nil, map[string]*SpecType{"parts": {Type: "array", Items: &SpecType{Type: "object", Required: []string{"name"}, Properties: map[string]*SpecType{"name": {Type: "string"}}}}}),
+				[]*BreakingChange{{Path: []string{"parts", "[]", "name"}, Reason: "field newly required"}}),
+			Entry("removed field",
+				widget(nil, map[string]*SpecType{"size": {Type: "integer"}, "color": {Type: "string"}}),
+				widget(nil, map[string]*SpecType{"size": {Type: "integer"}}),
+				[]*BreakingChange{{Path: []string{"color"}, Reason: "field removed"}}),
+			Entry("narrowed enum",
+				widget(nil, map[string]*SpecType{"color": {Type: "string", Enum: []interface{}{"red", "green", "blue"}}}),
+				widget(nil, map[string]*SpecType{"color": {Type: "string", Enum: []interface{}{"red", "green"}}}),
+				[]*BreakingChange{{Path: []string{"color", "@enum"}, Reason: `enum values removed: "blue"`}}),
+			Entry("widened enum",
+				widget(nil, map[string]*SpecType{"color": {Type: "string", Enum: []interface{}{"red"}}}),
+				widget(nil, map[string]*SpecType{"color": {Type: "string", Enum: []interface{}{"red", "green"}}}),
+				nil),
+			Entry("tightened minimum and maximum",
+				widget(nil, map[string]*SpecType{"size": {Type: "integer", Minimum: float(1), Maximum: float(10)}}),
+				widget(nil, map[string]*SpecType{"size": {Type: "integer", Minimum: float(2), Maximum: float(5)}}),
+				[]*BreakingChange{
+					{Path: []string{"size", "@maximum"}, Reason: "maximum decreased from 10 to 5"},
+					{Path: []string{"size", "@minimum"}, Reason: "minimum increased from 1 to 2"},
+				}),
+			Entry("tightened maxLength",
+				widget(nil, map[string]*SpecType{"name": {Type: "string", MaxLength: integer(63)}}),
+				widget(nil, map[string]*SpecType{"name": {Type: "string", MaxLength: integer(40)}}),
+				[]*BreakingChange{{Path: []string{"name", "@maxLength"}, Reason: "maxLength decreased from 63 to 40"}}),
+			Entry("loosened minimum and maximum",
+				widget(nil, map[string]*SpecType{"size": {Type: "integer", Minimum: float(2), Maximum: float(5)}}),
+				widget(nil, map[string]*SpecType{"size": {Type: "integer", Minimum: float(1), Maximum: float(10)}}),
+				nil),
+		)
+
+		It("compares numbers of any type, and doesn't panic on values which aren't numbers", func() {
+			Expect(constraintBreakingReason(
+				&ConstraintValue{Keyword: "minimum", Value: gojson.Number("1")},
+				&ConstraintValue{Keyword: "minimum", Value: 2})).To(Equal("minimum increased from 1 to 2"))
+			Expect(constraintBreakingReason(
+				&ConstraintValue{Keyword: "maxItems", Value: int64(3)},
+				&ConstraintValue{Keyword: "maxItems", Value: 4.0})).To(Equal(""))
+			Expect(constraintBreakingReason(
+				&ConstraintValue{Keyword: "maximum", Value: "ten"},
+				&ConstraintValue{Keyword: "maximum", Value: 5.0})).To(Equal(`maximum changed from "ten" to 5`))
+		})
+	})
+}
@@ -1,64 +1,137 @@
 package swagger
 
 import (
+	"context"
 	"fmt"
 	"github.com/mattfenwick/collections/pkg/set"
 	"github.com/mattfenwick/collections/pkg/slice"
-	"github.com/pkg/errors"
+	"github.com/mattfenwick/kubectl-schema/pkg/diff"
+	"github.com/mattfenwick/kubectl-schema/pkg/utils"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/exp/maps"
-	"strings"
 )
 
 type CompareResourceArgs struct {
 	KubeVersions []string
 	ApiVersions  []string
 	Resources    []string
+	Paths        []string
+	// ExcludeApiVersions, ExcludeResources and ExcludePaths are patterns to leave out, even if they're included
+	ExcludeApiVersions []string
+	ExcludeResources   []string
+	ExcludePaths       []string
+	CRDs               []string
+	CRDFiles           []string
+	CRDVersions        []string
+	ExitCode           bool
 }
 
-func RunCompareResource(args *CompareResourceArgs) {
+func RunCompareResource(ctx context.Context, args *CompareResourceArgs) error {
+	if len(args.CRDFiles) > 0 {
+		return RunCompareCustomResourceDefinitions(args)
+	}
+
 	if len(args.KubeVersions) != 2 {
-		panic(errors.Errorf("expected 2 kube versions, found %+v", args.KubeVersions))
+		return utils.NewUsageError("expected 2 kube versions, found %d: %+v", len(args.KubeVersions), args.KubeVersions)
+	}
+	versions, err := ParseKubeVersions(args.KubeVersions)
+	if err != nil {
+		return err
 	}
 
-	allowResource := allower(args.Resources)
-	allowApiVersion := allower(args.ApiVersions)
+	resources, err := newResourceFilter(args.Resources, args.ExcludeResources)
+	if err != nil {
+		return err
+	}
+	allowApiVersion, err := apiVersionFilter(args.ApiVersions, args.ExcludeApiVersions)
+	if err != nil {
+		return err
+	}
+	paths, err := newPathFilter(args.Paths, args.ExcludePaths, 0)
+	if err != nil {
+		return err
+	}
 
-	spec1 := MustReadSwaggerSpecFromGithub(MustVersion(args.KubeVersions[0]))
-	kinds1 := spec1.ResolveStructure()
-	spec2 := MustReadSwaggerSpecFromGithub(MustVersion(args.KubeVersions[1]))
-	kinds2 := spec2.ResolveStructure()
+	source := &SpecSource{CRDPaths: args.CRDs}
+	spec1, err := source.Read(ctx, versions[0])
+	if err != nil {
+		return err
+	}
+	kinds1, err := spec1.ResolveStructure()
+	if err != nil {
+		return err
+	}
+	spec2, err := source.Read(ctx, versions[1])
+	if err != nil {
+		return err
+	}
+	kinds2, err := spec2.ResolveStructure()
+	if err != nil {
+		return err
+	}
+	allowResource1, err := resolveResourceFilter(resources, spec1)
+	if err != nil {
+		return err
+	}
+	allowResource2, err := resolveResourceFilter(resources, spec2)
+	if err != nil {
+		return err
+	}
 
 	typeNames := set.FromSlice(maps.Keys(kinds1)).Union(set.FromSlice(maps.Keys(kinds2)))
+	foundChanges := false
 
 	for _, typeName := range slice.Sort(typeNames.ToSlice()) {
-		if allowResource(typeName) {
+		resolved1 := kinds1[typeName]
+		resolved2 := kinds2[typeName]
+		apiVersions1 := slice.Filter(func(apiVersion string) bool {
+			return allowResource1(typeName, spec1.DefinitionGVKs(apiVersion, typeName))
+		}, slice.Sort(maps.Keys(resolved1)))
+		apiVersions2 := slice.Filter(func(apiVersion string) bool {
+			return allowResource2(typeName, spec2.DefinitionGVKs(apiVersion, typeName))
+		}, slice.Sort(maps.Keys(resolved2)))
+		if len(apiVersions1) > 0 || len(apiVersions2) > 0 {
 			logrus.Debugf("inspecting type %s", typeName)
 		} else {
 			logrus.Debugf("skipping type %s", typeName)
 			continue
 		}
-		resolved1 := kinds1[typeName]
-		resolved2 := kinds2[typeName]
-		logrus.Debugf("api versions for kube %s: %+v", args.KubeVersions[0], maps.Keys(resolved1))
-		logrus.Debugf("api versions for kube %s: %+v", args.KubeVersions[1], maps.Keys(resolved2))
+		logrus.Debugf("api versions for kube %s: %+v", args.KubeVersions[0], apiVersions1)
+		logrus.Debugf("api versions for kube %s: %+v", args.KubeVersions[1], apiVersions2)
 
-		for _, apiVersion1 := range maps.Keys(resolved1) {
+		for _, apiVersion1 := range apiVersions1 {
 			if !allowApiVersion(apiVersion1) {
 				continue
 			}
 			type1 := resolved1[apiVersion1]
-			for _, apiVersion2 := range maps.Keys(resolved2) {
+			for _, apiVersion2 := range apiVersions2 {
 				if !allowApiVersion(apiVersion2) {
 					continue
 				}
 				type2 := resolved2[apiVersion2]
 				fmt.Printf("comparing %s: %s@%s vs. %s@%s\n", typeName, args.KubeVersions[0], apiVersion1, args.KubeVersions[1], apiVersion2)
-				for _, e := range CompareResolvedResources(type1, type2).Changes {
-					fmt.Printf("  %-20s    %+v\n", e.Kind.Short(), strings.Join(e.Path, "."))
+				changes := compareAllowedPaths(paths, type1, type2)
+				for _, e := range changes {
+					fmt.Println(FormatResourceChange(e))
 				}
 				fmt.Println()
+				foundChanges = foundChanges || len(changes) > 0
 			}
 		}
 	}
+	return differencesError(args.ExitCode, foundChanges)
+}
+
+// compareAllowedPaths compares two types, keeping the changes under the allowed paths
+func compareAllowedPaths(paths *pathFilter, type1 *ResolvedType, type2 *ResolvedType) []*diff.Node {
+	return slice.Filter(func(e *diff.Node) bool { return paths.Allow(e.Path) }, CompareResolvedResources(type1, type2).Changes)
+}
+
+// differencesError implements `--exit-code`: like `git diff --exit-code`, finding differences is
+// reported as a failure only when asked for
+func differencesError(exitCode bool, foundChanges bool) error {
+	if exitCode && foundChanges {
+		return utils.ErrDifferencesFound
+	}
+	return nil
 }
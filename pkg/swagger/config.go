@@ -0,0 +1,255 @@
+package swagger
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/mattfenwick/collections/pkg/file"
+	"github.com/mattfenwick/collections/pkg/slice"
+	"github.com/mattfenwick/collections/pkg/yaml"
+	"github.com/mattfenwick/kubectl-schema/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/exp/maps"
+)
+
+const (
+	ConfigEnvVar  = "KUBECTL_SCHEMA_CONFIG"
+	ProfileEnvVar = "KUBECTL_SCHEMA_PROFILE"
+)
+
+// ConfigFile sets defaults for flags, keyed by flag name.  `defaults` apply to every command with
+// a flag of that name; `commands` apply to a single command, keyed by its path without the root
+// (such as `resources` or `export jsonschema`).  A profile can override both.
+//
+// Values are applied in this order, first match wins: flags from the command line, environment
+// variables, the selected profile's command values, the profile's defaults, the file's command
+// values, the file's defaults, and finally compiled-in defaults.
+type ConfigFile struct {
+	Defaults map[string]interface{}            `json:"defaults,omitempty"`
+	Commands map[string]map[string]interface{} `json:"commands,omitempty"`
+	Profiles map[string]*ConfigProfile         `json:"profiles,omitempty"`
+}
+
+type ConfigProfile struct {
+	Defaults map[string]interface{}            `json:"defaults,omitempty"`
+	Commands map[string]map[string]interface{} `json:"commands,omitempty"`
+}
+
+// configAppliedAnnotation marks flags which Apply set, so that they aren't reported as coming from
+// the command line
+const configAppliedAnnotation = "kubectl-schema/config-applied"
+
+// flagEnvVars lists flags which can also be set from the environment
+var flagEnvVars = map[string]string{
+	"spec-url-template": SpecURLTemplateEnvVar,
+}
+
+// GetConfigPath returns where to look for the config file, and whether it was chosen explicitly --
+// in which case it's an error if the file doesn't exist
+func GetConfigPath(configFlag string) (string, bool, error) {
+	if configFlag != "" {
+		return configFlag, true, nil
+	}
+	if configPath, ok := os.LookupEnv(ConfigEnvVar); ok {
+		return configPath, true, nil
+	}
+	dataDir, err := GetSpecsRootDirectory()
+	if err != nil {
+		return "", false, err
+	}
+	return path.Join(dataDir, "config.yaml"), false, nil
+}
+
+// ReadConfigFile returns an empty config if the file doesn't exist and wasn't chosen explicitly
+func ReadConfigFile(configPath string, explicit bool) (*ConfigFile, error) {
+	if !file.Exists(configPath) {
+		if explicit {
+			return nil, utils.NewUsageError("config file %s not found", configPath)
+		}
+		return &ConfigFile{}, nil
+	}
+	config, err := yaml.ParseFileStrict[ConfigFile](configPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read config file %s", configPath)
+	}
+	return config, nil
+}
+
+// Validate checks that the profile exists, and that every command and flag in the file exists
+func (c *ConfigFile) Validate(root *cobra.Command, profile string) error {
+	if profile != "" {
+		if _, ok := c.Profiles[profile]; !ok {
+			return utils.NewUsageError("profile '%s' not found in config file; profiles: %s", profile, strings.Join(slice.Sort(maps.Keys(c.Profiles)), ", "))
+		}
+	}
+	commands := map[string]*cobra.Command{}
+	allFlags := map[string]bool{}
+	for _, command := range configurableCommands(root) {
+		commands[configCommandName(command)] = command
+		visitConfigurableFlags(command, func(flag *pflag.Flag) { allFlags[flag.Name] = true })
+	}
+	check := func(location string, defaults map[string]interface{}, commandValues map[string]map[string]interface{}) error {
+		for _, name := range slice.Sort(maps.Keys(defaults)) {
+			if !allFlags[name] {
+				return utils.NewUsageError("config file: %s.defaults: no command has a flag named '%s'", location, name)
+			}
+		}
+		for _, commandName := range slice.Sort(maps.Keys(commandValues)) {
+			command, ok := commands[commandName]
+			if !ok {
+				return utils.NewUsageError("config file: %s.commands: unknown command '%s'", location, commandName)
+			}
+			for _, name := range slice.Sort(maps.Keys(commandValues[commandName])) {
+				if command.Flags().Lookup(name) == nil && command.InheritedFlags().Lookup(name) == nil {
+					return utils.NewUsageError("config file: %s.commands.%s: unknown flag '%s'", location, commandName, name)
+				}
+			}
+		}
+		return nil
+	}
+	if err := check("top level", c.Defaults, c.Commands); err != nil {
+		return err
+	}
+	for _, name := range slice.Sort(maps.Keys(c.Profiles)) {
+		if err := check("profiles."+name, c.Profiles[name].Defaults, c.Profiles[name].Commands); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConfigValue is the effective value of a flag for a command, and where it came from
+type ConfigValue struct {
+	Command string
+	Flag    string
+	Value   string
+	Source  string
+}
+
+func (c *ConfigFile) lookup(profile string, command string, flag string) (interface{}, string, bool) {
+	if p, ok := c.Profiles[profile]; ok {
+		if value, ok := p.Commands[command][flag]; ok {
+			return value, fmt.Sprintf("profile %s, commands.%s", profile, command), true
+		}
+		if value, ok := p.Defaults[flag]; ok {
+			return value, fmt.Sprintf("profile %s, defaults", profile), true
+		}
+	}
+	if value, ok := c.Commands[command][flag]; ok {
+		return value, fmt.Sprintf("config file, commands.%s", command), true
+	}
+	if value, ok := c.Defaults[flag]; ok {
+		return value, "config file, defaults", true
+	}
+	return nil, "", false
+}
+
+// ResolveFlag finds a flag's effective value
+func (c *ConfigFile) ResolveFlag(command *cobra.Command, flag *pflag.Flag, profile string) *ConfigValue {
+	name := configCommandName(command)
+	out := &ConfigValue{Command: name, Flag: flag.Name}
+	if flag.Changed && !isConfigApplied(flag) {
+		out.Value, out.Source = flag.Value.String(), "command line"
+	} else if envValue, ok := os.LookupEnv(flagEnvVars[flag.Name]); ok {
+		out.Value, out.Source = envValue, "env "+flagEnvVars[flag.Name]
+	} else if value, source, ok := c.lookup(profile, name, flag.Name); ok {
+		out.Value, out.Source = configValueString(value), source
+	} else {
+		out.Value, out.Source = flag.DefValue, "default"
+	}
+	return out
+}
+
+// Apply sets flags which weren't given on the command line from the environment and config file
+func (c *ConfigFile) Apply(command *cobra.Command, profile string) error {
+	var err error
+	command.Flags().VisitAll(func(flag *pflag.Flag) {
+		if err != nil || !isConfigurableFlag(flag) || flag.Changed {
+			return
+		}
+		value := c.ResolveFlag(command, flag, profile)
+		if value.Source == "default" {
+			return
+		}
+		if setErr := command.Flags().Set(flag.Name, value.Value); setErr != nil {
+			err = utils.NewUsageError("invalid value '%s' for --%s from %s: %s", value.Value, flag.Name, value.Source, setErr.Error())
+			return
+		}
+		if flag.Annotations == nil {
+			flag.Annotations = map[string][]string{}
+		}
+		flag.Annotations[configAppliedAnnotation] = []string{value.Source}
+	})
+	return err
+}
+
+func isConfigApplied(flag *pflag.Flag) bool {
+	_, ok := flag.Annotations[configAppliedAnnotation]
+	return ok
+}
+
+// Resolve lists the effective value of every flag of every command
+func (c *ConfigFile) Resolve(root *cobra.Command, profile string) []*ConfigValue {
+	var values []*ConfigValue
+	for _, command := range configurableCommands(root) {
+		visitConfigurableFlags(command, func(flag *pflag.Flag) {
+			values = append(values, c.ResolveFlag(command, flag, profile))
+		})
+	}
+	return values
+}
+
+func configValueString(value interface{}) string {
+	if values, ok := value.([]interface{}); ok {
+		return strings.Join(slice.Map(func(v interface{}) string { return fmt.Sprintf("%v", v) }, values), ",")
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// configCommandName is the command path without the root, or "" for the root
+func configCommandName(command *cobra.Command) string {
+	if !command.HasParent() {
+		return ""
+	}
+	return strings.TrimPrefix(command.CommandPath(), command.Root().Name()+" ")
+}
+
+// configurableCommands is the root, for global flags, followed by every runnable subcommand
+func configurableCommands(root *cobra.Command) []*cobra.Command {
+	out := []*cobra.Command{root}
+	var visit func(*cobra.Command)
+	visit = func(command *cobra.Command) {
+		for _, child := range command.Commands() {
+			if child.Name() == "help" || child.Name() == "completion" {
+				continue
+			}
+			if child.Runnable() {
+				out = append(out, child)
+			}
+			visit(child)
+		}
+	}
+	visit(root)
+	return out
+}
+
+// visitConfigurableFlags visits a command's own flags; global flags are visited for the root only
+func visitConfigurableFlags(command *cobra.Command, visit func(*pflag.Flag)) {
+	flags := command.LocalNonPersistentFlags()
+	if !command.HasParent() {
+		flags = command.PersistentFlags()
+	}
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if isConfigurableFlag(flag) {
+			visit(flag)
+		}
+	})
+}
+
+func isConfigurableFlag(flag *pflag.Flag) bool {
+	return flag.Name != "help" && flag.Name != "config" && flag.Name != "profile"
+}
@@ -0,0 +1,139 @@
+package swagger
+
+import (
+	"github.com/mattfenwick/collections/pkg/file"
+	"github.com/mattfenwick/kubectl-schema/pkg/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/cobra"
+)
+
+func RunConfigTests() {
+	configYaml := `
+defaults:
+  format: json
+  kube-version: [1.29.6, 1.30.2]
+commands:
+  resources:
+    format: markdown
+profiles:
+  prod:
+    defaults:
+      kube-version: [1.28.11]
+    commands:
+      explain:
+        format: yaml
+`
+	readConfig := func(contents string) *ConfigFile {
+		configPath := GinkgoT().TempDir() + "/config.yaml"
+		Expect(file.Write(configPath, []byte(contents), 0644)).To(Succeed())
+		config, err := ReadConfigFile(configPath, true)
+		Expect(err).To(Succeed())
+		return config
+	}
+	setupCommands := func() (*cobra.Command, *cobra.Command, *cobra.Command) {
+		root := &cobra.Command{Use: "schema"}
+		root.PersistentFlags().String("spec-url-template", DefaultSpecURLTemplate, "")
+		noop := func(cmd *cobra.Command, args []string) {}
+		resources := &cobra.Command{Use: "resources", Run: noop}
+		resources.Flags().String("format", "table", "")
+		resources.Flags().StringSlice("kube-version", []string{"1.18.20"}, "")
+		explain := &cobra.Command{Use: "explain", Run: noop}
+		explain.Flags().String("format", "condensed", "")
+		explain.Flags().StringSlice("kube-version", []string{"1.18.20"}, "")
+		root.AddCommand(resources, explain)
+		return root, resources, explain
+	}
+	resolve := func(config *ConfigFile, command *cobra.Command, flag string, profile string) *ConfigValue {
+		return config.ResolveFlag(command, command.Flags().Lookup(flag), profile)
+	}
+
+	Describe("ConfigFile", func() {
+		It("prefers command values to defaults, and profiles to the rest of the file", func() {
+			config := readConfig(configYaml)
+			root, resources, explain := setupCommands()
+			Expect(config.Validate(root, "prod")).To(Succeed())
+
+			Expect(resolve(config, resources, "format", "")).To(Equal(&ConfigValue{Command: "resources", Flag: "format", Value: "markdown", Source: "config file, commands.resources"}))
+			Expect(resolve(config, explain, "format", "")).To(Equal(&ConfigValue{Command: "explain", Flag: "format", Value: "json", Source: "config file, defaults"}))
+			Expect(resolve(config, explain, "kube-version", "")).To(Equal(&ConfigValue{Command: "explain", Flag: "kube-version", Value: "1.29.6,1.30.2", Source: "config file, defaults"}))
+
+			Expect(resolve(config, explain, "format", "prod")).To(Equal(&ConfigValue{Command: "explain", Flag: "format", Value: "yaml", Source: "profile prod, commands.explain"}))
+			Expect(resolve(config, resources, "kube-version", "prod")).To(Equal(&ConfigValue{Command: "resources", Flag: "kube-version", Value: "1.28.11", Source: "profile prod, defaults"}))
+		})
+
+		It("prefers the command line to the config file", func() {
+			config := readConfig(configYaml)
+			_, resources, _ := setupCommands()
+			Expect(resources.Flags().Set("format", "json")).To(Succeed())
+
+			Expect(resolve(config, resources, "format", "prod")).To(Equal(&ConfigValue{Command: "resources", Flag: "format", Value: "json", Source: "command line"}))
+		})
+
+		It("sets flags which weren't given on the command line", func() {
+			config := readConfig(configYaml)
+			_, resources, _ := setupCommands()
+			Expect(resources.Flags().Set("format", "table")).To(Succeed())
+
+			Expect(config.Apply(resources, "prod")).To(Succeed())
+			format, err := resources.Flags().GetString("format")
+			Expect(err).To(Succeed())
+			Expect(format).To(Equal("table"))
+			versions, err := resources.Flags().GetStringSlice("kube-version")
+			Expect(err).To(Succeed())
+			Expect(versions).To(Equal([]string{"1.28.11"}))
+		})
+
+		It("reports flags it set by where their values came from, not as the command line", func() {
+			config := readConfig("defaults:\n  spec-url-template: https://mirror.example.com/{version}.json\n  kube-version: [1.29.6, 1.30.2]\n")
+			root, resources, _ := setupCommands()
+			Expect(root.ParseFlags(nil)).To(Succeed())
+			Expect(resources.ParseFlags([]string{"--format", "yaml"})).To(Succeed())
+
+			Expect(config.Apply(root, "")).To(Succeed())
+			Expect(config.Apply(resources, "")).To(Succeed())
+			Expect(resolve(config, resources, "format", "")).To(Equal(&ConfigValue{Command: "resources", Flag: "format", Value: "yaml", Source: "command line"}))
+			Expect(resolve(config, resources, "kube-version", "")).To(Equal(&ConfigValue{Command: "resources", Flag: "kube-version", Value: "1.29.6,1.30.2", Source: "config file, defaults"}))
+			Expect(config.ResolveFlag(root, root.PersistentFlags().Lookup("spec-url-template"), "")).To(Equal(&ConfigValue{Command: "", Flag: "spec-url-template", Value: "https://mirror.example.com/{version}.json", Source: "config file, defaults"}))
+		})
+
+		It("lists every flag of every command", func() {
+			config := readConfig(configYaml)
+			root, _, _ := setupCommands()
+
+			var flags []string
+			for _, value := range config.Resolve(root, "") {
+				flags = append(flags, value.Command+" --"+value.Flag)
+			}
+			Expect(flags).To(Equal([]string{
+				" --spec-url-template",
+				"explain --format",
+				"explain --kube-version",
+				"resources --format",
+				"resources --kube-version",
+			}))
+		})
+
+		It("rejects unknown profiles, commands and flags", func() {
+			root, _, _ := setupCommands()
+
+			err := readConfig(configYaml).Validate(root, "staging")
+			Expect(utils.ExitCode(err)).To(Equal(utils.ExitCodeUsage))
+			Expect(readConfig("commands:\n  sample:\n    count: 3\n").Validate(root, "")).To(MatchError(ContainSubstring("unknown command 'sample'")))
+			err = readConfig("commands:\n  explain:\n    group-by: kind\n").Validate(root, "")
+			Expect(utils.ExitCode(err)).To(Equal(utils.ExitCodeUsage))
+			Expect(readConfig("commands:\n  explain:\n    group-by: kind\n").Validate(root, "")).To(MatchError(ContainSubstring("unknown flag 'group-by'")))
+			Expect(readConfig("profiles:\n  prod:\n    defaults:\n      colour: blue\n").Validate(root, "")).To(MatchError(ContainSubstring("no command has a flag named 'colour'")))
+		})
+
+		It("requires config files which were chosen explicitly to exist", func() {
+			missing := GinkgoT().TempDir() + "/missing.yaml"
+
+			_, err := ReadConfigFile(missing, true)
+			Expect(utils.ExitCode(err)).To(Equal(utils.ExitCodeUsage))
+			config, err := ReadConfigFile(missing, false)
+			Expect(err).To(Succeed())
+			Expect(config).To(Equal(&ConfigFile{}))
+		})
+	})
+}
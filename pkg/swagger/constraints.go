@@ -0,0 +1,245 @@
+package swagger
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattfenwick/collections/pkg/json"
+	"github.com/mattfenwick/collections/pkg/slice"
+	"golang.org/x/exp/maps"
+)
+
+const (
+	validationsKeyword = "x-kubernetes-validations"
+)
+
+// Constraints holds the schema keywords which restrict or annotate values without
+// changing the shape of a type
+type Constraints struct {
+	Enum             []interface{}
+	Default          interface{}
+	Pattern          string
+	Minimum          *float64
+	Maximum          *float64
+	ExclusiveMinimum *bool
+	ExclusiveMaximum *bool
+	MultipleOf       *float64
+	MinLength        *int64
+	MaxLength        *int64
+	MinItems         *int64
+	MaxItems         *int64
+	MinProperties    *int64
+	MaxProperties    *int64
+	UniqueItems      *bool
+	Nullable         *bool
+	AllOf            []*SpecType
+	AnyOf            []*SpecType
+	OneOf            []*SpecType
+	Not              *SpecType
+
+	IntOrString           *bool
+	PreserveUnknownFields *bool
+	EmbeddedResource      *bool
+	Validations           []*ValidationRule
+}
+
+// Constraints pulls out the keywords which don't affect the shape of a type.  Returns nil if there aren't any.
+// `allOf` is only kept when it isn't used to wrap a $ref.
+func (s *SpecType) Constraints() *Constraints {
+	c := &Constraints{
+		Enum:                  s.Enum,
+		Default:               s.Default,
+		Pattern:               s.Pattern,
+		Minimum:               s.Minimum,
+		Maximum:               s.Maximum,
+		ExclusiveMinimum:      s.ExclusiveMinimum,
+		ExclusiveMaximum:      s.ExclusiveMaximum,
+		MultipleOf:            s.MultipleOf,
+		MinLength:             s.MinLength,
+		MaxLength:             s.MaxLength,
+		MinItems:              s.MinItems,
+		MaxItems:              s.MaxItems,
+		MinProperties:         s.MinProperties,
+		MaxProperties:         s.MaxProperties,
+		UniqueItems:           s.UniqueItems,
+		Nullable:              s.Nullable,
+		AnyOf:                 s.AnyOf,
+		OneOf:                 s.OneOf,
+		Not:                   s.Not,
+		IntOrString:           s.XKubernetesIntOrString,
+		PreserveUnknownFields: s.XKubernetesPreserveUnknownFields,
+		EmbeddedResource:      s.XKubernetesEmbeddedResource,
+		Validations:           s.XKubernetesValidations,
+	}
+	if s.Type != "" {
+		c.AllOf = s.AllOf
+	}
+	if len(c.Keywords()) == 0 {
+		return nil
+	}
+	return c
+}
+
+// Merge combines the constraints of a referenced type with those found next to the $ref, which
+// take precedence.  Validation rules from both apply, so they're kept.
+func (c *Constraints) Merge(field *Constraints) *Constraints {
+	if c == nil {
+		return field
+	} else if field == nil {
+		return c
+	}
+	merged := *c
+	if len(field.Enum) > 0 {
+		merged.Enum = field.Enum
+	}
+	if field.Default != nil {
+		merged.Default = field.Default
+	}
+	if field.Pattern != "" {
+		merged.Pattern = field.Pattern
+	}
+	merged.Minimum = firstNonNil(field.Minimum, c.Minimum)
+	merged.Maximum = firstNonNil(field.Maximum, c.Maximum)
+	merged.ExclusiveMinimum = firstNonNil(field.ExclusiveMinimum, c.ExclusiveMinimum)
+	merged.ExclusiveMaximum = firstNonNil(field.ExclusiveMaximum, c.ExclusiveMaximum)
+	merged.MultipleOf = firstNonNil(field.MultipleOf, c.MultipleOf)
+	merged.MinLength = firstNonNil(field.MinLength, c.MinLength)
+	merged.MaxLength = firstNonNil(field.MaxLength, c.MaxLength)
+	merged.MinItems = firstNonNil(field.MinItems, c.MinItems)
+	merged.MaxItems = firstNonNil(field.MaxItems, c.MaxItems)
+	merged.MinProperties = firstNonNil(field.MinProperties, c.MinProperties)
+	merged.MaxProperties = firstNonNil(field.MaxProperties, c.MaxProperties)
+	merged.UniqueItems = firstNonNil(field.UniqueItems, c.UniqueItems)
+	merged.Nullable = firstNonNil(field.Nullable, c.Nullable)
+	if len(field.AllOf) > 0 {
+		merged.AllOf = field.AllOf
+	}
+	if len(field.AnyOf) > 0 {
+		merged.AnyOf = field.AnyOf
+	}
+	if len(field.OneOf) > 0 {
+		merged.OneOf = field.OneOf
+	}
+	if field.Not != nil {
+		merged.Not = field.Not
+	}
+	merged.IntOrString = firstNonNil(field.IntOrString, c.IntOrString)
+	merged.PreserveUnknownFields = firstNonNil(field.PreserveUnknownFields, c.PreserveUnknownFields)
+	merged.EmbeddedResource = firstNonNil(field.EmbeddedResource, c.EmbeddedResource)
+	if len(field.Validations) > 0 {
+		merged.Validations = slice.Append(c.Validations, field.Validations)
+	}
+	return &merged
+}
+
+func firstNonNil[A any](values ...*A) *A {
+	for _, value := range values {
+		if value != nil {
+			return value
+		}
+	}
+	return nil
+}
+
+// Keywords maps each keyword which is set to its value, using the names from the schema
+func (c *Constraints) Keywords() map[string]interface{} {
+	keywords := map[string]interface{}{}
+	if c == nil {
+		return keywords
+	}
+	set := func(name string, value interface{}, ok bool) {
+		if ok {
+			keywords[name] = value
+		}
+	}
+	set("enum", c.Enum, len(c.Enum) > 0)
+	set("default", c.Default, c.Default != nil)
+	set("pattern", c.Pattern, c.Pattern != "")
+	setIfPresent(keywords, "minimum", c.Minimum)
+	setIfPresent(keywords, "maximum", c.Maximum)
+	setIfPresent(keywords, "exclusiveMinimum", c.ExclusiveMinimum)
+	setIfPresent(keywords, "exclusiveMaximum", c.ExclusiveMaximum)
+	setIfPresent(keywords, "multipleOf", c.MultipleOf)
+	setIfPresent(keywords, "minLength", c.MinLength)
+	setIfPresent(keywords, "maxLength", c.MaxLength)
+	setIfPresent(keywords, "minItems", c.MinItems)
+	setIfPresent(keywords, "maxItems", c.MaxItems)
+	setIfPresent(keywords, "minProperties", c.MinProperties)
+	setIfPresent(keywords, "maxProperties", c.MaxProperties)
+	setIfPresent(keywords, "uniqueItems", c.UniqueItems)
+	setIfPresent(keywords, "nullable", c.Nullable)
+	set("allOf", c.AllOf, len(c.AllOf) > 0)
+	set("anyOf", c.AnyOf, len(c.AnyOf) > 0)
+	set("oneOf", c.OneOf, len(c.OneOf) > 0)
+	set("not", c.Not, c.Not != nil)
+	setIfPresent(keywords, "x-kubernetes-int-or-string", c.IntOrString)
+	setIfPresent(keywords, "x-kubernetes-preserve-unknown-fields", c.PreserveUnknownFields)
+	setIfPresent(keywords, "x-kubernetes-embedded-resource", c.EmbeddedResource)
+	set(validationsKeyword, c.Validations, len(c.Validations) > 0)
+	return keywords
+}
+
+// Strings renders each keyword as `name=value`, sorted by name.  Validation rules are left out,
+// since they're too long to share a line: see ValidationRules.
+func (c *Constraints) Strings() []string {
+	keywords := c.Keywords()
+	delete(keywords, validationsKeyword)
+	return slice.Map(func(name string) string {
+		return fmt.Sprintf("%s=%s", name, ConstraintValueString(keywords[name]))
+	}, slice.Sort(maps.Keys(keywords)))
+}
+
+func (c *Constraints) minItems() *int64 {
+	if c == nil {
+		return nil
+	}
+	return c.MinItems
+}
+
+func (c *Constraints) maxItems() *int64 {
+	if c == nil {
+		return nil
+	}
+	return c.MaxItems
+}
+
+func (c *Constraints) minProperties() *int64 {
+	if c == nil {
+		return nil
+	}
+	return c.MinProperties
+}
+
+func (c *Constraints) maxProperties() *int64 {
+	if c == nil {
+		return nil
+	}
+	return c.MaxProperties
+}
+
+func (c *Constraints) preservesUnknownFields() bool {
+	return c != nil && isTrue(c.PreserveUnknownFields)
+}
+
+func (c *Constraints) ValidationRules() []*ValidationRule {
+	if c == nil {
+		return nil
+	}
+	return c.Validations
+}
+
+// ConstraintValueString renders a keyword's value as compact json
+func ConstraintValueString(value interface{}) string {
+	bytes, err := json.MarshalWithOptions(value, &json.MarshalOptions{EscapeHTML: false})
+	if err != nil {
+		// keyword values come from parsed json, so this can't happen in practice
+		return fmt.Sprintf("%+v", value)
+	}
+	return strings.TrimSpace(string(bytes))
+}
+
+func setIfPresent[A any](keywords map[string]interface{}, name string, value *A) {
+	if value != nil {
+		keywords[name] = *value
+	}
+}
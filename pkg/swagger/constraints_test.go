@@ -0,0 +1,166 @@
+package swagger
+
+import (
+	"strings"
+
+	"github.com/mattfenwick/collections/pkg/json"
+	"github.com/mattfenwick/collections/pkg/slice"
+	"github.com/mattfenwick/kubectl-schema/pkg/diff"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func RunConstraintsTests() {
+	Describe("Constraints", func() {
+		parseSpec := func(definitions string) *KubeSpec {
+			spec, err := json.ParseString[KubeSpec](definitions)
+			if err != nil {
+				panic(err)
+			}
+			return spec
+		}
+		resolveThing := func(definitions string) *ResolvedType {
+			resolved, err := parseSpec(definitions).ResolveDefinitions()
+			Expect(err).To(Succeed())
+			return resolved["io.example.v1.Thing"]
+		}
+
+		It("resolves allOf wrappers, int-or-string and unknown types", func() {
+			resolved := resolveThing(constraintsOldSpec)
+			Expect(resolved.Object.Properties["child"].Object.Properties["name"].Primitive).To(Equal("string"))
+			Expect(resolved.Object.Properties["child"].Description).To(Equal("the child"))
+			Expect(resolved.Object.Properties["child"].Constraints.Default).To(Equal(map[string]interface{}{}))
+			Expect(resolved.Object.Properties["port"].Primitive).To(Equal("string"))
+			Expect(resolved.Object.Properties["port"].Format).To(Equal("int-or-string"))
+			Expect(resolved.Object.Properties["weird"].Empty).To(BeTrue())
+		})
+
+		It("renders keywords", func() {
+			resolved := resolveThing(constraintsOldSpec)
+			Expect(resolved.Object.Properties["count"].Constraints.Strings()).To(BeEmpty())
+			Expect(slice.Map(func(r *ValidationRule) string { return r.String() }, resolved.Object.Properties["count"].Constraints.ValidationRules())).
+				To(Equal([]string{"self >= 0  # must not be negative"}))
+			Expect(resolved.Object.Properties["mode"].Constraints.Strings()).To(Equal([]string{`enum=["a","b"]`, `maxLength=5`}))
+			Expect(resolved.Object.Properties["weird"].Constraints).To(BeNil())
+		})
+
+		It("merges constraints next to a $ref with the referenced type's, preferring the field's", func() {
+			resolved := resolveThing(constraintsRefSpec("63"))
+			Expect(resolved.Object.Properties["owner"].Constraints.Strings()).To(Equal([]string{`maxLength=63`, `minLength=1`, `pattern="^[a-z]+$"`}))
+			Expect(resolved.Object.Properties["owner"].Description).To(Equal("the owner"))
+			Expect(resolved.Object.Properties["alias"].Constraints.Strings()).To(Equal([]string{`maxLength=10`, `pattern="^[a-z]+$"`}))
+			Expect(slice.Map(func(r *ValidationRule) string { return r.Rule }, resolved.Object.Properties["alias"].Constraints.ValidationRules())).
+				To(Equal([]string{"self != 'root'", "self != 'admin'"}))
+
+			changes := slice.Map(func(e *diff.Node) string { return e.Kind.Short() + " " + strings.Join(e.Path, ".") },
+				CompareResolvedResources(resolved, resolveThing(constraintsRefSpec("40"))).Changes)
+			Expect(changes).To(Equal([]string{"<> owner.@maxLength"}))
+		})
+
+		It("merges allOf objects, with each required field listed once, in order", func() {
+			resolved := resolveThing(`{"definitions": {
+  "io.example.v1.Thing": {"allOf": [
+    {"type": "object", "required": ["name", "size"], "properties": {"name": {"type": "string"}, "size": {"type": "integer"}}},
+    {"type": "object", "required": ["size", "color"], "properties": {"color": {"type": "string"}}}
+  ]}
+}}`)
+			Expect(resolved.Object.Required).To(Equal([]string{"color", "name", "size"}))
+			Expect(resolved.Object.Properties).To(HaveLen(3))
+		})
+
+		It("merges exclusive flags and booleans from the field whenever it sets them", func() {
+			boolean := func(b bool) *bool { return &b }
+			float := func(f float64) *float64 { return &f }
+			referenced := &Constraints{Minimum: float(0), ExclusiveMinimum: boolean(true), Maximum: float(10), UniqueItems: boolean(true), Nullable: boolean(true)}
+			field := &Constraints{ExclusiveMinimum: boolean(false), Maximum: float(5), ExclusiveMaximum: boolean(true), Nullable: boolean(false)}
+			Expect(referenced.Merge(field).Strings()).To(Equal([]string{
+				"exclusiveMaximum=true",
+				"exclusiveMinimum=false",
+				"maximum=5",
+				"minimum=0",
+				"nullable=false",
+				"uniqueItems=true",
+			}))
+		})
+
+		It("treats types without a shape as empty", func() {
+			shapeless := &ResolvedType{}
+			Expect(shapeless.TypeName()).To(Equal("?"))
+			Expect(shapeless.Paths([]string{"spec"})).To(HaveLen(1))
+			Expect(CompareResolvedResources(shapeless, &ResolvedType{Empty: true}).Changes).To(BeEmpty())
+			changes := slice.Map(func(e *diff.Node) string { return e.Kind.Short() + " " + strings.Join(e.Path, ".") },
+				CompareResolvedResources(shapeless, &ResolvedType{Primitive: "string"}).Changes)
+			Expect(changes).To(Equal([]string{"<> "}))
+		})
+
+		It("diffs keywords and finds breaking keyword changes", func() {
+			old := resolveThing(constraintsOldSpec)
+			new := resolveThing(constraintsNewSpec)
+			changes := slice.Map(func(e *diff.Node) string { return e.Kind.Short() + " " + strings.Join(e.Path, ".") }, CompareResolvedResources(old, new).Changes)
+			Expect(changes).To(Equal([]string{
+				"<> count.@x-kubernetes-validations",
+				"+ count.@x-kubernetes-validations",
+				"+ count.@minimum",
+				"<> mode.@enum",
+				"- mode.@maxLength",
+			}))
+			breaking := slice.Map(func(c *BreakingChange) string { return strings.Join(c.Path, ".") + ": " + c.Reason }, FindBreakingChanges(old, new))
+			Expect(breaking).To(Equal([]string{
+				"count.@x-kubernetes-validations: validation rule added: self < 100",
+				"count.@minimum: minimum added",
+				`mode.@enum: enum values removed: "b"`,
+			}))
+		})
+	})
+}
+
+func constraintsRefSpec(nameMaxLength string) string {
+	return `{
+  "definitions": {
+    "io.example.v1.Name": {"type": "string", "pattern": "^[a-z]+$", "maxLength": ` + nameMaxLength + `, "x-kubernetes-validations": [{"rule": "self != 'root'"}]},
+    "io.example.v1.Thing": {
+      "type": "object",
+      "properties": {
+        "owner": {"$ref": "#/definitions/io.example.v1.Name", "description": "the owner", "minLength": 1},
+        "alias": {"$ref": "#/definitions/io.example.v1.Name", "maxLength": 10, "x-kubernetes-validations": [{"rule": "self != 'admin'"}]}
+      }
+    }
+  }
+}`
+}
+
+var (
+	constraintsOldSpec = `{
+  "definitions": {
+    "io.example.v1.Child": {"type": "object", "properties": {"name": {"type": "string"}}},
+    "io.example.v1.Thing": {
+      "type": "object",
+      "properties": {
+        "child": {"allOf": [{"$ref": "#/definitions/io.example.v1.Child"}], "description": "the child", "default": {}},
+        "count": {"type": "integer", "x-kubernetes-validations": [{"rule": "self >= 0", "message": "must not be negative"}]},
+        "mode": {"type": "string", "enum": ["a", "b"], "maxLength": 5},
+        "port": {"x-kubernetes-int-or-string": true},
+        "weird": {"type": "null"}
+      }
+    }
+  }
+}`
+	constraintsNewSpec = `{
+  "definitions": {
+    "io.example.v1.Child": {"type": "object", "properties": {"name": {"type": "string"}}},
+    "io.example.v1.Thing": {
+      "type": "object",
+      "properties": {
+        "child": {"allOf": [{"$ref": "#/definitions/io.example.v1.Child"}], "description": "the child", "default": {}},
+        "count": {"type": "integer", "minimum": 0, "x-kubernetes-validations": [
+          {"rule": "self < 100", "message": "too big"},
+          {"rule": "self >= 0", "message": "count must not be negative"}
+        ]},
+        "mode": {"type": "string", "enum": ["a", "c"]},
+        "port": {"x-kubernetes-int-or-string": true},
+        "weird": {"type": "null"}
+      }
+    }
+  }
+}`
+)
@@ -0,0 +1,311 @@
+package swagger
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mattfenwick/collections/pkg/set"
+	"github.com/mattfenwick/collections/pkg/slice"
+	"github.com/mattfenwick/kubectl-schema/pkg/utils"
+	"golang.org/x/exp/maps"
+)
+
+type LintCRDArgs struct {
+	CRDs      []string
+	SkipRules []string
+}
+
+func RunLintCRD(args *LintCRDArgs) error {
+	if len(args.CRDs) == 0 {
+		return utils.NewUsageError("at least one --crd is required")
+	}
+	skip := set.FromSlice(args.SkipRules)
+	for _, name := range args.SkipRules {
+		if _, ok := CRDLintRules[name]; !ok {
+			return utils.NewUsageError("invalid --skip-rule '%s'; valid rules are: %s", name, strings.Join(slice.Sort(maps.Keys(CRDLintRules)), ", "))
+		}
+	}
+
+	crds, err := ReadCustomResourceDefinitions(args.CRDs)
+	if err != nil {
+		return err
+	}
+
+	var findings []*CRDLintFinding
+	for _, crd := range crds {
+		crdFindings, err := LintCustomResourceDefinition(crd)
+		if err != nil {
+			return err
+		}
+		findings = append(findings, slice.Filter(func(f *CRDLintFinding) bool { return !skip.Contains(f.Rule) }, crdFindings)...)
+	}
+
+	errorCount := 0
+	for _, finding := range findings {
+		fmt.Println(finding.String())
+		if finding.Severity == CRDLintSeverityError {
+			errorCount++
+		}
+	}
+	fmt.Printf("\n%d crds checked: %d errors, %d warnings\n", len(crds), errorCount, len(findings)-errorCount)
+	if errorCount > 0 {
+		return utils.ErrDifferencesFound
+	}
+	return nil
+}
+
+type CRDLintSeverity string
+
+const (
+	CRDLintSeverityError   CRDLintSeverity = "error"
+	CRDLintSeverityWarning CRDLintSeverity = "warning"
+)
+
+type CRDLintFinding struct {
+	File     string
+	CRD      string
+	Version  string
+	Path     []string
+	Rule     string
+	Severity CRDLintSeverity
+	Message  string
+}
+
+func (f *CRDLintFinding) String() string {
+	path := strings.Join(f.Path, ".")
+	if path == "" {
+		path = "<root>"
+	}
+	return fmt.Sprintf("%s: %s/%s: %s: %s [%s]: %s", f.File, f.CRD, f.Version, path, f.Severity, f.Rule, f.Message)
+}
+
+// CRDLintRule checks a single node of a schema.  Rules are checked at every node; `path` tells
+// where the node is, with `[]` for array items, `additionalProperties` for map values, and
+// `allOf[0]`, `anyOf[0]`, `oneOf[0]` or `not` for value validations.  Value validations only
+// restrict values, and aren't part of the structural schema, so they're checked by their own
+// rules: those with ValueValidations set, which aren't checked anywhere else.
+type CRDLintRule struct {
+	Severity         CRDLintSeverity
+	ValueValidations bool
+	Check            func(path []string, schema *SpecType) []string
+}
+
+var (
+	camelCaseFieldName = regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`)
+
+	CRDLintRules = map[string]*CRDLintRule{
+		// structural schema rules: see https://kubernetes.io/docs/tasks/extend-kubernetes/custom-resources/custom-resource-definitions/#specifying-a-structural-schema
+		"structural-type": {
+			Severity: CRDLintSeverityError,
+			Check: func(path []string, schema *SpecType) []string {
+				if schema.Type == "" && !schema.IsIntOrString() && !schema.PreservesUnknownFields() {
+					return []string{"type must be specified, unless x-kubernetes-int-or-string or x-kubernetes-preserve-unknown-fields is true"}
+				}
+				return nil
+			},
+		},
+		"structural-root-object": {
+			Severity: CRDLintSeverityError,
+			Check: func(path []string, schema *SpecType) []string {
+				if len(path) == 0 && schema.Type != "object" {
+					return []string{fmt.Sprintf("root type must be object, found '%s'", schema.Type)}
+				}
+				return nil
+			},
+		},
+		"structural-no-ref": {
+			Severity: CRDLintSeverityError,
+			Check: func(path []string, schema *SpecType) []string {
+				if schema.Ref != "" {
+					return []string{fmt.Sprintf("$ref is not allowed, found '%s'", schema.Ref)}
+				}
+				return nil
+			},
+		},
+		"structural-array-items": {
+			Severity: CRDLintSeverityError,
+			Check: func(path []string, schema *SpecType) []string {
+				if schema.Type == "array" && schema.Items == nil {
+					return []string{"arrays must specify items"}
+				}
+				return nil
+			},
+		},
+		"structural-properties-xor-additional-properties": {
+			Severity: CRDLintSeverityError,
+			Check: func(path []string, schema *SpecType) []string {
+				if len(schema.Properties) > 0 && schema.AdditionalProperties != nil {
+					return []string{"properties and additionalProperties are mutually exclusive"}
+				}
+				return nil
+			},
+		},
+		"structural-metadata": {
+			Severity: CRDLintSeverityError,
+			Check: func(path []string, schema *SpecType) []string {
+				if len(path) != 1 || path[0] != "metadata" {
+					return nil
+				}
+				var messages []string
+				for _, field := range slice.Sort(maps.Keys(schema.Properties)) {
+					if field != "name" && field != "generateName" {
+						messages = append(messages, fmt.Sprintf("metadata may only restrict name and generateName, found '%s'", field))
+					}
+				}
+				return messages
+			},
+		},
+		"structural-value-validation": {
+			Severity:         CRDLintSeverityError,
+			ValueValidations: true,
+			Check: func(path []string, schema *SpecType) []string {
+				// x-kubernetes-int-or-string fields may spell out their types as anyOf: [{type: integer}, {type: string}]
+				allowedType := schema.Type == "" || schema.Type == "integer" || schema.Type == "string"
+				var messages []string
+				for _, keyword := range []struct {
+					name  string
+					found bool
+				}{
+					{"$ref", schema.Ref != ""},
+					{"type", !allowedType},
+					{"description", schema.Description != ""},
+					{"default", schema.Default != nil},
+					{"additionalProperties", schema.AdditionalProperties != nil},
+					{"nullable", schema.Nullable != nil},
+					{"x-kubernetes-preserve-unknown-fields", schema.XKubernetesPreserveUnknownFields != nil},
+					{"x-kubernetes-embedded-resource", schema.XKubernetesEmbeddedResource != nil},
+					{"x-kubernetes-int-or-string", schema.XKubernetesIntOrString != nil},
+					{"x-kubernetes-list-type", schema.XKubernetesListType != ""},
+					{"x-kubernetes-list-map-keys", len(schema.XKubernetesListMapKeys) > 0},
+				} {
+					if keyword.found {
+						messages = append(messages, fmt.Sprintf("%s is not allowed in allOf, anyOf, oneOf or not", keyword.name))
+					}
+				}
+				return messages
+			},
+		},
+		"int-or-string-no-type": {
+			Severity: CRDLintSeverityError,
+			Check: func(path []string, schema *SpecType) []string {
+				if schema.IsIntOrString() && schema.Type != "" {
+					return []string{fmt.Sprintf("x-kubernetes-int-or-string must not specify a type, found '%s'", schema.Type)}
+				}
+				return nil
+			},
+		},
+		// API conventions: see https://github.com/kubernetes/community/blob/master/contributors/devel/sig-architecture/api-conventions.md
+		"list-type": {
+			Severity: CRDLintSeverityWarning,
+			Check: func(path []string, schema *SpecType) []string {
+				if schema.Type == "array" && schema.XKubernetesListType == "" {
+					return []string{"arrays should specify x-kubernetes-list-type (atomic, set or map)"}
+				}
+				return nil
+			},
+		},
+		"list-map-keys": {
+			Severity: CRDLintSeverityError,
+			Check: func(path []string, schema *SpecType) []string {
+				if schema.XKubernetesListType == "map" && len(schema.XKubernetesListMapKeys) == 0 {
+					return []string{"x-kubernetes-list-type map requires x-kubernetes-list-map-keys"}
+				}
+				return nil
+			},
+		},
+		"camel-case-fields": {
+			Severity: CRDLintSeverityWarning,
+			Check: func(path []string, schema *SpecType) []string {
+				var messages []string
+				for _, field := range slice.Sort(maps.Keys(schema.Properties)) {
+					if !camelCaseFieldName.MatchString(field) {
+						messages = append(messages, fmt.Sprintf("field name '%s' should be camelCase", field))
+					}
+				}
+				return messages
+			},
+		},
+		"spec-status": {
+			Severity: CRDLintSeverityWarning,
+			Check: func(path []string, schema *SpecType) []string {
+				if len(path) != 0 {
+					return nil
+				}
+				var messages []string
+				for _, field := range []string{"spec", "status"} {
+					if _, ok := schema.Properties[field]; !ok {
+						messages = append(messages, fmt.Sprintf("missing top-level '%s'", field))
+					}
+				}
+				return messages
+			},
+		},
+		"unbounded-preserve-unknown-fields": {
+			Severity: CRDLintSeverityWarning,
+			Check: func(path []string, schema *SpecType) []string {
+				if schema.PreservesUnknownFields() && len(schema.Properties) == 0 {
+					return []string{"x-kubernetes-preserve-unknown-fields without any properties disables pruning and validation for everything underneath"}
+				}
+				return nil
+			},
+		},
+	}
+)
+
+// LintCustomResourceDefinition checks every version's schema against every rule
+func LintCustomResourceDefinition(crd *CustomResourceDefinition) ([]*CRDLintFinding, error) {
+	var findings []*CRDLintFinding
+	for _, version := range crd.Spec.Versions {
+		specType, err := crd.SpecType(version)
+		if err != nil {
+			return nil, err
+		}
+		walkSpecType([]string{}, specType, false, func(path []string, schema *SpecType, valueValidation bool) {
+			for _, ruleName := range slice.Sort(maps.Keys(CRDLintRules)) {
+				rule := CRDLintRules[ruleName]
+				if rule.ValueValidations != valueValidation {
+					continue
+				}
+				for _, message := range rule.Check(path, schema) {
+					findings = append(findings, &CRDLintFinding{
+						File:     crd.Source,
+						CRD:      crd.Metadata.Name,
+						Version:  version.Name,
+						Path:     path,
+						Rule:     ruleName,
+						Severity: rule.Severity,
+						Message:  message,
+					})
+				}
+			}
+		})
+	}
+	return findings, nil
+}
+
+// walkSpecType visits every node of a schema without following $refs.  Nodes under allOf, anyOf,
+// oneOf and not are value validations, as is everything beneath them.
+func walkSpecType(path []string, specType *SpecType, valueValidation bool, visit func(path []string, specType *SpecType, valueValidation bool)) {
+	visit(path, specType, valueValidation)
+	for _, field := range slice.Sort(maps.Keys(specType.Properties)) {
+		walkSpecType(slice.Append(path, []string{field}), specType.Properties[field], valueValidation, visit)
+	}
+	if specType.Items != nil {
+		walkSpecType(slice.Append(path, []string{"[]"}), specType.Items, valueValidation, visit)
+	}
+	if specType.AdditionalProperties != nil {
+		walkSpecType(slice.Append(path, []string{"additionalProperties"}), specType.AdditionalProperties, valueValidation, visit)
+	}
+	for _, keyword := range []struct {
+		name    string
+		schemas []*SpecType
+	}{{"allOf", specType.AllOf}, {"anyOf", specType.AnyOf}, {"oneOf", specType.OneOf}} {
+		for i, schema := range keyword.schemas {
+			walkSpecType(slice.Append(path, []string{fmt.Sprintf("%s[%d]", keyword.name, i)}), schema, true, visit)
+		}
+	}
+	if specType.Not != nil {
+		walkSpecType(slice.Append(path, []string{"not"}), specType.Not, true, visit)
+	}
+}
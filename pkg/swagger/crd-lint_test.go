@@ -0,0 +1,123 @@
+package swagger
+
+import (
+	"github.com/mattfenwick/collections/pkg/slice"
+	"github.com/mattfenwick/collections/pkg/yaml"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/exp/maps"
+)
+
+type crdLintFixture struct {
+	Schema   string
+	Expected []*CRDLintFinding
+}
+
+func RunCRDLintTests() {
+	// lint wraps a schema in the widget CRD, and returns the findings for a single rule
+	lint := func(schema string, rule string) []*CRDLintFinding {
+		documents, err := yaml.ParseMany[map[string]interface{}]([]byte(widgetCRD))
+		Expect(err).To(Succeed())
+		openAPIV3Schema, err := yaml.ParseMany[map[string]interface{}]([]byte(schema))
+		Expect(err).To(Succeed())
+		version := documents[0]["spec"].(map[string]interface{})["versions"].([]interface{})[0].(map[string]interface{})
+		version["schema"] = map[string]interface{}{"openAPIV3Schema": openAPIV3Schema[0]}
+
+		crd, err := parseCustomResourceDefinition(documents[0])
+		Expect(err).To(Succeed())
+		findings, err := LintCustomResourceDefinition(crd)
+		Expect(err).To(Succeed())
+		return slice.Filter(func(f *CRDLintFinding) bool { return f.Rule == rule }, findings)
+	}
+	finding := func(rule string, severity CRDLintSeverity, path []string, message string) *CRDLintFinding {
+		return &CRDLintFinding{CRD: "widgets.example.com", Version: "v1", Path: path, Rule: rule, Severity: severity, Message: message}
+	}
+
+	fixtures := map[string]*crdLintFixture{
+		"structural-type": {
+			Schema: `{type: object, properties: {spec: {type: object, properties: {size: {description: how big}}}, status: {type: object}}}`,
+			Expected: []*CRDLintFinding{finding("structural-type", CRDLintSeverityError, []string{"spec", "size"},
+				"type must be specified, unless x-kubernetes-int-or-string or x-kubernetes-preserve-unknown-fields is true")},
+		},
+		"structural-root-object": {
+			Schema:   `{type: string}`,
+			Expected: []*CRDLintFinding{finding("structural-root-object", CRDLintSeverityError, []string{}, "root type must be object, found 'string'")},
+		},
+		"structural-no-ref": {
+			Schema:   `{type: object, properties: {spec: {type: object, $ref: "#/definitions/WidgetSpec"}, status: {type: object}}}`,
+			Expected: []*CRDLintFinding{finding("structural-no-ref", CRDLintSeverityError, []string{"spec"}, "$ref is not allowed, found '#/definitions/WidgetSpec'")},
+		},
+		"structural-array-items": {
+			Schema:   `{type: object, properties: {spec: {type: object, properties: {ports: {type: array, x-kubernetes-list-type: atomic}}}, status: {type: object}}}`,
+			Expected: []*CRDLintFinding{finding("structural-array-items", CRDLintSeverityError, []string{"spec", "ports"}, "arrays must specify items")},
+		},
+		"structural-properties-xor-additional-properties": {
+			Schema: `{type: object, properties: {spec: {type: object, properties: {size: {type: integer}}, additionalProperties: {type: string}}, status: {type: object}}}`,
+			Expected: []*CRDLintFinding{finding("structural-properties-xor-additional-properties", CRDLintSeverityError, []string{"spec"},
+				"properties and additionalProperties are mutually exclusive")},
+		},
+		"structural-metadata": {
+			Schema: `{type: object, properties: {metadata: {type: object, properties: {name: {type: string}, labels: {type: object}}}, spec: {type: object}, status: {type: object}}}`,
+			Expected: []*CRDLintFinding{finding("structural-metadata", CRDLintSeverityError, []string{"metadata"},
+				"metadata may only restrict name and generateName, found 'labels'")},
+		},
+		"structural-value-validation": {
+			Schema: `{type: object, properties: {spec: {type: object, properties: {size: {type: integer}}, anyOf: [{required: [size]}, {description: no size, properties: {size: {type: boolean}}}]}, status: {type: object}}}`,
+			Expected: []*CRDLintFinding{
+				finding("structural-value-validation", CRDLintSeverityError, []string{"spec", "anyOf[1]"}, "description is not allowed in allOf, anyOf, oneOf or not"),
+				finding("structural-value-validation", CRDLintSeverityError, []string{"spec", "anyOf[1]", "size"}, "type is not allowed in allOf, anyOf, oneOf or not"),
+			},
+		},
+		"int-or-string-no-type": {
+			Schema: `{type: object, properties: {spec: {type: object, properties: {port: {type: string, x-kubernetes-int-or-string: true}}}, status: {type: object}}}`,
+			Expected: []*CRDLintFinding{finding("int-or-string-no-type", CRDLintSeverityError, []string{"spec", "port"},
+				"x-kubernetes-int-or-string must not specify a type, found 'string'")},
+		},
+		"list-type": {
+			Schema: `{type: object, properties: {spec: {type: object, properties: {hosts: {type: array, items: {type: string}}}}, status: {type: object}}}`,
+			Expected: []*CRDLintFinding{finding("list-type", CRDLintSeverityWarning, []string{"spec", "hosts"},
+				"arrays should specify x-kubernetes-list-type (atomic, set or map)")},
+		},
+		"list-map-keys": {
+			Schema: `{type: object, properties: {spec: {type: object, properties: {ports: {type: array, x-kubernetes-list-type: map, items: {type: object}}}}, status: {type: object}}}`,
+			Expected: []*CRDLintFinding{finding("list-map-keys", CRDLintSeverityError, []string{"spec", "ports"},
+				"x-kubernetes-list-type map requires x-kubernetes-list-map-keys")},
+		},
+		"camel-case-fields": {
+			Schema: `{type: object, properties: {spec: {type: object, properties: {Bad_Name: {type: string}, max-size: {type: integer}, goodName: {type: string}}}, status: {type: object}}}`,
+			Expected: []*CRDLintFinding{
+				finding("camel-case-fields", CRDLintSeverityWarning, []string{"spec"}, "field name 'Bad_Name' should be camelCase"),
+				finding("camel-case-fields", CRDLintSeverityWarning, []string{"spec"}, "field name 'max-size' should be camelCase"),
+			},
+		},
+		"spec-status": {
+			Schema:   `{type: object, properties: {spec: {type: object}}}`,
+			Expected: []*CRDLintFinding{finding("spec-status", CRDLintSeverityWarning, []string{}, "missing top-level 'status'")},
+		},
+		"unbounded-preserve-unknown-fields": {
+			Schema: `{type: object, properties: {spec: {type: object, properties: {config: {type: object, x-kubernetes-preserve-unknown-fields: true}}}, status: {type: object}}}`,
+			Expected: []*CRDLintFinding{finding("unbounded-preserve-unknown-fields", CRDLintSeverityWarning, []string{"spec", "config"},
+				"x-kubernetes-preserve-unknown-fields without any properties disables pruning and validation for everything underneath")},
+		},
+	}
+
+	Describe("CRD lint", func() {
+		It("has a fixture for every rule", func() {
+			Expect(slice.Sort(maps.Keys(fixtures))).To(Equal(slice.Sort(maps.Keys(CRDLintRules))))
+		})
+
+		for _, rule := range slice.Sort(maps.Keys(fixtures)) {
+			rule, fixture := rule, fixtures[rule]
+			It("finds violations of "+rule, func() {
+				Expect(lint(fixture.Schema, rule)).To(Equal(fixture.Expected))
+			})
+		}
+
+		It("finds nothing in a clean schema", func() {
+			clean := `{type: object, properties: {spec: {type: object, properties: {ports: {type: array, x-kubernetes-list-type: map, x-kubernetes-list-map-keys: [name], items: {type: object, properties: {name: {type: string}, port: {x-kubernetes-int-or-string: true, anyOf: [{type: integer}, {type: string}]}}}}}, not: {required: [replicas]}}, status: {type: object, properties: {phase: {type: string}}}}}`
+			for _, rule := range slice.Sort(maps.Keys(CRDLintRules)) {
+				Expect(lint(clean, rule)).To(BeEmpty(), rule)
+			}
+		})
+	})
+}
@@ -0,0 +1,289 @@
+package swagger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mattfenwick/collections/pkg/json"
+	"github.com/mattfenwick/collections/pkg/slice"
+	"github.com/mattfenwick/collections/pkg/yaml"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	objectMetaDefinitionName = "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"
+)
+
+type CustomResourceDefinition struct {
+	ApiVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		Group string `json:"group"`
+		Names struct {
+			Kind       string   `json:"kind"`
+			ListKind   string   `json:"listKind,omitempty"`
+			Plural     string   `json:"plural"`
+			Singular   string   `json:"singular,omitempty"`
+			ShortNames []string `json:"shortNames,omitempty"`
+		} `json:"names"`
+		Scope    string                             `json:"scope"`
+		Versions []*CustomResourceDefinitionVersion `json:"versions"`
+	} `json:"spec"`
+	// Source is the file the CRD was read from
+	Source string `json:"-"`
+}
+
+type CustomResourceDefinitionVersion struct {
+	Name    string `json:"name"`
+	Served  bool   `json:"served"`
+	Storage bool   `json:"storage"`
+	Schema  *struct {
+		OpenAPIV3Schema map[string]interface{} `json:"openAPIV3Schema"`
+	} `json:"schema,omitempty"`
+	Subresources *struct {
+		Status map[string]interface{} `json:"status,omitempty"`
+		Scale  map[string]interface{} `json:"scale,omitempty"`
+	} `json:"subresources,omitempty"`
+}
+
+func (c *CustomResourceDefinition) GVK(version string) *GVK {
+	return &GVK{Group: c.Spec.Group, Version: version, Kind: c.Spec.Names.Kind}
+}
+
+// DefinitionName follows the naming the kube apiserver uses when publishing CRDs: the group
+// is reversed, as with built-in types.  Example: `cert-manager.io` => `io.cert-manager.v1.Certificate`
+func (c *CustomResourceDefinition) DefinitionName(version string) string {
+	return strings.Join(slice.Append(slice.Reverse(strings.Split(c.Spec.Group, ".")), []string{version, c.Spec.Names.Kind}), ".")
+}
+
+// CustomResourceDefinitionNameIndex indexes every version of the CRDs, with their plurals and
+// short names
+func CustomResourceDefinitionNameIndex(crds []*CustomResourceDefinition) *ResourceNameIndex {
+	index := NewResourceNameIndex()
+	for _, crd := range crds {
+		for _, version := range crd.Spec.Versions {
+			index.Add(crd.GVK(version.Name), crd.Spec.Names.Plural, crd.Spec.Names.ShortNames)
+		}
+	}
+	return index
+}
+
+// Paths are the paths the kube apiserver serves for a version
+func (c *CustomResourceDefinition) Paths(version *CustomResourceDefinitionVersion) map[string]*PathItem {
+	operation := func(gvk *GVK, action string) *PathOperation {
+		return &PathOperation{Action: action, GVK: gvk}
+	}
+	gvk := c.GVK(version.Name)
+	prefix := fmt.Sprintf("/apis/%s/%s", c.Spec.Group, version.Name)
+	collection := fmt.Sprintf("%s/%s", prefix, c.Spec.Names.Plural)
+	watchCollection := fmt.Sprintf("%s/watch/%s", prefix, c.Spec.Names.Plural)
+	paths := map[string]*PathItem{}
+	if c.Spec.Scope == "Namespaced" {
+		paths[collection] = &PathItem{Get: operation(gvk, "list")}
+		paths[watchCollection] = &PathItem{Get: operation(gvk, "watchlist")}
+		collection = fmt.Sprintf("%s/namespaces/{namespace}/%s", prefix, c.Spec.Names.Plural)
+		watchCollection = fmt.Sprintf("%s/watch/namespaces/{namespace}/%s", prefix, c.Spec.Names.Plural)
+	}
+	item := collection + "/{name}"
+	paths[collection] = &PathItem{Get: operation(gvk, "list"), Post: operation(gvk, "post"), Delete: operation(gvk, "deletecollection")}
+	paths[item] = &PathItem{Get: operation(gvk, "get"), Put: operation(gvk, "put"), Patch: operation(gvk, "patch"), Delete: operation(gvk, "delete")}
+	paths[watchCollection] = &PathItem{Get: operation(gvk, "watchlist")}
+	paths[watchCollection+"/{name}"] = &PathItem{Get: operation(gvk, "watch")}
+	if version.Subresources != nil && version.Subresources.Status != nil {
+		paths[item+"/status"] = &PathItem{Get: operation(gvk, "get"), Put: operation(gvk, "put"), Patch: operation(gvk, "patch")}
+	}
+	if version.Subresources != nil && version.Subresources.Scale != nil {
+		scale := &GVK{Group: "autoscaling", Version: "v1", Kind: "Scale"}
+		paths[item+"/scale"] = &PathItem{Get: operation(scale, "get"), Put: operation(scale, "put"), Patch: operation(scale, "patch")}
+	}
+	return paths
+}
+
+// SpecType converts a version's `openAPIV3Schema` into a SpecType
+func (c *CustomResourceDefinition) SpecType(version *CustomResourceDefinitionVersion) (*SpecType, error) {
+	if version.Schema == nil || version.Schema.OpenAPIV3Schema == nil {
+		return nil, errors.Errorf("crd %s version %s has no openAPIV3Schema", c.Metadata.Name, version.Name)
+	}
+	bytes, err := json.Marshal(normalizeOpenAPIV3Schema(version.Schema.OpenAPIV3Schema))
+	if err != nil {
+		return nil, err
+	}
+	specType, err := json.Parse[SpecType](bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse openAPIV3Schema for crd %s version %s", c.Metadata.Name, version.Name)
+	}
+	specType.XKubernetesGroupVersionKind = []*GVK{c.GVK(version.Name)}
+	return specType, nil
+}
+
+// normalizeOpenAPIV3Schema drops boolean `additionalProperties`, which SpecType can't represent:
+// `false` is the default, and `true` is replaced by a schema which allows anything.  Values, such as
+// defaults, are left alone, and so are the names of properties.
+func normalizeOpenAPIV3Schema(obj interface{}) interface{} {
+	switch val := obj.(type) {
+	case map[string]interface{}:
+		out := map[string]interface{}{}
+		for k, v := range val {
+			b, isBool := v.(bool)
+			switch {
+			case k == "additionalProperties" && isBool:
+				if b {
+					out[k] = map[string]interface{}{"x-kubernetes-preserve-unknown-fields": true}
+				}
+			case k == "default" || k == "enum" || k == "example":
+				out[k] = v
+			case k == "properties" || k == "patternProperties" || k == "definitions":
+				out[k] = normalizeOpenAPIV3SchemasByName(v)
+			default:
+				out[k] = normalizeOpenAPIV3Schema(v)
+			}
+		}
+		return out
+	case []interface{}:
+		return slice.Map(normalizeOpenAPIV3Schema, val)
+	default:
+		return val
+	}
+}
+
+func normalizeOpenAPIV3SchemasByName(obj interface{}) interface{} {
+	schemas, ok := obj.(map[string]interface{})
+	if !ok {
+		return obj
+	}
+	out := map[string]interface{}{}
+	for name, schema := range schemas {
+		out[name] = normalizeOpenAPIV3Schema(schema)
+	}
+	return out
+}
+
+// ReadCustomResourceDefinitions reads CRDs from yaml files.  Directories are read non-recursively,
+// picking up files ending in .yaml, .yml or .json.  Documents which aren't CRDs are skipped.
+func ReadCustomResourceDefinitions(paths []string) ([]*CustomResourceDefinition, error) {
+	var crds []*CustomResourceDefinition
+	for _, path := range paths {
+		files, err := yamlFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			documents, err := yaml.ParseManyFromFile[map[string]interface{}](file)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to read crds from %s", file)
+			}
+			for _, document := range documents {
+				if document == nil || document["kind"] != "CustomResourceDefinition" {
+					logrus.Debugf("skipping non-crd document in %s", file)
+					continue
+				}
+				crd, err := parseCustomResourceDefinition(document)
+				if err != nil {
+					return nil, errors.Wrapf(err, "unable to parse crd from %s", file)
+				}
+				crd.Source = file
+				crds = append(crds, crd)
+			}
+		}
+	}
+	return crds, nil
+}
+
+// yamlFiles is the path itself if it's a file, otherwise the .yaml, .yml and .json files in it
+func yamlFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to stat %s", path)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read dir %s", path)
+	}
+	var files []string
+	for _, entry := range entries {
+		switch filepath.Ext(entry.Name()) {
+		case ".yaml", ".yml", ".json":
+			if !entry.IsDir() {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+	return files, nil
+}
+
+func parseCustomResourceDefinition(document map[string]interface{}) (*CustomResourceDefinition, error) {
+	bytes, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	crd, err := json.Parse[CustomResourceDefinition](bytes)
+	if err != nil {
+		return nil, err
+	}
+	if crd.ApiVersion != "apiextensions.k8s.io/v1" {
+		return nil, errors.Errorf("unsupported crd apiVersion %s for %s; only apiextensions.k8s.io/v1 is supported", crd.ApiVersion, crd.Metadata.Name)
+	}
+	return crd, nil
+}
+
+// MergeCustomResourceDefinitions adds a definition for each version of each CRD, and paths for each
+// served version.  Like the kube apiserver, it fills in `apiVersion`, `kind` and `metadata` if the
+// CRD schema leaves them out.
+func (s *KubeSpec) MergeCustomResourceDefinitions(crds []*CustomResourceDefinition) error {
+	if s.Paths == nil {
+		s.Paths = map[string]interface{}{}
+	}
+	for _, crd := range crds {
+		for _, version := range crd.Spec.Versions {
+			if version.Served {
+				for path, item := range crd.Paths(version) {
+					generic, err := json.Remarshal(item)
+					if err != nil {
+						return errors.Wrapf(err, "unable to marshal path %s for crd %s", path, crd.Metadata.Name)
+					}
+					s.Paths[path] = generic
+				}
+			}
+			specType, err := crd.SpecType(version)
+			if err != nil {
+				return err
+			}
+			s.addTypeMeta(specType)
+
+			name := crd.DefinitionName(version.Name)
+			if _, ok := s.Definitions[name]; ok {
+				logrus.Warnf("crd %s from %s overrides existing definition %s", crd.Metadata.Name, crd.Source, name)
+			}
+			logrus.Debugf("adding definition %s from crd %s", name, crd.Metadata.Name)
+			s.Definitions[name] = specType
+		}
+	}
+	return nil
+}
+
+func (s *KubeSpec) addTypeMeta(specType *SpecType) {
+	if specType.Properties == nil {
+		specType.Properties = map[string]*SpecType{}
+	}
+	for _, field := range []string{"apiVersion", "kind"} {
+		if _, ok := specType.Properties[field]; !ok {
+			specType.Properties[field] = &SpecType{Type: "string"}
+		}
+	}
+	metadata, ok := specType.Properties["metadata"]
+	if _, hasObjectMeta := s.Definitions[objectMetaDefinitionName]; hasObjectMeta && (!ok || len(metadata.Properties) == 0) {
+		specType.Properties["metadata"] = &SpecType{Ref: "#/definitions/" + objectMetaDefinitionName}
+		if ok {
+			specType.Properties["metadata"].Description = metadata.Description
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package swagger
+
+import (
+	"strings"
+
+	"github.com/mattfenwick/collections/pkg/slice"
+	"github.com/mattfenwick/collections/pkg/yaml"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func RunCustomResourceDefinitionTests() {
+	Describe("CustomResourceDefinition", func() {
+		documents, err := yaml.ParseMany[map[string]interface{}]([]byte(widgetCRD))
+		if err != nil {
+			panic(err)
+		}
+
+		It("converts each version to a definition", func() {
+			crd, err := parseCustomResourceDefinition(documents[0])
+			Expect(err).To(Succeed())
+			Expect(crd.DefinitionName("v1")).To(Equal("com.example.v1.Widget"))
+
+			spec := &KubeSpec{Definitions: map[string]*SpecType{
+				objectMetaDefinitionName: {Type: "object", Properties: map[string]*SpecType{"name": {Type: "string"}}},
+			}}
+			Expect(spec.MergeCustomResourceDefinitions([]*CustomResourceDefinition{crd})).To(Succeed())
+
+			widget := spec.Definitions["com.example.v1.Widget"]
+			Expect(widget).NotTo(BeNil())
+			Expect(widget.XKubernetesGroupVersionKind).To(Equal([]*GVK{{Group: "example.com", Version: "v1", Kind: "Widget"}}))
+			Expect(widget.Properties["metadata"]).To(Equal(&SpecType{Ref: "#/definitions/" + objectMetaDefinitionName, Description: "standard metadata"}))
+			Expect(widget.Properties["apiVersion"]).To(Equal(&SpecType{Type: "string"}))
+			Expect(widget.Properties["spec"].Properties["labels"].AdditionalProperties.PreservesUnknownFields()).To(BeTrue())
+			Expect(widget.Properties["spec"].Properties["closed"].AdditionalProperties).To(BeNil())
+
+			structure, err := spec.ResolveStructure()
+			Expect(err).To(Succeed())
+			resolved := structure["Widget"]["com.example.v1"]
+			Expect(resolved.Object.Properties["metadata"].Object.Properties["name"].Primitive).To(Equal("string"))
+		})
+
+		It("serves every verb, including watch", func() {
+			crd, err := parseCustomResourceDefinition(documents[0])
+			Expect(err).To(Succeed())
+			spec := &KubeSpec{Definitions: map[string]*SpecType{}}
+			Expect(spec.MergeCustomResourceDefinitions([]*CustomResourceDefinition{crd})).To(Succeed())
+			Expect(spec.Paths).To(HaveKey("/apis/example.com/v1/watch/widgets"))
+			Expect(spec.Paths).To(HaveKey("/apis/example.com/v1/watch/namespaces/{namespace}/widgets"))
+			Expect(spec.Paths).To(HaveKey("/apis/example.com/v1/watch/namespaces/{namespace}/widgets/{name}"))
+
+			catalog, err := spec.ResourceCatalog()
+			Expect(err).To(Succeed())
+			widgets := catalog[GVK{Group: "example.com", Version: "v1", Kind: "Widget"}]
+			Expect(widgets.Plural).To(Equal("widgets"))
+			Expect(widgets.Namespaced).To(BeTrue())
+			Expect(widgets.Verbs).To(Equal([]string{"create", "delete", "deletecollection", "get", "list", "patch", "update", "watch"}))
+		})
+
+		It("normalizes boolean additionalProperties in schemas, but not in values", func() {
+			preserve := map[string]interface{}{"x-kubernetes-preserve-unknown-fields": true}
+			literal := map[string]interface{}{"additionalProperties": true}
+			schema := map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"enum":     map[string]interface{}{"type": "object", "additionalProperties": true},
+					"settings": map[string]interface{}{"type": "object", "default": literal, "example": literal, "enum": []interface{}{literal}},
+				},
+			}
+			Expect(normalizeOpenAPIV3Schema(schema)).To(Equal(map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"enum":     map[string]interface{}{"type": "object", "additionalProperties": preserve},
+					"settings": map[string]interface{}{"type": "object", "default": literal, "example": literal, "enum": []interface{}{literal}},
+				},
+			}))
+		})
+
+		It("lints structural schema rules and api conventions", func() {
+			crd, err := parseCustomResourceDefinition(documents[0])
+			Expect(err).To(Succeed())
+			findings, err := LintCustomResourceDefinition(crd)
+			Expect(err).To(Succeed())
+
+			rules := slice.Map(func(f *CRDLintFinding) string { return strings.Join(f.Path, ".") + " " + f.Rule }, findings)
+			Expect(rules).To(Equal([]string{
+				" spec-status",
+				"spec.labels.additionalProperties unbounded-preserve-unknown-fields",
+			}))
+			Expect(findings[0].Severity).To(Equal(CRDLintSeverityWarning))
+
+			specProperties := crd.Spec.Versions[0].Schema.OpenAPIV3Schema["properties"].(map[string]interface{})["spec"].(map[string]interface{})["properties"].(map[string]interface{})
+			specProperties["ports"] = map[string]interface{}{"type": "array"}
+			findings, err = LintCustomResourceDefinition(crd)
+			Expect(err).To(Succeed())
+			errorRules := slice.Map(func(f *CRDLintFinding) string { return f.Rule },
+				slice.Filter(func(f *CRDLintFinding) bool { return f.Severity == CRDLintSeverityError }, findings))
+			Expect(errorRules).To(Equal([]string{"structural-array-items"}))
+		})
+
+		It("rejects old crd api versions", func() {
+			_, err := parseCustomResourceDefinition(map[string]interface{}{"apiVersion": "apiextensions.k8s.io/v1beta1", "kind": "CustomResourceDefinition"})
+			Expect(err).NotTo(Succeed())
+		})
+	})
+}
+
+var (
+	widgetCRD = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+    plural: widgets
+  scope: Namespaced
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          metadata:
+            type: object
+            description: standard metadata
+          spec:
+            type: object
+            properties:
+              labels:
+                type: object
+                additionalProperties: true
+              closed:
+                type: object
+                additionalProperties: false
+`
+)
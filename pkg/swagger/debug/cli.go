@@ -1,6 +1,7 @@
 package debug
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -8,7 +9,7 @@ import (
 	"github.com/mattfenwick/collections/pkg/json"
 	"github.com/mattfenwick/collections/pkg/slice"
 	"github.com/mattfenwick/kubectl-schema/pkg/swagger"
-	"github.com/mattfenwick/kubectl-schema/pkg/utils"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -38,8 +39,8 @@ func setupParseCommand() *cobra.Command {
 		Use:   "parse",
 		Short: "parse and serialize openapi spec for comparison (test command)",
 		Args:  cobra.ExactArgs(0),
-		Run: func(cmd *cobra.Command, as []string) {
-			RunParse(args)
+		RunE: func(cmd *cobra.Command, as []string) error {
+			return RunParse(cmd.Context(), args)
 		},
 	}
 
@@ -48,8 +49,15 @@ func setupParseCommand() *cobra.Command {
 	return command
 }
 
-func RunParse(args *ParseArgs) {
-	spec := swagger.MustReadSwaggerSpecFromGithub(swagger.MustVersion(args.Version))
+func RunParse(ctx context.Context, args *ParseArgs) error {
+	version, err := swagger.ParseKubeVersion(args.Version)
+	if err != nil {
+		return err
+	}
+	spec, err := (&swagger.SpecSource{}).ReadUpstream(ctx, version)
+	if err != nil {
+		return err
+	}
 
 	for name, t := range spec.Definitions {
 		for propName, prop := range t.Properties {
@@ -59,9 +67,12 @@ func RunParse(args *ParseArgs) {
 
 	// must do weird marshal/unmarshal/marshal dance to get struct keys sorted
 	bytes, err := json.MarshalWithOptions(spec, &json.MarshalOptions{EscapeHTML: true, Indent: true, Sort: true})
-	utils.Die(err)
+	if err != nil {
+		return errors.Wrapf(err, "unable to marshal spec")
+	}
 
 	fmt.Printf("%s\n", bytes)
+	return nil
 }
 
 type AnalyzeSchemaArgs struct {
@@ -76,8 +87,8 @@ func setupAnalyzeSchemaCommand() *cobra.Command {
 		Use:   "analyze-schema",
 		Short: "analyze shape of openapi schema",
 		Args:  cobra.ExactArgs(0),
-		Run: func(cmd *cobra.Command, as []string) {
-			RunAnalyzeSchema(args)
+		RunE: func(cmd *cobra.Command, as []string) error {
+			return RunAnalyzeSchema(cmd.Context(), args)
 		},
 	}
 
@@ -87,20 +98,31 @@ func setupAnalyzeSchemaCommand() *cobra.Command {
 	return command
 }
 
-func RunAnalyzeSchema(args *AnalyzeSchemaArgs) {
+func RunAnalyzeSchema(ctx context.Context, args *AnalyzeSchemaArgs) error {
 	if args.All {
-		RunAnalyzeSchemaLatest()
-		return
+		return RunAnalyzeSchemaLatest(ctx)
 	}
 
-	path := swagger.MakePathFromKubeVersion(swagger.MustVersion(args.Version))
+	version, err := swagger.ParseKubeVersion(args.Version)
+	if err != nil {
+		return err
+	}
+	path, err := swagger.MakePathFromKubeVersion(version)
+	if err != nil {
+		return err
+	}
 	specObj, err := json.ParseFile[map[string]interface{}](path)
-	utils.Die(err)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read spec from %s", path)
+	}
 	//spec := MustReadSwaggerSpecFromGithub(args.Version) // TODO
 
 	//starterPaths := []string{"paths", "definitions"}
 	starterPaths := []string{"definitions"}
-	paths, schemaPaths := JsonFindPaths(specObj, starterPaths)
+	paths, schemaPaths, err := JsonFindPaths(specObj, starterPaths)
+	if err != nil {
+		return err
+	}
 	for _, p := range paths {
 		if false {
 			fmt.Printf("%s\n", strings.Join(p, " "))
@@ -109,29 +131,40 @@ func RunAnalyzeSchema(args *AnalyzeSchemaArgs) {
 	for _, p := range schemaPaths {
 		fmt.Printf("%s\n", strings.Join(p, " "))
 	}
+	return nil
 }
 
-func RunAnalyzeSchemaLatest() {
+func RunAnalyzeSchemaLatest(ctx context.Context) error {
 	for _, version := range swagger.LatestKubePatchVersions {
 		path := fmt.Sprintf("test-schema/%s.txt", version)
-		specBytes := swagger.MustDownloadSwaggerSpec(version)
+		specBytes, err := swagger.DefaultSpecDownloader.Download(ctx, version)
+		if err != nil {
+			return err
+		}
 		specObj, err := json.Parse[map[string]interface{}](specBytes)
-		utils.Die(err)
+		if err != nil {
+			return errors.Wrapf(err, "unable to parse spec for %s", version.ToString())
+		}
 		//spec := MustReadSwaggerSpecFromGithub(args.Version) // TODO
 
 		//starterPathsToInspect := []string{"paths", "definitions"}
 		starterPathsToInspect := []string{"definitions"}
-		schemaPaths, dedupedPaths := JsonFindPaths(specObj, starterPathsToInspect)
+		schemaPaths, dedupedPaths, err := JsonFindPaths(specObj, starterPathsToInspect)
+		if err != nil {
+			return err
+		}
 		for _, p := range schemaPaths {
 			if false {
 				fmt.Printf("%s\n", strings.Join(p, " "))
 			}
 		}
 		lines := slice.Map(func(xs []string) string { return strings.Join(xs, " ") }, dedupedPaths)
-		err = file.Write(path, []byte(strings.Join(lines, "\n")), 0644)
-		utils.Die(err)
+		if err := file.Write(path, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+			return errors.Wrapf(err, "unable to write %s", path)
+		}
 		//for _, p := range dedupedPaths {
 		//	fmt.Printf("%s\n", strings.Join(p, " "))
 		//}
 	}
+	return nil
 }
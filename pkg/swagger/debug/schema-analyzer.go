@@ -7,7 +7,6 @@ import (
 	"github.com/mattfenwick/collections/pkg/function"
 	"github.com/mattfenwick/collections/pkg/set"
 	"github.com/mattfenwick/collections/pkg/slice"
-	"github.com/mattfenwick/kubectl-schema/pkg/utils"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/exp/maps"
@@ -73,16 +72,21 @@ func BounceMarshalGeneric[A any](in interface{}) (*A, error) {
 	return &out, errors.Wrapf(err, "unable to unmarshal k8s yaml")
 }
 
-func JsonFindPaths(obj interface{}, starterPaths []string) ([][]string, [][]string) {
+func JsonFindPaths(obj interface{}, starterPaths []string) ([][]string, [][]string, error) {
 	paths := &JsonPaths{}
 	bouncedObj, err := BounceMarshalGeneric[map[string]interface{}](obj)
-	utils.Die(err)
+	if err != nil {
+		return nil, nil, err
+	}
 	JsonFindPathsHelper(*bouncedObj, []string{}, paths)
 
 	trie := NewTrie()
 	schemaPaths := &JsonPaths{}
 	for _, key := range starterPaths {
-		nextLevel := (*bouncedObj)[key].(map[string]interface{})
+		nextLevel, ok := (*bouncedObj)[key].(map[string]interface{})
+		if !ok {
+			return nil, nil, errors.Errorf("expected object at key %s, found %T", key, (*bouncedObj)[key])
+		}
 		for _, val := range nextLevel {
 			JsonFindPathsHelper(val, []string{key}, schemaPaths)
 		}
@@ -93,7 +97,7 @@ func JsonFindPaths(obj interface{}, starterPaths []string) ([][]string, [][]stri
 	triePaths := &JsonPaths{}
 	trie.GetPaths([]string{}, triePaths)
 
-	return paths.GetSortedPaths(), triePaths.GetSortedPaths()
+	return paths.GetSortedPaths(), triePaths.GetSortedPaths(), nil
 }
 
 func JsonFindPathsHelper(obj interface{}, pathContext []string, paths *JsonPaths) {
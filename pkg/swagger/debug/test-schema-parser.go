@@ -1,6 +1,7 @@
 package debug
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path"
@@ -9,7 +10,7 @@ import (
 	"github.com/mattfenwick/collections/pkg/file"
 	"github.com/mattfenwick/collections/pkg/json"
 	"github.com/mattfenwick/kubectl-schema/pkg/swagger"
-	"github.com/mattfenwick/kubectl-schema/pkg/utils"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
@@ -18,48 +19,74 @@ func setupTestSchemaParserCommand() *cobra.Command {
 		Use:   "test-schema-parser",
 		Short: "make sure schema parser handles everything",
 		Args:  cobra.ExactArgs(0),
-		Run: func(cmd *cobra.Command, as []string) {
-			TestSchemaParser()
+		RunE: func(cmd *cobra.Command, as []string) error {
+			return TestSchemaParser(cmd.Context())
 		},
 	}
 
 	return command
 }
 
-func TestSchemaParser() {
+func TestSchemaParser(ctx context.Context) error {
 	schemaDir := "test-schema"
 	for _, version := range swagger.LatestKubePatchVersions {
-		CheckSchema(path.Join(schemaDir, version.ToString()), version)
+		if err := CheckSchema(ctx, path.Join(schemaDir, version.ToString()), version); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-func CheckSchema(dir string, version swagger.KubeVersion) {
-	specBytes := swagger.MustDownloadSwaggerSpec(version)
+func CheckSchema(ctx context.Context, dir string, version swagger.KubeVersion) error {
+	specBytes, err := swagger.DefaultSpecDownloader.Download(ctx, version)
+	if err != nil {
+		return err
+	}
 	// remove paths
 	specMap, err := json.Parse[map[string]interface{}](specBytes)
-	utils.Die(err)
+	if err != nil {
+		return errors.Wrapf(err, "unable to parse spec")
+	}
 	delete(*specMap, "paths")
 	specMapBytes, err := json.MarshalWithOptions(specMap, json.DefaultMarshalOptions)
-	utils.Die(err)
+	if err != nil {
+		return errors.Wrapf(err, "unable to marshal spec")
+	}
 	// carry on
 	spec, err := json.Parse[swagger.KubeSpec](specMapBytes)
-	utils.Die(err)
+	if err != nil {
+		return errors.Wrapf(err, "unable to parse spec into KubeSpec")
+	}
 
 	specString, err := json.MarshalToString(spec)
-	utils.Die(err)
+	if err != nil {
+		return errors.Wrapf(err, "unable to marshal KubeSpec")
+	}
 	spec2, err := json.Parse[swagger.KubeSpec]([]byte(specString))
-	utils.Die(err)
+	if err != nil {
+		return errors.Wrapf(err, "unable to parse marshaled KubeSpec")
+	}
 
-	utils.Die(os.MkdirAll(dir, 0777))
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return errors.Wrapf(err, "unable to mkdir %s", dir)
+	}
 	path1, path2 := path.Join(dir, "spec1.txt"), path.Join(dir, "spec2.txt")
 
 	sortedSpecBytes, err := json.SortOptions(specMapBytes, false, true)
-	utils.Die(err)
-	utils.Die(file.Write(path1, sortedSpecBytes, 0644))
+	if err != nil {
+		return err
+	}
+	if err := file.Write(path1, sortedSpecBytes, 0644); err != nil {
+		return errors.Wrapf(err, "unable to write %s", path1)
+	}
 
 	sortedSpecStringBytes, err := json.SortOptions([]byte(specString), false, true)
-	utils.Die(err)
-	utils.Die(file.Write(path2, sortedSpecStringBytes, 0644))
+	if err != nil {
+		return err
+	}
+	if err := file.Write(path2, sortedSpecStringBytes, 0644); err != nil {
+		return errors.Wrapf(err, "unable to write %s", path2)
+	}
 
 	//diff, err := utils.CommandRun(exec.Command("git", "diff", "--no-index", "my-spec-1.txt", "my-spec-2.txt"))
 	//utils.DoOrDie(err)
@@ -72,4 +99,5 @@ func CheckSchema(dir string, version swagger.KubeVersion) {
 	//specString2 := utils.JsonString(spec2)
 
 	fmt.Printf("same? %t, %t\n", reflect.DeepEqual(spec, spec2), specString == string(specBytes))
+	return nil
 }
@@ -1,12 +1,17 @@
 package swagger
 
 import (
+	"context"
 	"fmt"
+	"strings"
+
+	"github.com/mattfenwick/collections/pkg/json"
+	"github.com/mattfenwick/collections/pkg/set"
 	"github.com/mattfenwick/collections/pkg/slice"
+	"github.com/mattfenwick/kubectl-schema/pkg/utils"
 	"github.com/olekukonko/tablewriter"
 	"github.com/pkg/errors"
 	"golang.org/x/exp/maps"
-	"strings"
 )
 
 type ExplainArgs struct {
@@ -16,66 +21,280 @@ type ExplainArgs struct {
 	KubeVersions []string
 	Depth        int
 	Paths        []string
+	// ExcludeApiVersions, ExcludeResources and ExcludePaths are patterns to leave out, even if they're included
+	ExcludeApiVersions []string
+	ExcludeResources   []string
+	ExcludePaths       []string
+	CRDs               []string
+	DiffOnly           bool
 }
 
-func RunExplain(args *ExplainArgs) {
-	allowApiVersion := allower(args.ApiVersions)
-	allowResource := allower(args.Resources)
+func RunExplain(ctx context.Context, args *ExplainArgs) error {
+	if err := validateChoice("format", args.Format, []string{"table", "condensed", "tree", "json", "yaml", "pivot"}); err != nil {
+		return err
+	}
+	if args.DiffOnly && args.Format != "pivot" {
+		return utils.NewUsageError("--diff-only requires --format pivot")
+	}
+	kubeVersions, err := ParseKubeVersions(args.KubeVersions)
+	if err != nil {
+		return err
+	}
+	versionStrings := slice.Map(func(v KubeVersion) string { return v.ToString() }, kubeVersions)
+	if set.FromSlice(versionStrings).Len() != len(versionStrings) {
+		return utils.NewUsageError("kube versions must be unique, found %+v", args.KubeVersions)
+	}
+	allowApiVersion, err := apiVersionFilter(args.ApiVersions, args.ExcludeApiVersions)
+	if err != nil {
+		return err
+	}
+	resources, err := newResourceFilter(args.Resources, args.ExcludeResources)
+	if err != nil {
+		return err
+	}
+	paths, err := newPathFilter(args.Paths, args.ExcludePaths, args.Depth)
+	if err != nil {
+		return err
+	}
+	allowPath := paths.Allow
 
-	allowDepth := func(prefix int, depth int) bool {
-		if args.Depth == 0 {
-			// always allow if maxDepth is unset
-			return true
+	var explained []*ExplainedResource
+	pivots := map[string]map[string]*PivotTable{}
+	for _, kubeVersion := range kubeVersions {
+		if args.Format == "table" || args.Format == "condensed" {
+			fmt.Printf("for kube version %s\n", kubeVersion.ToString())
 		}
-		return (depth - prefix) <= args.Depth
-	}
-	allowedPaths := slice.Map(func(p string) []string { return strings.Split(p, ".") }, args.Paths)
-	allowPath := func(path []string) bool {
-		if len(allowedPaths) == 0 {
-			return allowDepth(0, len(path))
+		spec, err := (&SpecSource{CRDPaths: args.CRDs}).Read(ctx, kubeVersion)
+		if err != nil {
+			return err
 		}
-		for _, prefix := range allowedPaths {
-			if IsPrefixOf(prefix, path) && allowDepth(len(prefix), len(path)) {
-				return true
-			}
+		typesByKindByApiVersion, err := spec.ResolveStructure()
+		if err != nil {
+			return err
+		}
+		allowResource, err := resolveResourceFilter(resources, spec)
+		if err != nil {
+			return err
 		}
-		return false
-	}
-
-	//table := NewPivotTable("?", args.KubeVersions)
-
-	for _, kubeVersion := range args.KubeVersions {
-		fmt.Printf("for kube version %s\n", kubeVersion)
-		spec := MustReadSwaggerSpecFromGithub(MustVersion(kubeVersion))
-		typesByKindByApiVersion := spec.ResolveStructure()
 
 		for _, resourceName := range slice.Sort(maps.Keys(typesByKindByApiVersion)) {
-			if !allowResource(resourceName) {
+			allowedByResource := slice.Filter(func(apiVersion string) bool {
+				return allowResource(resourceName, spec.DefinitionGVKs(apiVersion, resourceName))
+			}, slice.Sort(maps.Keys(typesByKindByApiVersion[resourceName])))
+			if len(allowedByResource) == 0 {
 				continue
 			}
-			typesByApiVersion := map[string]*ResolvedType{}
-			for apiVersion, resolvedType := range typesByKindByApiVersion[resourceName] {
-				if allowApiVersion(apiVersion) {
-					typesByApiVersion[apiVersion] = resolvedType
-				}
-			}
+			apiVersions := slice.Filter(allowApiVersion, allowedByResource)
 
 			switch args.Format {
 			case "table":
-				for apiVersion, resolvedType := range typesByApiVersion {
+				for _, apiVersion := range apiVersions {
 					fmt.Printf("%s %s:\n", apiVersion, resourceName)
-					fmt.Printf("%s\n\n", TableResource(resolvedType, allowPath))
+					fmt.Printf("%s\n\n", TableResource(typesByKindByApiVersion[resourceName][apiVersion], allowPath))
 				}
 			case "condensed":
 				fmt.Printf("%s:\n", resourceName)
-				for apiVersion, resolvedType := range typesByApiVersion {
-					fmt.Printf("%s\n\n", CondensedResource(apiVersion, resolvedType, allowPath))
+				for _, apiVersion := range apiVersions {
+					fmt.Printf("%s\n\n", CondensedResource(apiVersion, typesByKindByApiVersion[resourceName][apiVersion], allowPath))
+				}
+			case "pivot":
+				for _, apiVersion := range apiVersions {
+					if _, ok := pivots[resourceName]; !ok {
+						pivots[resourceName] = map[string]*PivotTable{}
+					}
+					if _, ok := pivots[resourceName][apiVersion]; !ok {
+						pivots[resourceName][apiVersion], err = NewPivotTable("Path", versionStrings)
+						if err != nil {
+							return err
+						}
+					}
+					if err := AddToPivot(pivots[resourceName][apiVersion], kubeVersion.ToString(), typesByKindByApiVersion[resourceName][apiVersion], allowPath); err != nil {
+						return err
+					}
 				}
 			default:
-				panic(errors.Errorf("invalid output format: %s", args.Format))
+				for _, apiVersion := range apiVersions {
+					explained = append(explained, ExplainResource(kubeVersion.ToString(), apiVersion, resourceName, typesByKindByApiVersion[resourceName][apiVersion], allowPath))
+				}
 			}
 		}
 	}
+
+	if explained == nil {
+		explained = []*ExplainedResource{}
+	}
+	switch args.Format {
+	case "pivot":
+		for _, resourceName := range slice.Sort(maps.Keys(pivots)) {
+			for _, apiVersion := range slice.Sort(maps.Keys(pivots[resourceName])) {
+				pivot, err := PivotResource(pivots[resourceName][apiVersion], args.DiffOnly)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("%s %s:\n", apiVersion, resourceName)
+				fmt.Printf("%s\n\n", pivot)
+			}
+		}
+	case "tree":
+		fmt.Print(strings.Join(slice.Map(TreeResource, explained), "\n"))
+	case "json":
+		bytes, err := json.MarshalWithOptions(explained, &json.MarshalOptions{EscapeHTML: false, Indent: true})
+		if err != nil {
+			return errors.Wrapf(err, "unable to marshal explain output to json")
+		}
+		fmt.Println(string(bytes))
+	case "yaml":
+		out, err := marshalYaml(explained)
+		if err != nil {
+			return err
+		}
+		fmt.Println(out)
+	}
+	return nil
+}
+
+// ExplainedResource is a resource's fields, for structured output
+type ExplainedResource struct {
+	KubeVersion string            `json:"kubeVersion"`
+	ApiVersion  string            `json:"apiVersion"`
+	Kind        string            `json:"kind"`
+	Description string            `json:"description,omitempty"`
+	Fields      []*ExplainedField `json:"fields"`
+}
+
+// ExplainedField is a field, in depth-first order with fields sorted by name.  Array items show up
+// as "[]" in paths, and map values as "additionalProperties".
+type ExplainedField struct {
+	Path            []string               `json:"path"`
+	Type            string                 `json:"type"`
+	Format          string                 `json:"format,omitempty"`
+	Required        bool                   `json:"required"`
+	Description     string                 `json:"description,omitempty"`
+	Constraints     map[string]interface{} `json:"constraints,omitempty"`
+	ValidationRules []*ValidationRule      `json:"validationRules,omitempty"`
+}
+
+func ExplainResource(kubeVersion string, apiVersion string, kind string, resolvedType *ResolvedType, allowPath func([]string) bool) *ExplainedResource {
+	out := &ExplainedResource{
+		KubeVersion: kubeVersion,
+		ApiVersion:  apiVersion,
+		Kind:        kind,
+		Description: resolvedType.Description,
+		Fields:      []*ExplainedField{},
+	}
+	var visit func(path []string, resolved *ResolvedType, required bool)
+	visit = func(path []string, resolved *ResolvedType, required bool) {
+		if len(path) > 0 && allowPath(path) {
+			constraints := resolved.Constraints.Keywords()
+			delete(constraints, validationsKeyword)
+			out.Fields = append(out.Fields, &ExplainedField{
+				Path:            path,
+				Type:            resolved.TypeName(),
+				Format:          resolved.Format,
+				Required:        required,
+				Description:     resolved.Description,
+				Constraints:     constraints,
+				ValidationRules: resolved.Constraints.ValidationRules(),
+			})
+		}
+		if resolved.Array != nil {
+			visit(slice.Append(path, []string{"[]"}), resolved.Array, false)
+		} else if resolved.Object != nil {
+			for _, fieldName := range slice.Sort(maps.Keys(resolved.Object.Properties)) {
+				visit(slice.Append(path, []string{fieldName}), resolved.Object.Properties[fieldName], resolved.Object.IsRequired(fieldName))
+			}
+			if resolved.Object.AdditionalProperties != nil {
+				visit(slice.Append(path, []string{"additionalProperties"}), resolved.Object.AdditionalProperties, false)
+			}
+		}
+	}
+	visit([]string{}, resolvedType, false)
+	return out
+}
+
+// AddToPivot adds a row per path, with the type in a column for the kube version
+func AddToPivot(table *PivotTable, kubeVersion string, resolvedType *ResolvedType, allowPath func([]string) bool) error {
+	for _, pair := range resolvedType.Paths([]string{}) {
+		path, resolved := pair.Fst, pair.Snd
+		if len(path) > 0 && allowPath(path) {
+			typeName := resolved.TypeName()
+			if resolved.Format != "" {
+				typeName += fmt.Sprintf(" (%s)", resolved.Format)
+			}
+			if err := table.Add(strings.Join(path, "."), kubeVersion, typeName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PivotResource renders a table with a row per path and a column per kube version; with diffOnly,
+// paths whose type is the same in every kube version are left out
+func PivotResource(table *PivotTable, diffOnly bool) (string, error) {
+	if diffOnly {
+		table = table.FilterRows(func(rowKey string, values [][]string) bool {
+			return slice.Any(func(v []string) bool { return formatCell(v) != formatCell(values[0]) }, values)
+		})
+	}
+	raw, err := table.ToRawTable(func(rowKey string, values [][]string) []string {
+		return slice.Cons(rowKey, slice.Map(formatCell, values))
+	})
+	if err != nil {
+		return "", err
+	}
+	return raw.ToFormattedTable(), nil
+}
+
+type explainTreeNode struct {
+	name     string
+	field    *ExplainedField
+	children []*explainTreeNode
+}
+
+// TreeResource draws a resource's fields as a tree.  Fields which were filtered out, but have
+// descendants which weren't, are drawn without a type.
+func TreeResource(resource *ExplainedResource) string {
+	root := &explainTreeNode{}
+	for _, field := range resource.Fields {
+		node := root
+		for _, name := range field.Path {
+			// fields are in depth-first order, so a node's parent is always its parent's last child
+			if len(node.children) == 0 || node.children[len(node.children)-1].name != name {
+				node.children = append(node.children, &explainTreeNode{name: name})
+			}
+			node = node.children[len(node.children)-1]
+		}
+		node.field = field
+	}
+	lines := []string{fmt.Sprintf("%s %s (kube %s)", resource.ApiVersion, resource.Kind, resource.KubeVersion)}
+	var draw func(node *explainTreeNode, indent string)
+	draw = func(node *explainTreeNode, indent string) {
+		for i, child := range node.children {
+			branch, childIndent := "├── ", "│   "
+			if i == len(node.children)-1 {
+				branch, childIndent = "└── ", "    "
+			}
+			lines = append(lines, indent+branch+child.label())
+			draw(child, indent+childIndent)
+		}
+	}
+	draw(root, "")
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func (n *explainTreeNode) label() string {
+	if n.field == nil {
+		return n.name
+	}
+	label := fmt.Sprintf("%s: %s", n.name, n.field.Type)
+	if n.field.Format != "" {
+		label += fmt.Sprintf(" (%s)", n.field.Format)
+	}
+	if n.field.Required {
+		label += " [required]"
+	}
+	return label
 }
 
 func TableResource(resolvedType *ResolvedType, allowPath func([]string) bool) string {
@@ -85,11 +304,15 @@ func TableResource(resolvedType *ResolvedType, allowPath func([]string) bool) st
 	table.SetRowLine(true)
 	table.SetAutoMergeCells(true)
 	table.SetColMinWidth(1, 100)
-	table.SetHeader([]string{"Path", "Type"})
+	table.SetHeader([]string{"Path", "Type", "Constraints"})
 	for _, pair := range resolvedType.Paths([]string{}) {
-		path, vType := pair.Fst, pair.Snd
+		path, resolved := pair.Fst, pair.Snd
 		if allowPath(path) {
-			table.Append([]string{strings.Join(path, "."), vType})
+			constraints := resolved.Constraints.Strings()
+			for _, rule := range resolved.Constraints.ValidationRules() {
+				constraints = append(constraints, "rule: "+rule.String())
+			}
+			table.Append([]string{strings.Join(path, "."), resolved.TypeName(), strings.Join(constraints, "\n")})
 		}
 	}
 	table.Render()
@@ -108,11 +331,18 @@ func IsPrefixOf[A comparable](xs []A, ys []A) bool {
 func CondensedResource(apiVersion string, resolvedType *ResolvedType, allowPath func([]string) bool) string {
 	lines := []string{apiVersion + ":"}
 	for _, pair := range resolvedType.Paths([]string{}) {
-		path, vType := pair.Fst, pair.Snd
+		path, resolved := pair.Fst, pair.Snd
 		if len(path) > 0 && allowPath(path) {
 			prefix := strings.Repeat("  ", len(path)-1)
 			typeString := fmt.Sprintf("%s%s", prefix, path[len(path)-1])
-			lines = append(lines, fmt.Sprintf("%-60s    %s", typeString, vType))
+			line := fmt.Sprintf("%-60s    %s", typeString, resolved.TypeName())
+			if constraints := resolved.Constraints.Strings(); len(constraints) > 0 {
+				line = fmt.Sprintf("%-80s    %s", line, strings.Join(constraints, " "))
+			}
+			lines = append(lines, line)
+			for _, rule := range resolved.Constraints.ValidationRules() {
+				lines = append(lines, fmt.Sprintf("%s  [rule] %s", prefix, rule.String()))
+			}
 		}
 	}
 	return strings.Join(lines, "\n")
@@ -0,0 +1,117 @@
+package swagger
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func RunExplainTests() {
+	spec := &KubeSpec{Definitions: map[string]*SpecType{
+		"io.k8s.api.apps.v1.Deployment": {
+			Description: "Deployment enables declarative updates.",
+			Type:        "object",
+			Properties: map[string]*SpecType{
+				"kind": {Type: "string"},
+				"spec": {Ref: "#/definitions/io.k8s.api.apps.v1.DeploymentSpec", Description: "Desired behavior."},
+			},
+			XKubernetesGroupVersionKind: []*GVK{{Group: "apps", Version: "v1", Kind: "Deployment"}},
+		},
+		"io.k8s.api.apps.v1.DeploymentSpec": {
+			Type:     "object",
+			Required: []string{"selector"},
+			Properties: map[string]*SpecType{
+				"replicas": {Type: "integer", Format: "int32", Description: "Number of desired pods.", Minimum: floatPointer(0)},
+				"selector": {Type: "object", AdditionalProperties: &SpecType{Type: "string"}},
+				"args":     {Type: "array", Items: &SpecType{Type: "string"}},
+			},
+		},
+	}}
+	structure, err := spec.ResolveStructure()
+	if err != nil {
+		panic(err)
+	}
+	deployment := structure["Deployment"]["io.k8s.api.apps.v1"]
+	pathAllower := func(paths []string, depth int) func([]string) bool {
+		filter, err := newPathFilter(paths, nil, depth)
+		Expect(err).To(Succeed())
+		return filter.Allow
+	}
+
+	Describe("Explain", func() {
+		It("lists fields depth first, sorted by name", func() {
+			explained := ExplainResource("1.30.2", "io.k8s.api.apps.v1", "Deployment", deployment, pathAllower(nil, 0))
+			Expect(explained.Description).To(Equal("Deployment enables declarative updates."))
+			Expect(explained.Fields).To(HaveLen(7))
+			Expect(explained.Fields[0].Path).To(Equal([]string{"kind"}))
+			Expect(explained.Fields[1]).To(Equal(&ExplainedField{Path: []string{"spec"}, Type: "object", Description: "Desired behavior.", Constraints: map[string]interface{}{}}))
+			Expect(explained.Fields[2].Path).To(Equal([]string{"spec", "args"}))
+			Expect(explained.Fields[3].Path).To(Equal([]string{"spec", "args", "[]"}))
+			Expect(explained.Fields[4]).To(Equal(&ExplainedField{
+				Path:        []string{"spec", "replicas"},
+				Type:        "integer",
+				Format:      "int32",
+				Description: "Number of desired pods.",
+				Constraints: map[string]interface{}{"minimum": float64(0)},
+			}))
+			Expect(explained.Fields[5].Path).To(Equal([]string{"spec", "selector"}))
+			Expect(explained.Fields[5].Required).To(BeTrue())
+			Expect(explained.Fields[6].Path).To(Equal([]string{"spec", "selector", "additionalProperties"}))
+		})
+
+		It("draws a tree, including the ancestors of matching paths", func() {
+			explained := ExplainResource("1.30.2", "io.k8s.api.apps.v1", "Deployment", deployment, pathAllower([]string{"spec.selector", "spec.args"}, 0))
+			Expect(TreeResource(explained)).To(Equal(explainTree[1:]))
+		})
+
+		It("pivots paths against kube versions, optionally showing only differences", func() {
+			newer := &KubeSpec{Definitions: map[string]*SpecType{
+				"io.k8s.api.apps.v1.Deployment": {
+					Type: "object",
+					Properties: map[string]*SpecType{
+						"kind": {Type: "string"},
+						"spec": {Type: "object", Properties: map[string]*SpecType{
+							"replicas": {Type: "integer", Format: "int64"},
+							"paused":   {Type: "boolean"},
+						}},
+					},
+					XKubernetesGroupVersionKind: []*GVK{{Group: "apps", Version: "v1", Kind: "Deployment"}},
+				},
+			}}
+			newerStructure, err := newer.ResolveStructure()
+			Expect(err).To(Succeed())
+
+			table, err := NewPivotTable("Path", []string{"1.29.6", "1.30.2"})
+			Expect(err).To(Succeed())
+			Expect(AddToPivot(table, "1.29.6", deployment, pathAllower([]string{"spec"}, 1))).To(Succeed())
+			Expect(AddToPivot(table, "1.30.2", newerStructure["Deployment"]["io.k8s.api.apps.v1"], pathAllower([]string{"spec"}, 1))).To(Succeed())
+			Expect(PivotResource(table, true)).To(Equal(explainPivotDiffOnly[1:]))
+		})
+	})
+}
+
+func floatPointer(f float64) *float64 {
+	return &f
+}
+
+var explainTree = `
+io.k8s.api.apps.v1 Deployment (kube 1.30.2)
+└── spec
+    ├── args: array
+    │   └── []: string
+    └── selector: object [required]
+        └── additionalProperties: string
+`
+
+var explainPivotDiffOnly = `
++---------------+-----------------+-----------------+
+|     PATH      |     1.29.6      |     1.30.2      |
++---------------+-----------------+-----------------+
+| spec.args     | array           |                 |
++---------------+-----------------+-----------------+
+| spec.paused   |                 | boolean         |
++---------------+-----------------+-----------------+
+| spec.replicas | integer (int32) | integer (int64) |
++---------------+-----------------+-----------------+
+| spec.selector | object          |                 |
++---------------+-----------------+-----------------+
+`
@@ -0,0 +1,349 @@
+package swagger
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/mattfenwick/collections/pkg/set"
+	"github.com/mattfenwick/collections/pkg/slice"
+	"github.com/mattfenwick/kubectl-schema/pkg/utils"
+)
+
+// namePattern matches resource names and api versions.  It's either a glob, as in path.Match --
+// so a name without wildcards matches only itself -- or a regular expression between slashes,
+// such as `/^v1(alpha|beta)/`, which matches anywhere in a name unless it's anchored.
+type namePattern struct {
+	glob  string
+	regex *regexp.Regexp
+}
+
+func isNamePatternRegex(pattern string) bool {
+	return len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/")
+}
+
+func parseNamePattern(flag string, pattern string) (*namePattern, error) {
+	if isNamePatternRegex(pattern) {
+		regex, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, utils.NewUsageError("invalid --%s regular expression '%s': %s", flag, pattern, err.Error())
+		}
+		return &namePattern{regex: regex}, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, utils.NewUsageError("invalid --%s glob '%s': %s", flag, pattern, err.Error())
+	}
+	return &namePattern{glob: pattern}, nil
+}
+
+func (p *namePattern) matches(name string) bool {
+	if p.regex != nil {
+		return p.regex.MatchString(name)
+	}
+	// the pattern was checked when it was parsed, so there can't be an error
+	matched, _ := path.Match(p.glob, name)
+	return matched
+}
+
+func parseNamePatterns(flag string, patterns []string) ([]*namePattern, error) {
+	var out []*namePattern
+	for _, pattern := range patterns {
+		parsed, err := parseNamePattern(flag, pattern)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, parsed)
+	}
+	return out, nil
+}
+
+func anyMatches(patterns []*namePattern, name string) bool {
+	for _, pattern := range patterns {
+		if pattern.matches(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// nameFilter allows names which match any include -- or every name, if there are no includes --
+// unless they also match an exclude.  flag and excludeFlag are used in error messages.
+func nameFilter(flag string, includes []string, excludeFlag string, excludes []string) (func(string) bool, error) {
+	includePatterns, err := parseNamePatterns(flag, includes)
+	if err != nil {
+		return nil, err
+	}
+	excludePatterns, err := parseNamePatterns(excludeFlag, excludes)
+	if err != nil {
+		return nil, err
+	}
+	return func(name string) bool {
+		return (len(includePatterns) == 0 || anyMatches(includePatterns, name)) && !anyMatches(excludePatterns, name)
+	}, nil
+}
+
+// resourceFilter matches --resource and --exclude-resource values.  Globs and regular expressions
+// match kinds, as with other names.  Anything else is a kind, or a name the way kubectl accepts it,
+// such as `deploy` or `ingresses.v1.networking.k8s.io`, which is resolved against each spec's
+// ResourceNameIndex.
+type resourceFilter struct {
+	includes []*resourcePattern
+	excludes []*resourcePattern
+}
+
+type resourcePattern struct {
+	flag string
+	// exactly one of name and pattern is set
+	name    string
+	pattern *namePattern
+}
+
+func parseResourcePatterns(flag string, values []string) ([]*resourcePattern, error) {
+	var out []*resourcePattern
+	for _, value := range values {
+		if isNamePatternRegex(value) || strings.ContainsAny(value, `*?[\`) {
+			pattern, err := parseNamePattern(flag, value)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, &resourcePattern{flag: flag, pattern: pattern})
+		} else {
+			out = append(out, &resourcePattern{flag: flag, name: value})
+		}
+	}
+	return out, nil
+}
+
+func newResourceFilter(resources []string, excludeResources []string) (*resourceFilter, error) {
+	includes, err := parseResourcePatterns("resource", resources)
+	if err != nil {
+		return nil, err
+	}
+	excludes, err := parseResourcePatterns("exclude-resource", excludeResources)
+	if err != nil {
+		return nil, err
+	}
+	return &resourceFilter{includes: includes, excludes: excludes}, nil
+}
+
+// resolve returns a function of a kind and its GVKs; kinds which aren't resources, such as
+// PodSpec, don't have any.  Names which are exactly a kind only match that kind.  A nil index
+// treats every name as a kind.
+func (p *resourcePattern) resolve(index *ResourceNameIndex) (func(string, []*GVK) bool, error) {
+	if p.pattern != nil {
+		return func(kind string, gvks []*GVK) bool { return p.pattern.matches(kind) }, nil
+	}
+	if index == nil || index.HasKind(p.name) {
+		return func(kind string, gvks []*GVK) bool { return kind == p.name }, nil
+	}
+	resolved, err := index.Resolve(p.flag, p.name)
+	if err != nil {
+		return nil, err
+	}
+	resolvedGVKs := set.FromSlice(slice.Map(func(gvk *GVK) GVK { return *gvk }, resolved))
+	return func(kind string, gvks []*GVK) bool {
+		return kind == p.name || slice.Any(func(gvk *GVK) bool { return resolvedGVKs.Contains(*gvk) }, gvks)
+	}, nil
+}
+
+func resolveResourcePatterns(patterns []*resourcePattern, index *ResourceNameIndex) ([]func(string, []*GVK) bool, error) {
+	var out []func(string, []*GVK) bool
+	for _, pattern := range patterns {
+		resolved, err := pattern.resolve(index)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, resolved)
+	}
+	return out, nil
+}
+
+// Resolve allows kinds which match any include -- or every kind, if there are no includes --
+// unless they also match an exclude
+func (f *resourceFilter) Resolve(index *ResourceNameIndex) (func(kind string, gvks []*GVK) bool, error) {
+	includes, err := resolveResourcePatterns(f.includes, index)
+	if err != nil {
+		return nil, err
+	}
+	excludes, err := resolveResourcePatterns(f.excludes, index)
+	if err != nil {
+		return nil, err
+	}
+	matchesAny := func(matchers []func(string, []*GVK) bool, kind string, gvks []*GVK) bool {
+		return slice.Any(func(matches func(string, []*GVK) bool) bool { return matches(kind, gvks) }, matchers)
+	}
+	return func(kind string, gvks []*GVK) bool {
+		return (len(includes) == 0 || matchesAny(includes, kind, gvks)) && !matchesAny(excludes, kind, gvks)
+	}, nil
+}
+
+// resolveResourceFilter resolves a resource filter against a spec's names
+func resolveResourceFilter(filter *resourceFilter, spec *KubeSpec) (func(string, []*GVK) bool, error) {
+	index, err := spec.ResourceNameIndex()
+	if err != nil {
+		return nil, err
+	}
+	return filter.Resolve(index)
+}
+
+// apiVersionFilter matches api versions as they're shown, such as `networking.k8s.io.v1`; globs
+// may also be written the way they'd appear in a manifest, such as `networking.k8s.io/v1`
+func apiVersionFilter(apiVersions []string, excludeApiVersions []string) (func(string) bool, error) {
+	dotted := func(patterns []string) []string {
+		var out []string
+		for _, pattern := range patterns {
+			if !strings.HasPrefix(pattern, "/") {
+				pattern = strings.ReplaceAll(pattern, "/", ".")
+			}
+			out = append(out, pattern)
+		}
+		return out
+	}
+	return nameFilter("api-version", dotted(apiVersions), "exclude-api-version", dotted(excludeApiVersions))
+}
+
+// gvkFilter combines api version and resource filters, for commands which work with GVKs
+type gvkFilter struct {
+	allowApiVersion func(string) bool
+	resources       *resourceFilter
+}
+
+func newGVKFilter(apiVersions []string, excludeApiVersions []string, resources []string, excludeResources []string) (*gvkFilter, error) {
+	allowApiVersion, err := apiVersionFilter(apiVersions, excludeApiVersions)
+	if err != nil {
+		return nil, err
+	}
+	resourceFilter, err := newResourceFilter(resources, excludeResources)
+	if err != nil {
+		return nil, err
+	}
+	return &gvkFilter{allowApiVersion: allowApiVersion, resources: resourceFilter}, nil
+}
+
+func (f *gvkFilter) Resolve(index *ResourceNameIndex) (func(*GVK) bool, error) {
+	allowResource, err := f.resources.Resolve(index)
+	if err != nil {
+		return nil, err
+	}
+	return func(gvk *GVK) bool {
+		return f.allowApiVersion(gvk.GroupVersion()) && allowResource(gvk.Kind, []*GVK{gvk})
+	}, nil
+}
+
+// pathPattern is a path with components separated by '.'.  Each component is a glob, as in
+// path.Match, except for `**`, which matches any number of components, and `[]`, which matches
+// array items.
+type pathPattern []string
+
+func parsePathPattern(flag string, pattern string) (pathPattern, error) {
+	components := strings.Split(pattern, ".")
+	for _, component := range components {
+		if component == "[]" {
+			continue
+		}
+		if _, err := path.Match(component, ""); err != nil {
+			return nil, utils.NewUsageError("invalid --%s glob '%s': %s", flag, pattern, err.Error())
+		}
+	}
+	return components, nil
+}
+
+func (p pathPattern) matches(fieldPath []string) bool {
+	if len(p) == 0 {
+		return len(fieldPath) == 0
+	}
+	if p[0] == "**" {
+		return p[1:].matches(fieldPath) || (len(fieldPath) > 0 && p.matches(fieldPath[1:]))
+	}
+	if len(fieldPath) == 0 {
+		return false
+	}
+	return matchPathComponent(p[0], fieldPath[0]) && p[1:].matches(fieldPath[1:])
+}
+
+// couldMatchBelow is true if the pattern could match a descendant of fieldPath
+func (p pathPattern) couldMatchBelow(fieldPath []string) bool {
+	if len(fieldPath) == 0 {
+		return len(p) > 0
+	}
+	if len(p) == 0 {
+		return false
+	}
+	if p[0] == "**" {
+		return p[1:].couldMatchBelow(fieldPath) || p.couldMatchBelow(fieldPath[1:])
+	}
+	return matchPathComponent(p[0], fieldPath[0]) && p[1:].couldMatchBelow(fieldPath[1:])
+}
+
+func matchPathComponent(pattern string, component string) bool {
+	if pattern == "[]" {
+		return component == "[]"
+	}
+	// the pattern was checked when it was parsed, so there can't be an error
+	matched, _ := path.Match(pattern, component)
+	return matched
+}
+
+// pathFilter allows paths which match an include pattern, along with their descendants -- up to
+// maxDepth levels below the match, unless maxDepth is 0 -- unless they, or one of their ancestors,
+// match an exclude pattern
+type pathFilter struct {
+	includes []pathPattern
+	excludes []pathPattern
+	maxDepth int
+}
+
+func newPathFilter(paths []string, excludePaths []string, maxDepth int) (*pathFilter, error) {
+	filter := &pathFilter{maxDepth: maxDepth}
+	for _, p := range paths {
+		pattern, err := parsePathPattern("path", p)
+		if err != nil {
+			return nil, err
+		}
+		filter.includes = append(filter.includes, pattern)
+	}
+	for _, p := range excludePaths {
+		pattern, err := parsePathPattern("exclude-path", p)
+		if err != nil {
+			return nil, err
+		}
+		filter.excludes = append(filter.excludes, pattern)
+	}
+	return filter, nil
+}
+
+func (f *pathFilter) allowDepth(prefix int, depth int) bool {
+	// always allow if maxDepth is unset
+	return f.maxDepth == 0 || (depth-prefix) <= f.maxDepth
+}
+
+func (f *pathFilter) Allow(fieldPath []string) bool {
+	for i := 0; i <= len(fieldPath); i++ {
+		for _, pattern := range f.excludes {
+			if pattern.matches(fieldPath[:i]) {
+				return false
+			}
+		}
+	}
+	if len(f.includes) == 0 {
+		return f.allowDepth(0, len(fieldPath))
+	}
+	for i := 0; i <= len(fieldPath); i++ {
+		for _, pattern := range f.includes {
+			if pattern.matches(fieldPath[:i]) && f.allowDepth(i, len(fieldPath)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsAncestor is true if fieldPath is above a path that could be allowed
+func (f *pathFilter) IsAncestor(fieldPath []string) bool {
+	for _, pattern := range f.includes {
+		if pattern.couldMatchBelow(fieldPath) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,267 @@
+package swagger
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/mattfenwick/collections/pkg/json"
+	"github.com/mattfenwick/collections/pkg/slice"
+	"github.com/mattfenwick/kubectl-schema/pkg/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/exp/maps"
+)
+
+// kindFilter resolves a resource filter without an index, so that every name is a kind
+func kindFilter(resources []string, excludeResources []string) (func(string) bool, error) {
+	filter, err := newResourceFilter(resources, excludeResources)
+	if err != nil {
+		return nil, err
+	}
+	allow, err := filter.Resolve(nil)
+	if err != nil {
+		return nil, err
+	}
+	return func(kind string) bool { return allow(kind, nil) }, nil
+}
+
+// captureStdout returns what f prints
+func captureStdout(f func() error) (string, error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	output := make(chan string)
+	go func() {
+		bytes, _ := io.ReadAll(reader)
+		output <- string(bytes)
+	}()
+	err = f()
+	os.Stdout = stdout
+	_ = writer.Close()
+	return <-output, err
+}
+
+// filterFixtureSpec has Deployments in apps/v1 and apps/v1beta1, and a StatefulSet in apps/v1,
+// each with a spec whose paused field has the given type
+func filterFixtureSpec(pausedType string) *KubeSpec {
+	spec := &KubeSpec{Swagger: "2.0", Definitions: map[string]*SpecType{}}
+	for _, gvk := range []*GVK{
+		{Group: "apps", Version: "v1", Kind: "Deployment"},
+		{Group: "apps", Version: "v1beta1", Kind: "Deployment"},
+		{Group: "apps", Version: "v1", Kind: "StatefulSet"},
+	} {
+		name := "io.k8s.api.apps." + gvk.Version + "." + gvk.Kind
+		spec.Definitions[name] = &SpecType{
+			Type: "object",
+			Properties: map[string]*SpecType{
+				"apiVersion": {Type: "string"},
+				"kind":       {Type: "string"},
+				"spec":       {Ref: "#/definitions/" + name + "Spec"},
+			},
+			XKubernetesGroupVersionKind: []*GVK{gvk},
+		}
+		spec.Definitions[name+"Spec"] = &SpecType{
+			Type:       "object",
+			Properties: map[string]*SpecType{"replicas": {Type: "integer"}, "paused": {Type: pausedType}},
+		}
+	}
+	return spec
+}
+
+func RunFilterTests() {
+	Describe("Name filters", func() {
+		It("matches globs and regular expressions, and leaves out excludes", func() {
+			allow, err := kindFilter([]string{"*Policy", "/^Pod/"}, []string{"PodList"})
+			Expect(err).To(Succeed())
+			Expect(allow("NetworkPolicy")).To(BeTrue())
+			Expect(allow("Pod")).To(BeTrue())
+			Expect(allow("PodTemplate")).To(BeTrue())
+			Expect(allow("PodList")).To(BeFalse())
+			Expect(allow("Deployment")).To(BeFalse())
+		})
+
+		It("allows everything but excludes if there are no includes", func() {
+			allow, err := kindFilter(nil, []string{"*List"})
+			Expect(err).To(Succeed())
+			Expect(allow("Deployment")).To(BeTrue())
+			Expect(allow("DeploymentList")).To(BeFalse())
+		})
+
+		It("accepts api versions in manifest form", func() {
+			allow, err := apiVersionFilter([]string{"*.k8s.io/v1beta*"}, nil)
+			Expect(err).To(Succeed())
+			Expect(allow("networking.k8s.io.v1beta1")).To(BeTrue())
+			Expect(allow("networking.k8s.io.v1")).To(BeFalse())
+		})
+
+		It("rejects invalid patterns", func() {
+			_, err := kindFilter([]string{"["}, nil)
+			Expect(utils.ExitCode(err)).To(Equal(utils.ExitCodeUsage))
+			_, err = apiVersionFilter(nil, []string{"/(/"})
+			Expect(err).To(MatchError(ContainSubstring("--exclude-api-version")))
+		})
+	})
+
+	Describe("Path filters", func() {
+		split := func(path string) []string {
+			return strings.Split(path, ".")
+		}
+
+		It("matches any number of components with **, and includes descendants", func() {
+			filter, err := newPathFilter([]string{"spec.template.**.securityContext"}, nil, 0)
+			Expect(err).To(Succeed())
+			Expect(filter.Allow(split("spec.template.spec.securityContext"))).To(BeTrue())
+			Expect(filter.Allow(split("spec.template.spec.containers.[].securityContext.runAsUser"))).To(BeTrue())
+			Expect(filter.Allow(split("spec.template.spec.containers.[].image"))).To(BeFalse())
+			Expect(filter.Allow(split("spec.template"))).To(BeFalse())
+			Expect(filter.IsAncestor(split("spec.template"))).To(BeTrue())
+			Expect(filter.IsAncestor(split("status"))).To(BeFalse())
+		})
+
+		It("matches one component with *, and limits depth below the match", func() {
+			filter, err := newPathFilter([]string{"spec.*.name"}, nil, 1)
+			Expect(err).To(Succeed())
+			Expect(filter.Allow(split("spec.containers.name"))).To(BeTrue())
+			Expect(filter.Allow(split("spec.containers.name.first"))).To(BeTrue())
+			Expect(filter.Allow(split("spec.containers.name.first.initial"))).To(BeFalse())
+			Expect(filter.Allow(split("spec.template.spec.name"))).To(BeFalse())
+		})
+
+		It("leaves out excluded paths and everything under them", func() {
+			filter, err := newPathFilter([]string{"metadata"}, []string{"metadata.managedFields"}, 0)
+			Expect(err).To(Succeed())
+			Expect(filter.Allow(split("metadata.labels"))).To(BeTrue())
+			Expect(filter.Allow(split("metadata.managedFields"))).To(BeFalse())
+			Expect(filter.Allow(split("metadata.managedFields.[].manager"))).To(BeFalse())
+		})
+	})
+	Describe("Commands", func() {
+		ctx := context.Background()
+		var dir string
+
+		BeforeEach(func() {
+			dir = GinkgoT().TempDir()
+			previous, wasSet := os.LookupEnv(DataDirEnvVar)
+			Expect(os.Setenv(DataDirEnvVar, dir)).To(Succeed())
+			DeferCleanup(func() {
+				if wasSet {
+					_ = os.Setenv(DataDirEnvVar, previous)
+				} else {
+					_ = os.Unsetenv(DataDirEnvVar)
+				}
+			})
+			for version, pausedType := range map[string]string{"1.30.2": "boolean", "1.31.0": "string"} {
+				Expect(json.MarshalToFile(filterFixtureSpec(pausedType), path.Join(dir, version+"-swagger-spec.json"))).To(Succeed())
+			}
+		})
+
+		It("compare leaves out excluded api versions, resources and paths", func() {
+			args := &CompareResourceArgs{
+				KubeVersions:       []string{"1.30.2", "1.31.0"},
+				ExcludeApiVersions: []string{"*beta*"},
+				ExcludeResources:   []string{"*Spec", "StatefulSet"},
+			}
+			output, err := captureStdout(func() error { return RunCompareResource(ctx, args) })
+			Expect(err).To(Succeed())
+			Expect(strings.Fields(output)).To(Equal([]string{
+				"comparing", "Deployment:", "1.30.2@io.k8s.api.apps.v1", "vs.", "1.31.0@io.k8s.api.apps.v1",
+				"<>", "spec.paused",
+			}))
+
+			args.Paths = []string{"spec"}
+			args.ExcludePaths = []string{"spec.paused"}
+			output, err = captureStdout(func() error { return RunCompareResource(ctx, args) })
+			Expect(err).To(Succeed())
+			Expect(output).NotTo(ContainSubstring("spec.paused"))
+		})
+
+		It("compare goes through api versions in order", func() {
+			args := &CompareResourceArgs{KubeVersions: []string{"1.30.2", "1.31.0"}, Resources: []string{"Deployment"}}
+			for i := 0; i < 10; i++ {
+				output, err := captureStdout(func() error { return RunCompareResource(ctx, args) })
+				Expect(err).To(Succeed())
+				comparisons := slice.Filter(func(line string) bool { return strings.HasPrefix(line, "comparing") }, strings.Split(output, "\n"))
+				Expect(comparisons).To(Equal([]string{
+					"comparing Deployment: 1.30.2@io.k8s.api.apps.v1 vs. 1.31.0@io.k8s.api.apps.v1",
+					"comparing Deployment: 1.30.2@io.k8s.api.apps.v1 vs. 1.31.0@io.k8s.api.apps.v1beta1",
+					"comparing Deployment: 1.30.2@io.k8s.api.apps.v1beta1 vs. 1.31.0@io.k8s.api.apps.v1",
+					"comparing Deployment: 1.30.2@io.k8s.api.apps.v1beta1 vs. 1.31.0@io.k8s.api.apps.v1beta1",
+				}))
+			}
+		})
+
+		It("skeleton leaves out excluded api versions, resources and paths", func() {
+			args := &SkeletonArgs{
+				KubeVersion:        "1.30.2",
+				Resources:          []string{"Deployment", "StatefulSet"},
+				ExcludeApiVersions: []string{"*beta*"},
+				ExcludeResources:   []string{"StatefulSet"},
+				ExcludePaths:       []string{"spec.paused"},
+			}
+			output, err := captureStdout(func() error { return RunSkeleton(ctx, args) })
+			Expect(err).To(Succeed())
+			Expect(output).To(Equal("apiVersion: apps/v1\nkind: Deployment\nspec:\n  replicas: 0\n"))
+		})
+
+		It("sample leaves out excluded api versions and resources", func() {
+			args := &SampleArgs{
+				KubeVersion:        "1.30.2",
+				Count:              1,
+				SeedSet:            true,
+				MaxItems:           2,
+				Format:             "json",
+				ExcludeApiVersions: []string{"apps/v1beta1"},
+				ExcludeResources:   []string{"/Spec$/", "Deployment"},
+			}
+			output, err := captureStdout(func() error { return RunSample(ctx, args) })
+			Expect(err).To(Succeed())
+			sample, err := json.ParseString[map[string]interface{}](output)
+			Expect(err).To(Succeed())
+			Expect(*sample).To(HaveKeyWithValue("kind", "StatefulSet"))
+		})
+
+		It("export jsonschema leaves out excluded api versions and resources", func() {
+			args := &ExportJsonSchemaArgs{
+				KubeVersions:       []string{"1.30.2"},
+				ExcludeApiVersions: []string{"*beta*"},
+				ExcludeResources:   []string{"StatefulSet"},
+				OutputDir:          dir,
+			}
+			Expect(RunExportJsonSchema(ctx, args)).To(Succeed())
+			entries, err := os.ReadDir(path.Join(dir, JsonSchemaDirectoryName("1.30.2", false)))
+			Expect(err).To(Succeed())
+			Expect(slice.Map(func(e os.DirEntry) string { return e.Name() }, entries)).To(Equal([]string{"deployment-apps-v1.json"}))
+		})
+
+		It("export typescript leaves out excluded api versions and resources", func() {
+			args := &ExportCodeArgs{
+				KubeVersion:        "1.30.2",
+				ExcludeApiVersions: []string{"*beta*"},
+				ExcludeResources:   []string{"Deployment"},
+			}
+			output, err := captureStdout(func() error { return RunExportTypeScript(ctx, args) })
+			Expect(err).To(Succeed())
+			Expect(output).To(ContainSubstring("StatefulSet"))
+			Expect(output).NotTo(ContainSubstring("Deployment"))
+		})
+
+		It("trim leaves out excluded api versions and resources", func() {
+			args := &TrimArgs{
+				KubeVersion:        "1.30.2",
+				Resources:          []string{"Deployment"},
+				ExcludeApiVersions: []string{"*beta*"},
+				Output:             path.Join(dir, "trimmed.json"),
+			}
+			Expect(RunTrim(ctx, args)).To(Succeed())
+			trimmed, err := json.ParseFile[KubeSpec](args.Output)
+			Expect(err).To(Succeed())
+			Expect(slice.Sort(maps.Keys(trimmed.Definitions))).To(Equal([]string{"io.k8s.api.apps.v1.Deployment", "io.k8s.api.apps.v1.DeploymentSpec"}))
+		})
+	})
+}
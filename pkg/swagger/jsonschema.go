@@ -0,0 +1,306 @@
+package swagger
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/mattfenwick/collections/pkg/json"
+	"github.com/mattfenwick/collections/pkg/set"
+	"github.com/mattfenwick/collections/pkg/slice"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/exp/maps"
+)
+
+type ExportJsonSchemaArgs struct {
+	KubeVersions []string
+	ApiVersions  []string
+	Resources    []string
+	OutputDir    string
+	CRDs         []string
+	// ExcludeApiVersions and ExcludeResources are patterns to leave out, even if they're included
+	ExcludeApiVersions []string
+	ExcludeResources   []string
+}
+
+func RunExportJsonSchema(ctx context.Context, args *ExportJsonSchemaArgs) error {
+	filter, err := newGVKFilter(args.ApiVersions, args.ExcludeApiVersions, args.Resources, args.ExcludeResources)
+	if err != nil {
+		return err
+	}
+	versions, err := ParseKubeVersions(args.KubeVersions)
+	if err != nil {
+		return err
+	}
+
+	for i, kubeVersion := range args.KubeVersions {
+		spec, err := (&SpecSource{CRDPaths: args.CRDs}).Read(ctx, versions[i])
+		if err != nil {
+			return err
+		}
+		definitions, err := spec.ResolveDefinitions()
+		if err != nil {
+			return err
+		}
+		index, err := spec.ResourceNameIndex()
+		if err != nil {
+			return err
+		}
+		include, err := filter.Resolve(index)
+		if err != nil {
+			return err
+		}
+
+		for _, strict := range []bool{false, true} {
+			dir := path.Join(args.OutputDir, JsonSchemaDirectoryName(kubeVersion, strict))
+			if err := os.MkdirAll(dir, 0777); err != nil {
+				return errors.Wrapf(err, "unable to mkdir %s", dir)
+			}
+
+			count := 0
+			for _, name := range slice.Sort(maps.Keys(spec.Definitions)) {
+				for _, gvk := range spec.Definitions[name].XKubernetesGroupVersionKind {
+					if !include(gvk) {
+						continue
+					}
+					schema, err := ToJsonSchema(definitions, definitions[name], strict)
+					if err != nil {
+						return errors.Wrapf(err, "unable to build json schema for %s", gvk.ToString())
+					}
+					schemaPath := path.Join(dir, JsonSchemaFileName(gvk))
+					logrus.Debugf("writing json schema for %s to %s", gvk.ToString(), schemaPath)
+					if err := json.MarshalToFileOptions(schema, schemaPath, &json.MarshalOptions{EscapeHTML: false, Indent: true, Sort: true}); err != nil {
+						return errors.Wrapf(err, "unable to write json schema to %s", schemaPath)
+					}
+					count++
+				}
+			}
+			logrus.Infof("wrote %d json schemas to %s", count, dir)
+		}
+	}
+	return nil
+}
+
+// JsonSchemaDirectoryName follows the layout used by kubeconform and yaml-language-server schema catalogs
+func JsonSchemaDirectoryName(kubeVersion string, strict bool) string {
+	if strict {
+		return fmt.Sprintf("v%s-standalone-strict", kubeVersion)
+	}
+	return fmt.Sprintf("v%s-standalone", kubeVersion)
+}
+
+// JsonSchemaFileName follows the layout used by kubeconform and yaml-language-server schema catalogs:
+// lowercased kind, first component of the group (if there is a group), and version
+func JsonSchemaFileName(gvk *GVK) string {
+	pieces := []string{gvk.Kind}
+	if gvk.Group != "" {
+		pieces = append(pieces, strings.Split(gvk.Group, ".")[0])
+	}
+	pieces = append(pieces, gvk.Version)
+	return strings.ToLower(strings.Join(pieces, "-")) + ".json"
+}
+
+// ToJsonSchema builds a standalone JSON schema for a type.  Since circular references can't be inlined,
+// they're bundled under `definitions` and referenced with `$ref`.  In strict mode, objects with
+// properties don't allow additional properties.
+func ToJsonSchema(definitions map[string]*ResolvedType, resolved *ResolvedType, strict bool) (map[string]interface{}, error) {
+	builder := &jsonSchemaBuilder{definitions: definitions, strict: strict, referenced: set.NewSet[string](nil)}
+	schema, err := builder.build(resolved)
+	if err != nil {
+		return nil, err
+	}
+
+	bundled := map[string]interface{}{}
+	for len(builder.pending) > 0 {
+		name := builder.pending[0]
+		builder.pending = builder.pending[1:]
+		bundled[name], err = builder.build(definitions[name])
+		if err != nil {
+			return nil, errors.Wrapf(err, "in definition %s", name)
+		}
+	}
+	if len(bundled) > 0 {
+		schema["definitions"] = bundled
+	}
+	return schema, nil
+}
+
+type jsonSchemaBuilder struct {
+	definitions map[string]*ResolvedType
+	strict      bool
+	referenced  *set.Set[string]
+	pending     []string
+}
+
+func (b *jsonSchemaBuilder) build(resolved *ResolvedType) (map[string]interface{}, error) {
+	schema := map[string]interface{}{}
+	if resolved.Description != "" {
+		schema["description"] = resolved.Description
+	}
+
+	if resolved.Circular != "" {
+		if _, ok := b.definitions[resolved.Circular]; !ok {
+			return nil, errors.Errorf("unable to find definition for %s", resolved.Circular)
+		}
+		b.reference(resolved.Circular)
+		schema["$ref"] = "#/definitions/" + resolved.Circular
+	} else if resolved.Primitive != "" {
+		if resolved.Primitive == "string" && resolved.Format == "int-or-string" {
+			schema["oneOf"] = []interface{}{
+				map[string]interface{}{"type": "string"},
+				map[string]interface{}{"type": "integer"},
+			}
+		} else {
+			schema["type"] = resolved.Primitive
+			if resolved.Format != "" {
+				schema["format"] = resolved.Format
+			}
+		}
+	} else if resolved.Array != nil {
+		items, err := b.build(resolved.Array)
+		if err != nil {
+			return nil, err
+		}
+		schema["type"] = "array"
+		schema["items"] = items
+	} else if resolved.Object != nil {
+		schema["type"] = "object"
+		if len(resolved.Object.Properties) > 0 {
+			properties := map[string]interface{}{}
+			for name, prop := range resolved.Object.Properties {
+				property, err := b.build(prop)
+				if err != nil {
+					return nil, errors.Wrapf(err, "at %s", name)
+				}
+				properties[name] = property
+			}
+			schema["properties"] = properties
+		}
+		if len(resolved.Object.Required) > 0 {
+			schema["required"] = resolved.Object.Required
+		}
+		if resolved.Object.AdditionalProperties != nil {
+			additionalProperties, err := b.build(resolved.Object.AdditionalProperties)
+			if err != nil {
+				return nil, err
+			}
+			schema["additionalProperties"] = additionalProperties
+		} else if b.strict && len(resolved.Object.Properties) > 0 && !resolved.Constraints.preservesUnknownFields() {
+			schema["additionalProperties"] = false
+		}
+	}
+	keywords := resolved.Constraints.Keywords()
+	for _, keyword := range slice.Sort(maps.Keys(keywords)) {
+		switch {
+		// kubernetes extensions aren't json schema, and openapi's `nullable` is handled below
+		case strings.HasPrefix(keyword, "x-kubernetes-") || keyword == "nullable":
+		case keyword == "allOf" || keyword == "anyOf" || keyword == "oneOf" || keyword == "not":
+			subschemas, ok, err := b.buildSubschemas(keywords[keyword])
+			if err != nil {
+				return nil, err
+			} else if ok {
+				schema[keyword] = subschemas
+			} else {
+				logrus.Warnf("dropping %s, since it refers to a definition which can't be found", keyword)
+			}
+		default:
+			schema[keyword] = keywords[keyword]
+		}
+	}
+	if resolved.Constraints != nil && isTrue(resolved.Constraints.Nullable) {
+		makeNullable(schema)
+	}
+	return schema, nil
+}
+
+// buildSubschemas converts the raw schemas under `allOf`, `anyOf`, `oneOf` and `not` the same way as
+// everything else: $refs are bundled under `definitions`, and extensions are dropped.  Not ok if a
+// $ref can't be found.
+func (b *jsonSchemaBuilder) buildSubschemas(raw interface{}) (interface{}, bool, error) {
+	parsed, err := reparse[interface{}](raw)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "unable to reparse subschemas")
+	}
+	converted, ok := b.convertSubschema(*parsed)
+	return converted, ok, nil
+}
+
+func (b *jsonSchemaBuilder) convertSubschema(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case []interface{}:
+		var out []interface{}
+		for _, item := range v {
+			converted, ok := b.convertSubschema(item)
+			if !ok {
+				return nil, false
+			}
+			out = append(out, converted)
+		}
+		return out, true
+	case map[string]interface{}:
+		out := map[string]interface{}{}
+		for key, item := range v {
+			switch {
+			case strings.HasPrefix(key, "x-kubernetes-") || key == "nullable":
+			case key == "$ref":
+				ref, _ := item.(string)
+				name, err := ParseRef(ref)
+				if err != nil {
+					return nil, false
+				}
+				if _, ok := b.definitions[name]; !ok {
+					return nil, false
+				}
+				b.reference(name)
+				out[key] = "#/definitions/" + name
+			case key == "properties":
+				props, _ := item.(map[string]interface{})
+				properties := map[string]interface{}{}
+				for name, prop := range props {
+					converted, ok := b.convertSubschema(prop)
+					if !ok {
+						return nil, false
+					}
+					properties[name] = converted
+				}
+				out[key] = properties
+			default:
+				converted, ok := b.convertSubschema(item)
+				if !ok {
+					return nil, false
+				}
+				out[key] = converted
+			}
+		}
+		if nullable, _ := v["nullable"].(bool); nullable {
+			makeNullable(out)
+		}
+		return out, true
+	default:
+		return value, true
+	}
+}
+
+func (b *jsonSchemaBuilder) reference(name string) {
+	if !b.referenced.Contains(name) {
+		b.referenced.Add(name)
+		b.pending = append(b.pending, name)
+	}
+}
+
+// makeNullable turns openapi's `nullable` into json schema, the way openapi2jsonschema does: null is
+// added to `type`, or to the alternatives of a `oneOf`, and a `$ref` becomes one of the ref and null.
+func makeNullable(schema map[string]interface{}) {
+	null := map[string]interface{}{"type": "null"}
+	if schemaType, ok := schema["type"].(string); ok {
+		schema["type"] = []interface{}{schemaType, "null"}
+	} else if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		schema["oneOf"] = append(oneOf, null)
+	} else if ref, ok := schema["$ref"]; ok && schema["anyOf"] == nil {
+		delete(schema, "$ref")
+		schema["anyOf"] = []interface{}{map[string]interface{}{"$ref": ref}, null}
+	}
+}
@@ -0,0 +1,103 @@
+package swagger
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func RunJsonSchemaTests() {
+	boolean := func(b bool) *bool { return &b }
+	spec := &KubeSpec{Definitions: map[string]*SpecType{
+		"com.example.v1.Widget": {
+			Type: "object",
+			Properties: map[string]*SpecType{
+				"spec": {Ref: "#/definitions/com.example.v1.WidgetSpec"},
+				"status": {
+					Type:                             "object",
+					Properties:                       map[string]*SpecType{"phase": {Type: "string"}},
+					XKubernetesPreserveUnknownFields: boolean(true),
+				},
+			},
+			XKubernetesGroupVersionKind: []*GVK{{Group: "example.com", Version: "v1", Kind: "Widget"}},
+		},
+		"com.example.v1.WidgetSpec": {
+			Type: "object",
+			Properties: map[string]*SpecType{
+				"size":     {Type: "integer", Nullable: boolean(true)},
+				"port":     {Type: "string", XKubernetesIntOrString: boolean(true), Nullable: boolean(true)},
+				"selector": {Type: "object", OneOf: []*SpecType{{Ref: "#/definitions/com.example.v1.Selector"}, {Type: "object", Nullable: boolean(true), XKubernetesPreserveUnknownFields: boolean(true)}}},
+				"mode":     {Type: "string", AnyOf: []*SpecType{{Ref: "#/definitions/com.example.v1.Missing"}}},
+			},
+		},
+		"com.example.v1.Selector": {
+			Type:       "object",
+			Properties: map[string]*SpecType{"app": {Type: "string"}},
+		},
+	}}
+	definitions, err := spec.ResolveDefinitions()
+	if err != nil {
+		panic(err)
+	}
+	widget := definitions["com.example.v1.Widget"]
+
+	Describe("JsonSchema", func() {
+		It("bundles definitions referred to by subschemas, and drops subschemas whose refs can't be found", func() {
+			schema, err := ToJsonSchema(definitions, widget, false)
+			Expect(err).To(Succeed())
+			specProperties := schema["properties"].(map[string]interface{})["spec"].(map[string]interface{})["properties"].(map[string]interface{})
+
+			Expect(specProperties["selector"]).To(Equal(map[string]interface{}{
+				"type": "object",
+				"oneOf": []interface{}{
+					map[string]interface{}{"$ref": "#/definitions/com.example.v1.Selector"},
+					map[string]interface{}{"type": []interface{}{"object", "null"}},
+				},
+			}))
+			Expect(specProperties["mode"]).To(Equal(map[string]interface{}{"type": "string"}))
+			Expect(schema["definitions"]).To(Equal(map[string]interface{}{
+				"com.example.v1.Selector": map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{"app": map[string]interface{}{"type": "string"}},
+				},
+			}))
+		})
+
+		It("turns nullable into a null type", func() {
+			schema, err := ToJsonSchema(definitions, widget, false)
+			Expect(err).To(Succeed())
+			specProperties := schema["properties"].(map[string]interface{})["spec"].(map[string]interface{})["properties"].(map[string]interface{})
+
+			Expect(specProperties["size"]).To(Equal(map[string]interface{}{"type": []interface{}{"integer", "null"}}))
+			Expect(specProperties["port"]).To(Equal(map[string]interface{}{
+				"oneOf": []interface{}{
+					map[string]interface{}{"type": "string"},
+					map[string]interface{}{"type": "integer"},
+					map[string]interface{}{"type": "null"},
+				},
+			}))
+		})
+
+		It("disallows additional properties in strict mode, unless unknown fields are preserved", func() {
+			schema, err := ToJsonSchema(definitions, widget, true)
+			Expect(err).To(Succeed())
+			properties := schema["properties"].(map[string]interface{})
+
+			Expect(schema["additionalProperties"]).To(Equal(false))
+			Expect(properties["spec"].(map[string]interface{})["additionalProperties"]).To(Equal(false))
+			Expect(properties["status"]).NotTo(HaveKey("additionalProperties"))
+
+			Expect(ToJsonSchema(definitions, widget, false)).NotTo(HaveKey("additionalProperties"))
+		})
+
+		It("returns an error for circular references to missing definitions", func() {
+			_, err := ToJsonSchema(definitions, &ResolvedType{Array: &ResolvedType{Circular: "com.example.v1.Missing"}}, false)
+			Expect(err).To(MatchError("unable to find definition for com.example.v1.Missing"))
+		})
+
+		It("names files the way kubeconform does", func() {
+			Expect(JsonSchemaFileName(&GVK{Group: "apps", Version: "v1", Kind: "Deployment"})).To(Equal("deployment-apps-v1.json"))
+			Expect(JsonSchemaFileName(&GVK{Version: "v1", Kind: "Pod"})).To(Equal("pod-v1.json"))
+			Expect(JsonSchemaDirectoryName("1.30.2", true)).To(Equal("v1.30.2-standalone-strict"))
+		})
+	})
+}
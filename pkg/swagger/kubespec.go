@@ -1,8 +1,10 @@
 package swagger
 
 import (
+	"fmt"
 	"github.com/mattfenwick/collections/pkg/base"
 	"github.com/mattfenwick/collections/pkg/function"
+	"github.com/mattfenwick/collections/pkg/set"
 	"github.com/mattfenwick/collections/pkg/slice"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -25,43 +27,120 @@ type SpecType struct {
 	XKubernetesPatchStrategy    string                   `json:"x-kubernetes-patch-strategy,omitempty"`
 	XKubernetesGroupVersionKind []*GVK                   `json:"x-kubernetes-group-version-kind,omitempty"`
 	XKubernetesUnions           []map[string]interface{} `json:"x-kubernetes-unions,omitempty"`
+
+	AllOf            []*SpecType   `json:"allOf,omitempty"`
+	AnyOf            []*SpecType   `json:"anyOf,omitempty"`
+	Default          interface{}   `json:"default,omitempty"`
+	Enum             []interface{} `json:"enum,omitempty"`
+	ExclusiveMaximum *bool         `json:"exclusiveMaximum,omitempty"`
+	ExclusiveMinimum *bool         `json:"exclusiveMinimum,omitempty"`
+	MaxItems         *int64        `json:"maxItems,omitempty"`
+	MaxLength        *int64        `json:"maxLength,omitempty"`
+	MaxProperties    *int64        `json:"maxProperties,omitempty"`
+	Maximum          *float64      `json:"maximum,omitempty"`
+	MinItems         *int64        `json:"minItems,omitempty"`
+	MinLength        *int64        `json:"minLength,omitempty"`
+	MinProperties    *int64        `json:"minProperties,omitempty"`
+	Minimum          *float64      `json:"minimum,omitempty"`
+	MultipleOf       *float64      `json:"multipleOf,omitempty"`
+	Not              *SpecType     `json:"not,omitempty"`
+	Nullable         *bool         `json:"nullable,omitempty"`
+	OneOf            []*SpecType   `json:"oneOf,omitempty"`
+	Pattern          string        `json:"pattern,omitempty"`
+	UniqueItems      *bool         `json:"uniqueItems,omitempty"`
+
+	XKubernetesEmbeddedResource      *bool             `json:"x-kubernetes-embedded-resource,omitempty"`
+	XKubernetesIntOrString           *bool             `json:"x-kubernetes-int-or-string,omitempty"`
+	XKubernetesPreserveUnknownFields *bool             `json:"x-kubernetes-preserve-unknown-fields,omitempty"`
+	XKubernetesValidations           []*ValidationRule `json:"x-kubernetes-validations,omitempty"`
+}
+
+// ValidationRule is a CEL rule from `x-kubernetes-validations`
+type ValidationRule struct {
+	Rule              string `json:"rule"`
+	Message           string `json:"message,omitempty"`
+	MessageExpression string `json:"messageExpression,omitempty"`
+	Reason            string `json:"reason,omitempty"`
+	FieldPath         string `json:"fieldPath,omitempty"`
+	OptionalOldSelf   *bool  `json:"optionalOldSelf,omitempty"`
+}
+
+func (v *ValidationRule) String() string {
+	if v.Message != "" {
+		return fmt.Sprintf("%s  # %s", v.Rule, v.Message)
+	} else if v.MessageExpression != "" {
+		return fmt.Sprintf("%s  # %s", v.Rule, v.MessageExpression)
+	}
+	return v.Rule
+}
+
+func (s *SpecType) PreservesUnknownFields() bool {
+	return isTrue(s.XKubernetesPreserveUnknownFields)
+}
+
+func (s *SpecType) IsIntOrString() bool {
+	return isTrue(s.XKubernetesIntOrString)
+}
+
+// isTrue is for optional boolean keywords, which are pointers so that an explicit `false` can be told apart
+// from not being set at all
+func isTrue(value *bool) bool {
+	return value != nil && *value
 }
 
 type KubeSpec struct {
+	Swagger     string               `json:"swagger,omitempty"`
 	Definitions map[string]*SpecType `json:"definitions"`
 	Info        struct {
 		Title   string `json:"title"`
 		Version string `json:"version"`
 	} `json:"info"`
-	//Paths map[string]interface{}
+	Paths map[string]interface{} `json:"paths"`
+	// Parameters are shared by operations in Paths, through `$ref`s
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
 	//Security int
 	//SecurityDefinitions int
 }
 
 func enforceInvariant(specType *SpecType) {
 	counts := slice.Filter(function.Id[bool], []bool{specType.Ref != "", specType.Type != ""})
-	if len(counts) != 1 && specType.Description == "" {
+	// CRD schemas may leave out the type for these, and v3 specs wrap $refs in allOf
+	untyped := specType.IsIntOrString() || specType.PreservesUnknownFields() || len(specType.AllOf) > 0
+	if len(counts) != 1 && specType.Description == "" && !untyped {
 		logrus.Errorf("INVARIANT violated: %d; %+v", len(counts), specType)
 	}
 }
 
-func (s *KubeSpec) MustGetDefinition(name string) *SpecType {
+func (s *KubeSpec) GetDefinition(name string) (*SpecType, error) {
 	val, ok := s.Definitions[name]
 	if !ok {
-		panic(errors.Errorf("unable to find definition for %s", name))
+		return nil, errors.Errorf("unable to find definition for %s", name)
 	}
-	return val
+	return val, nil
 }
 
-func (s *KubeSpec) VisitSpecType(resolvedTypes map[string]*ResolvedType, path Path, specType *SpecType, visit func(path Path, resolved *ResolvedType, circular string)) *ResolvedType {
+// DefinitionGVKs are the GVKs of the definition for a kind, under an api version as keyed by
+// ResolveStructure; types which aren't resources don't have any
+func (s *KubeSpec) DefinitionGVKs(apiVersion string, kind string) []*GVK {
+	if definition, ok := s.Definitions[fmt.Sprintf("%s.%s", apiVersion, kind)]; ok {
+		return definition.XKubernetesGroupVersionKind
+	}
+	return nil
+}
+
+func (s *KubeSpec) VisitSpecType(resolvedTypes map[string]*ResolvedType, path Path, specType *SpecType, visit func(path Path, resolved *ResolvedType, circular string)) (*ResolvedType, error) {
 	enforceInvariant(specType)
 
 	// visit AFTER processing of the type is done
 	var resolved *ResolvedType
+	constraints := specType.Constraints()
 	defer visit(path, resolved, "")
 
 	if specType.Ref != "" {
-		refName := ParseRef(specType.Ref)
+		refName, err := ParseRef(specType.Ref)
+		if err != nil {
+			return nil, errors.Wrapf(err, "at %s", strings.Join(path.ToStringPieces(), "."))
+		}
 		newPath := path.Append(SpecPath{Ref: true})
 		if resolvedTypes[refName] != nil {
 			// done: NOT circular
@@ -73,52 +152,143 @@ func (s *KubeSpec) VisitSpecType(resolvedTypes map[string]*ResolvedType, path Pa
 			resolved = &ResolvedType{Circular: refName}
 		} else {
 			// hasn't been seen yet
+			definition, err := s.GetDefinition(refName)
+			if err != nil {
+				return nil, errors.Wrapf(err, "at %s", strings.Join(path.ToStringPieces(), "."))
+			}
 			resolvedTypes[refName] = nil
-			resolved = s.VisitSpecType(resolvedTypes, newPath, s.MustGetDefinition(refName), visit)
+			resolved, err = s.VisitSpecType(resolvedTypes, newPath, definition, visit)
+			if err != nil {
+				return nil, err
+			}
 			resolvedTypes[refName] = resolved
 		}
+		// copy before naming the definition, since the resolved definition itself isn't found through a $ref
+		named := *resolved
+		named.Definition = refName
+		// a description next to a $ref describes the field, not the referenced type
+		resolved = (&named).WithField(specType.Description, constraints)
+	} else if specType.Type == "" && len(specType.AllOf) > 0 {
+		allOf, err := s.visitAllOf(resolvedTypes, path, specType.AllOf, visit)
+		if err != nil {
+			return nil, err
+		}
+		resolved = allOf.WithField(specType.Description, constraints)
 	} else {
 		switch specType.Type {
 		case "":
-			logrus.Debugf("skipping empty type: %+v", strings.Join(path.ToStringPieces(), "."))
-			resolved = &ResolvedType{Empty: true}
+			if specType.IsIntOrString() {
+				// same representation as the built-in IntOrString type
+				resolved = &ResolvedType{Primitive: "string"}
+			} else {
+				logrus.Debugf("skipping empty type: %+v", strings.Join(path.ToStringPieces(), "."))
+				resolved = &ResolvedType{Empty: true}
+			}
 		case "array":
-			resolved = &ResolvedType{Array: s.VisitSpecType(resolvedTypes, path.Append(SpecPath{Array: true}), specType.Items, visit)}
+			if specType.Items == nil {
+				logrus.Warnf("array without items at %s", strings.Join(path.ToStringPieces(), "."))
+				resolved = &ResolvedType{Array: &ResolvedType{Empty: true}}
+				break
+			}
+			items, err := s.VisitSpecType(resolvedTypes, path.Append(SpecPath{Array: true}), specType.Items, visit)
+			if err != nil {
+				return nil, err
+			}
+			resolved = &ResolvedType{Array: items}
 		case "object":
-			obj := &ResolvedObject{Properties: map[string]*ResolvedType{}}
-			for propName, prop := range specType.Properties {
-				obj.Properties[propName] = s.VisitSpecType(resolvedTypes, path.Append(SpecPath{ObjectProperty: true}).Append(SpecPath{FieldAccess: propName}), prop, visit)
+			obj := &ResolvedObject{Properties: map[string]*ResolvedType{}, Required: specType.Required}
+			for _, propName := range slice.Sort(maps.Keys(specType.Properties)) {
+				resolvedProp, err := s.VisitSpecType(resolvedTypes, path.Append(SpecPath{ObjectProperty: true}).Append(SpecPath{FieldAccess: propName}), specType.Properties[propName], visit)
+				if err != nil {
+					return nil, err
+				}
+				obj.Properties[propName] = resolvedProp
 			}
 			if specType.AdditionalProperties != nil {
-				obj.AdditionalProperties = s.VisitSpecType(resolvedTypes, path.Append(SpecPath{FieldAccess: "additionalProperties"}), specType.AdditionalProperties, visit)
+				additionalProperties, err := s.VisitSpecType(resolvedTypes, path.Append(SpecPath{FieldAccess: "additionalProperties"}), specType.AdditionalProperties, visit)
+				if err != nil {
+					return nil, err
+				}
+				obj.AdditionalProperties = additionalProperties
 			}
 			resolved = &ResolvedType{Object: obj}
 		case "boolean", "string", "integer", "number":
 			resolved = &ResolvedType{Primitive: specType.Type}
 			logrus.Debugf("found primitive: %s", specType.Type)
 		default:
-			panic(errors.Errorf("TODO unsupported type %s: %+v, %+v", specType.Type, path, specType))
+			logrus.Warnf("unsupported type %s at %s, treating as empty", specType.Type, strings.Join(path.ToStringPieces(), "."))
+			resolved = &ResolvedType{Empty: true}
 		}
+		resolved.Description = specType.Description
+		resolved.Format = specType.Format
+		if specType.IsIntOrString() {
+			resolved.Format = "int-or-string"
+		}
+		resolved.Constraints = constraints
 	}
-	return resolved
+	return resolved, nil
 }
 
-func (s *KubeSpec) Visit(visit func(path Path, resolved *ResolvedType, circular string)) (map[string]*ResolvedType, map[string]map[string]*ResolvedType) {
+// visitAllOf handles the `allOf: [{$ref: ...}]` wrapper which v3 specs use to attach a description
+// or default to a $ref.  Multiple object schemas are merged; anything else is kept as the first schema.
+func (s *KubeSpec) visitAllOf(resolvedTypes map[string]*ResolvedType, path Path, schemas []*SpecType, visit func(path Path, resolved *ResolvedType, circular string)) (*ResolvedType, error) {
+	var all []*ResolvedType
+	for _, schema := range schemas {
+		resolved, err := s.VisitSpecType(resolvedTypes, path, schema, visit)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, resolved)
+	}
+	if len(all) == 1 {
+		return all[0], nil
+	}
+	merged := &ResolvedObject{Properties: map[string]*ResolvedType{}}
+	required := set.Empty[string]()
+	for _, resolved := range all {
+		if resolved.Object == nil {
+			logrus.Warnf("unable to merge allOf at %s, using first schema", strings.Join(path.ToStringPieces(), "."))
+			return all[0], nil
+		}
+		for name, prop := range resolved.Object.Properties {
+			merged.Properties[name] = prop
+		}
+		for _, field := range resolved.Object.Required {
+			required.Add(field)
+		}
+		if resolved.Object.AdditionalProperties != nil {
+			merged.AdditionalProperties = resolved.Object.AdditionalProperties
+		}
+	}
+	if required.Len() > 0 {
+		merged.Required = slice.Sort(required.ToSlice())
+	}
+	return &ResolvedType{Object: merged, Description: all[0].Description}, nil
+}
+
+func (s *KubeSpec) Visit(visit func(path Path, resolved *ResolvedType, circular string)) (map[string]*ResolvedType, map[string]map[string]*ResolvedType, error) {
 	resolvedTypes := map[string]*ResolvedType{}
-	for defName, def := range s.Definitions {
+	// definitions and properties are visited in order, so that circular markers land in the same place every time
+	for _, defName := range slice.Sort(maps.Keys(s.Definitions)) {
 		resolvedTypes[defName] = nil
-		resolved := s.VisitSpecType(resolvedTypes, []SpecPath{{FieldAccess: defName}}, def, visit)
+		resolved, err := s.VisitSpecType(resolvedTypes, []SpecPath{{FieldAccess: defName}}, s.Definitions[defName], visit)
+		if err != nil {
+			return nil, nil, err
+		}
 		resolvedTypes[defName] = resolved
 	}
 	byKindByAPIVersion := map[string]map[string]*ResolvedType{}
 	for gvkString, resolved := range resolvedTypes {
-		gvk := ParseGVK(gvkString)
+		gvk, err := ParseGVK(gvkString)
+		if err != nil {
+			return nil, nil, err
+		}
 		if _, ok := byKindByAPIVersion[gvk.Kind]; !ok {
 			byKindByAPIVersion[gvk.Kind] = map[string]*ResolvedType{}
 		}
 		byKindByAPIVersion[gvk.Kind][gvk.GroupVersion()] = resolved
 	}
-	return resolvedTypes, byKindByAPIVersion
+	return resolvedTypes, byKindByAPIVersion, nil
 }
 
 type SpecPath struct {
@@ -146,21 +316,32 @@ func (p Path) ToStringPieces() []string {
 		} else if piece.ObjectProperty {
 			// nothing to do ??  TODO decide
 		} else {
-			panic(errors.Errorf("invalid SpecPath value: %+v", piece))
+			elems = append(elems, "?")
 		}
 	}
 	return elems
 }
 
-func (s *KubeSpec) ResolveStructure() map[string]map[string]*ResolvedType {
-	_, byKindByAPIVersion := s.Visit(func(path Path, resolved *ResolvedType, circular string) {
+func (s *KubeSpec) resolve() (map[string]*ResolvedType, map[string]map[string]*ResolvedType, error) {
+	return s.Visit(func(path Path, resolved *ResolvedType, circular string) {
 		if circular == "" {
 			logrus.Debugf("%+v -- %+v\n", path.ToStringPieces(), resolved)
 		} else {
 			logrus.Debugf("%+v\n  CIRCULAR %s\n", path.ToStringPieces(), circular)
 		}
 	})
-	return byKindByAPIVersion
+}
+
+func (s *KubeSpec) ResolveStructure() (map[string]map[string]*ResolvedType, error) {
+	_, byKindByAPIVersion, err := s.resolve()
+	return byKindByAPIVersion, err
+}
+
+// ResolveDefinitions resolves every definition, keyed by definition name.  This is useful
+// for following up on `Circular` markers, which refer to definitions by name.
+func (s *KubeSpec) ResolveDefinitions() (map[string]*ResolvedType, error) {
+	byName, _, err := s.resolve()
+	return byName, err
 }
 
 //func (s *KubeSpec) ResolveGVKs() {
@@ -184,41 +365,90 @@ func (s *KubeSpec) ResolveStructure() map[string]map[string]*ResolvedType {
 type ResolvedObject struct {
 	Properties           map[string]*ResolvedType
 	AdditionalProperties *ResolvedType
+	Required             []string
+}
+
+func (r *ResolvedObject) IsRequired(field string) bool {
+	return slice.Any(func(f string) bool { return f == field }, r.Required)
 }
 
 type ResolvedType struct {
+	Description string
+	Format      string
+	Constraints *Constraints
+
 	Empty     bool
 	Primitive string
 	Array     *ResolvedType
 	Object    *ResolvedObject
 	Circular  string
+	// Definition is the name of the definition this type was found through, if it came from a $ref
+	Definition string
 }
 
-func (r *ResolvedType) Paths(pathContext []string) []*base.Pair[[]string, string] {
-	logrus.Debugf("path: %+v", pathContext)
+// WithDescription makes a shallow copy, so that resolved types shared between
+// multiple $refs don't have their descriptions overwritten
+func (r *ResolvedType) WithDescription(description string) *ResolvedType {
+	out := *r
+	out.Description = description
+	return &out
+}
 
-	path := slice.Map(function.Id[string], pathContext)
+// WithField applies the description and constraints found next to a $ref, if there are any.
+// Constraints are merged with the referenced type's.
+func (r *ResolvedType) WithField(description string, constraints *Constraints) *ResolvedType {
+	if description == "" && constraints == nil {
+		return r
+	}
+	out := r
+	if description != "" {
+		out = out.WithDescription(description)
+	}
+	if constraints != nil {
+		if out == r {
+			copied := *r
+			out = &copied
+		}
+		out.Constraints = r.Constraints.Merge(constraints)
+	}
+	return out
+}
+
+// hasShape is false for Empty types, and for types which don't set any of the shape fields:
+// those are treated as Empty
+func (r *ResolvedType) hasShape() bool {
+	return !r.Empty && (r.Circular != "" || r.Primitive != "" || r.Array != nil || r.Object != nil)
+}
 
-	var out []*base.Pair[[]string, string]
-	if r.Circular != "" {
-		out = append(out, base.NewPair(path, r.Circular))
+// TypeName is a short description of the type's shape, as used by explain
+func (r *ResolvedType) TypeName() string {
+	if !r.hasShape() {
+		return "?"
+	} else if r.Circular != "" {
+		return r.Circular
 	} else if r.Primitive != "" {
-		out = append(out, base.NewPair(path, r.Primitive))
+		return r.Primitive
 	} else if r.Array != nil {
-		out = append(out, base.NewPair(path, "array"))
+		return "array"
+	}
+	return "object"
+}
+
+func (r *ResolvedType) Paths(pathContext []string) []*base.Pair[[]string, *ResolvedType] {
+	logrus.Debugf("path: %+v", pathContext)
+
+	path := slice.Map(function.Id[string], pathContext)
+
+	out := []*base.Pair[[]string, *ResolvedType]{base.NewPair(path, r)}
+	if r.Array != nil {
 		out = append(out, r.Array.Paths(slice.Append(path, []string{"[]"}))...)
 	} else if r.Object != nil {
-		out = append(out, base.NewPair(path, "object"))
 		for _, fieldName := range slice.Sort(maps.Keys(r.Object.Properties)) {
 			out = append(out, r.Object.Properties[fieldName].Paths(slice.Append(path, []string{fieldName}))...)
 		}
 		if r.Object.AdditionalProperties != nil {
 			out = append(out, r.Object.AdditionalProperties.Paths(slice.Append(path, []string{"additionalProperties"}))...)
 		}
-	} else if r.Empty {
-		out = append(out, base.NewPair(path, "?"))
-	} else {
-		panic(errors.Errorf("invalid ResolvedType: %+v", r))
 	}
 	return out
 }
@@ -1,7 +1,6 @@
 package swagger
 
 import (
-	"fmt"
 	"strings"
 
 	"github.com/mattfenwick/collections/pkg/base"
@@ -24,12 +23,38 @@ func NewVersion(v string) (KubeVersion, error) {
 	return pieces, nil
 }
 
+// MustVersion is for versions which are known to be valid, such as constants: it panics on an invalid
+// version.  Use ParseKubeVersions for user input.
 func MustVersion(v string) KubeVersion {
 	version, err := NewVersion(v)
-	utils.Die(err)
+	if err != nil {
+		panic(err)
+	}
 	return version
 }
 
+// ParseKubeVersions parses versions from flags, returning a usage error for the first invalid one
+func ParseKubeVersions(versions []string) ([]KubeVersion, error) {
+	var out []KubeVersion
+	for _, v := range versions {
+		version, err := ParseKubeVersion(v)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, version)
+	}
+	return out, nil
+}
+
+// ParseKubeVersion is NewVersion for user input: a bad version is a usage error
+func ParseKubeVersion(v string) (KubeVersion, error) {
+	version, err := NewVersion(v)
+	if err != nil {
+		return nil, utils.NewUsageError("invalid kube version '%s': expected a version like 1.30.2", v)
+	}
+	return version, nil
+}
+
 func (v KubeVersion) Compare(b KubeVersion) base.Ordering {
 	return CompareKubeVersion(v, b)
 }
@@ -38,13 +63,7 @@ func (v KubeVersion) ToString() string {
 	return strings.Join(v, ".")
 }
 
-func (v KubeVersion) SwaggerSpecURL() string {
-	return fmt.Sprintf(GithubOpenapiURLTemplate, v.ToString())
-}
-
 var (
-	GithubOpenapiURLTemplate = "https://raw.githubusercontent.com/kubernetes/kubernetes/v%s/api/openapi-spec/swagger.json"
-
 	// LatestKubePatchVersionStrings records the latest known patch versions for each minor version
 	//   these version numbers come from https://github.com/kubernetes/kubernetes/tree/master/CHANGELOG
 	LatestKubePatchVersionStrings = []string{
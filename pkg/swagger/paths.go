@@ -0,0 +1,387 @@
+package swagger
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattfenwick/collections/pkg/slice"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/maps"
+)
+
+// PathOperation is an operation from the spec's `paths` section.  Only the fields that kubernetes
+// adds are modeled.
+type PathOperation struct {
+	OperationID string           `json:"operationId,omitempty"`
+	Action      string           `json:"x-kubernetes-action,omitempty"`
+	GVK         *GVK             `json:"x-kubernetes-group-version-kind,omitempty"`
+	Parameters  []*PathParameter `json:"parameters,omitempty"`
+	// Consumes lists content types; for patches, these are the patch types
+	Consumes []string `json:"consumes,omitempty"`
+}
+
+// PathParameter is either a parameter, or a `$ref` to one in the spec's `parameters` section
+type PathParameter struct {
+	Ref  string `json:"$ref,omitempty"`
+	Name string `json:"name,omitempty"`
+	In   string `json:"in,omitempty"`
+}
+
+type PathItem struct {
+	Get    *PathOperation `json:"get,omitempty"`
+	Put    *PathOperation `json:"put,omitempty"`
+	Post   *PathOperation `json:"post,omitempty"`
+	Delete *PathOperation `json:"delete,omitempty"`
+	Patch  *PathOperation `json:"patch,omitempty"`
+	// Parameters are shared by every operation
+	Parameters []*PathParameter `json:"parameters,omitempty"`
+}
+
+// Operations is keyed by lowercase http method
+func (p *PathItem) Operations() map[string]*PathOperation {
+	operations := map[string]*PathOperation{}
+	for method, operation := range map[string]*PathOperation{"get": p.Get, "put": p.Put, "post": p.Post, "delete": p.Delete, "patch": p.Patch} {
+		if operation != nil {
+			operations[method] = operation
+		}
+	}
+	return operations
+}
+
+// ResourcePath is a path broken down the way the apiserver builds them: `/api/v1` or
+// `/apis/{group}/{version}`, then optionally `watch`, then optionally `namespaces/{namespace}`,
+// then the resource, `{name}` and a subresource.
+type ResourcePath struct {
+	Path        string
+	Group       string
+	Version     string
+	Watch       bool
+	Namespaced  bool
+	Resource    string
+	HasName     bool
+	Subresource string
+	Operations  map[string]*PathOperation
+	// Parameters are shared by every operation, with `$ref`s resolved
+	Parameters []*PathParameter
+}
+
+// ParseResourcePath returns false for paths which aren't for a resource, such as `/version/` or
+// `/apis/apps/`
+func ParseResourcePath(path string) (*ResourcePath, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	out := &ResourcePath{Path: path}
+	switch {
+	case len(segments) >= 3 && segments[0] == "api":
+		out.Version, segments = segments[1], segments[2:]
+	case len(segments) >= 4 && segments[0] == "apis":
+		out.Group, out.Version, segments = segments[1], segments[2], segments[3:]
+	default:
+		return nil, false
+	}
+	if segments[0] == "watch" {
+		out.Watch, segments = true, segments[1:]
+	}
+	// `namespaces/{name}` is the path for namespaces themselves
+	if len(segments) > 2 && segments[0] == "namespaces" && segments[1] == "{namespace}" {
+		out.Namespaced, segments = true, segments[2:]
+	}
+	if len(segments) == 0 || strings.HasPrefix(segments[0], "{") {
+		return nil, false
+	}
+	out.Resource = segments[0]
+	if len(segments) > 1 {
+		if segments[1] != "{name}" {
+			return nil, false
+		}
+		out.HasName = true
+	}
+	if len(segments) > 2 {
+		out.Subresource = segments[2]
+	}
+	return out, true
+}
+
+// ResourcePaths parses the spec's `paths` section, sorted by path, leaving out paths which aren't
+// for a resource
+func (s *KubeSpec) ResourcePaths() ([]*ResourcePath, error) {
+	var out []*ResourcePath
+	for _, path := range slice.Sort(maps.Keys(s.Paths)) {
+		resourcePath, ok := ParseResourcePath(path)
+		if !ok {
+			continue
+		}
+		item, err := reparse[PathItem](s.Paths[path])
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to parse path %s", path)
+		}
+		resourcePath.Operations = item.Operations()
+		resourcePath.Parameters, err = s.resolveParameters(item.Parameters)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to resolve parameters for path %s", path)
+		}
+		for method, operation := range resourcePath.Operations {
+			operation.Parameters, err = s.resolveParameters(operation.Parameters)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to resolve parameters for %s %s", method, path)
+			}
+		}
+		out = append(out, resourcePath)
+	}
+	return out, nil
+}
+
+func (s *KubeSpec) resolveParameters(parameters []*PathParameter) ([]*PathParameter, error) {
+	var out []*PathParameter
+	for _, parameter := range parameters {
+		if parameter.Ref == "" {
+			out = append(out, parameter)
+			continue
+		}
+		name := strings.TrimPrefix(parameter.Ref, "#/parameters/")
+		shared, ok := s.Parameters[name]
+		if !ok {
+			return nil, errors.Errorf("unable to find parameter %s", parameter.Ref)
+		}
+		resolved, err := reparse[PathParameter](shared)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to parse parameter %s", parameter.Ref)
+		}
+		out = append(out, resolved)
+	}
+	return out, nil
+}
+
+// CatalogResource is what the spec's paths say about a resource: the verbs come from the
+// `x-kubernetes-action`s of its own paths, translated to the names kubectl and RBAC use, and the
+// query parameters are those of any of its operations.
+type CatalogResource struct {
+	GVK             *GVK     `json:"gvk"`
+	Plural          string   `json:"plural"`
+	Namespaced      bool     `json:"namespaced"`
+	Verbs           []string `json:"verbs"`
+	Subresources    []string `json:"subresources"`
+	QueryParameters []string `json:"queryParameters"`
+	// PatchTypes are the content types accepted by patch, without the `application/` prefix
+	PatchTypes []string `json:"patchTypes"`
+	// SubresourceVerbs is keyed by subresource
+	SubresourceVerbs map[string][]string `json:"subresourceVerbs"`
+}
+
+// OperationNames lists what can be done with a resource the way RBAC rules name it -- such as
+// `pods:get` and `pods/status:patch` -- along with patch types, such as
+// `pods:patch(apply-patch+yaml)`.  There are no spaces, so that they're easy to tell apart in csv.
+func (c *CatalogResource) OperationNames() []string {
+	var out []string
+	for _, verb := range c.Verbs {
+		out = append(out, fmt.Sprintf("%s:%s", c.Plural, verb))
+	}
+	for _, patchType := range c.PatchTypes {
+		out = append(out, fmt.Sprintf("%s:patch(%s)", c.Plural, patchType))
+	}
+	for _, subresource := range c.Subresources {
+		for _, verb := range c.SubresourceVerbs[subresource] {
+			out = append(out, fmt.Sprintf("%s/%s:%s", c.Plural, subresource, verb))
+		}
+	}
+	return out
+}
+
+func (c *CatalogResource) Scope() string {
+	if c.Namespaced {
+		return "Namespaced"
+	}
+	return "Cluster"
+}
+
+var actionVerbs = map[string]string{
+	"get":              "get",
+	"list":             "list",
+	"watch":            "watch",
+	"watchlist":        "watch",
+	"post":             "create",
+	"put":              "update",
+	"patch":            "patch",
+	"delete":           "delete",
+	"deletecollection": "deletecollection",
+}
+
+func actionVerb(action string) string {
+	if verb, ok := actionVerbs[action]; ok {
+		return verb
+	}
+	return action
+}
+
+// subresourceVerb uses the http method for `connect` actions, such as `pods/exec`, since that's
+// what RBAC checks
+func subresourceVerb(method string, action string) string {
+	if action != "connect" {
+		return actionVerb(action)
+	}
+	switch method {
+	case "post":
+		return "create"
+	case "put":
+		return "update"
+	default:
+		return method
+	}
+}
+
+type resourceKey struct {
+	group    string
+	version  string
+	resource string
+}
+
+// ResourceCatalog has an entry for every GVK with its own paths.  Subresources are attached to
+// the resource whose path they're under, rather than to the kind their operations use, which is
+// often different -- `deployments/{name}/scale` uses autoscaling/v1 Scale.
+func (s *KubeSpec) ResourceCatalog() (map[GVK]*CatalogResource, error) {
+	paths, err := s.ResourcePaths()
+	if err != nil {
+		return nil, err
+	}
+	catalog := map[GVK]*CatalogResource{}
+	byResource := map[resourceKey]*CatalogResource{}
+	verbs := map[GVK]map[string]bool{}
+	parameters := map[GVK]map[string]bool{}
+	patchTypes := map[GVK]map[string]bool{}
+	for _, path := range paths {
+		if path.Subresource != "" {
+			continue
+		}
+		for _, method := range slice.Sort(maps.Keys(path.Operations)) {
+			operation := path.Operations[method]
+			if operation.GVK == nil {
+				continue
+			}
+			gvk := *operation.GVK
+			resource, ok := catalog[gvk]
+			if !ok {
+				resource = &CatalogResource{GVK: operation.GVK, Plural: path.Resource}
+				catalog[gvk] = resource
+				verbs[gvk] = map[string]bool{}
+				parameters[gvk] = map[string]bool{}
+				patchTypes[gvk] = map[string]bool{}
+			}
+			byResource[resourceKey{group: path.Group, version: path.Version, resource: path.Resource}] = resource
+			resource.Namespaced = resource.Namespaced || path.Namespaced
+			verbs[gvk][actionVerb(operation.Action)] = true
+			if method == "patch" {
+				for _, contentType := range operation.Consumes {
+					patchTypes[gvk][strings.TrimPrefix(contentType, "application/")] = true
+				}
+			}
+			for _, parameter := range slice.Append(path.Parameters, operation.Parameters) {
+				if parameter.In == "query" {
+					parameters[gvk][parameter.Name] = true
+				}
+			}
+		}
+	}
+
+	subresources := map[GVK]map[string]map[string]bool{}
+	for _, path := range paths {
+		if path.Subresource == "" {
+			continue
+		}
+		resource, ok := byResource[resourceKey{group: path.Group, version: path.Version, resource: path.Resource}]
+		if !ok {
+			continue
+		}
+		gvk := *resource.GVK
+		if subresources[gvk] == nil {
+			subresources[gvk] = map[string]map[string]bool{}
+		}
+		if subresources[gvk][path.Subresource] == nil {
+			subresources[gvk][path.Subresource] = map[string]bool{}
+		}
+		for method, operation := range path.Operations {
+			subresources[gvk][path.Subresource][subresourceVerb(method, operation.Action)] = true
+		}
+	}
+
+	for gvk, resource := range catalog {
+		resource.Verbs = slice.Sort(maps.Keys(verbs[gvk]))
+		resource.Subresources = slice.Sort(maps.Keys(subresources[gvk]))
+		resource.QueryParameters = slice.Sort(maps.Keys(parameters[gvk]))
+		resource.PatchTypes = slice.Sort(maps.Keys(patchTypes[gvk]))
+		resource.SubresourceVerbs = map[string][]string{}
+		for subresource, subresourceVerbs := range subresources[gvk] {
+			resource.SubresourceVerbs[subresource] = slice.Sort(maps.Keys(subresourceVerbs))
+		}
+	}
+	return catalog, nil
+}
+
+type KindClass string
+
+const (
+	// KindClassResource kinds can be read from their own paths, like Pod
+	KindClassResource KindClass = "resource"
+	// KindClassSubresource kinds are only used by subresources, like Scale and Eviction
+	KindClassSubresource KindClass = "subresource"
+	KindClassList        KindClass = "list"
+	KindClassOptions     KindClass = "options"
+	KindClassEvent       KindClass = "event"
+	// KindClassOther is everything else, such as Status, or kinds like TokenReview which can be
+	// created but aren't persisted
+	KindClassOther KindClass = "other"
+)
+
+var kindClassChoices = []string{
+	string(KindClassResource),
+	string(KindClassSubresource),
+	string(KindClassList),
+	string(KindClassOptions),
+	string(KindClassEvent),
+	string(KindClassOther),
+}
+
+// ClassifyKinds classifies every GVK in the spec's definitions by cross-referencing paths: kinds
+// which can be read (with get, list or watch) from their own paths are resources, and kinds
+// which only show up under subresources are subresources.  Kinds without paths are classified by
+// name.
+func (s *KubeSpec) ClassifyKinds() (map[GVK]KindClass, error) {
+	paths, err := s.ResourcePaths()
+	if err != nil {
+		return nil, err
+	}
+	readable := map[GVK]bool{}
+	subresource := map[GVK]bool{}
+	for _, path := range paths {
+		for _, operation := range path.Operations {
+			if operation.GVK == nil {
+				continue
+			}
+			switch {
+			case path.Subresource != "":
+				subresource[*operation.GVK] = true
+			case slice.Any(func(action string) bool { return operation.Action == action }, []string{"get", "list", "watch", "watchlist"}):
+				readable[*operation.GVK] = true
+			}
+		}
+	}
+
+	classes := map[GVK]KindClass{}
+	for _, definition := range s.Definitions {
+		for _, gvk := range definition.XKubernetesGroupVersionKind {
+			switch {
+			case readable[*gvk]:
+				classes[*gvk] = KindClassResource
+			case subresource[*gvk]:
+				classes[*gvk] = KindClassSubresource
+			case gvk.Kind == "WatchEvent":
+				classes[*gvk] = KindClassEvent
+			case strings.HasSuffix(gvk.Kind, "List"):
+				classes[*gvk] = KindClassList
+			case strings.HasSuffix(gvk.Kind, "Options"):
+				classes[*gvk] = KindClassOptions
+			default:
+				classes[*gvk] = KindClassOther
+			}
+		}
+	}
+	return classes, nil
+}
@@ -0,0 +1,197 @@
+package swagger
+
+import (
+	"github.com/mattfenwick/collections/pkg/yaml"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func RunPathsTests() {
+	Describe("ParseResourcePath", func() {
+		It("breaks down resource paths", func() {
+			path, ok := ParseResourcePath("/apis/apps/v1/namespaces/{namespace}/deployments/{name}/scale")
+			Expect(ok).To(BeTrue())
+			Expect(path).To(Equal(&ResourcePath{
+				Path:        "/apis/apps/v1/namespaces/{namespace}/deployments/{name}/scale",
+				Group:       "apps",
+				Version:     "v1",
+				Namespaced:  true,
+				Resource:    "deployments",
+				HasName:     true,
+				Subresource: "scale",
+			}))
+
+			path, ok = ParseResourcePath("/api/v1/watch/pods")
+			Expect(ok).To(BeTrue())
+			Expect(path).To(Equal(&ResourcePath{Path: "/api/v1/watch/pods", Version: "v1", Watch: true, Resource: "pods"}))
+		})
+
+		It("treats namespaces as a resource", func() {
+			path, ok := ParseResourcePath("/api/v1/namespaces/{name}/status")
+			Expect(ok).To(BeTrue())
+			Expect(path.Namespaced).To(BeFalse())
+			Expect(path.Resource).To(Equal("namespaces"))
+			Expect(path.Subresource).To(Equal("status"))
+		})
+
+		It("leaves out paths which aren't for resources", func() {
+			for _, p := range []string{"/version/", "/apis/", "/apis/apps/", "/api/v1/", "/apis/apps/v1/namespaces/{namespace}"} {
+				_, ok := ParseResourcePath(p)
+				Expect(ok).To(BeFalse(), p)
+			}
+		})
+	})
+
+	Describe("ClassifyKinds", func() {
+		operation := func(action string, group string, version string, kind string) map[string]interface{} {
+			return map[string]interface{}{
+				"x-kubernetes-action":             action,
+				"x-kubernetes-group-version-kind": map[string]interface{}{"group": group, "version": version, "kind": kind},
+			}
+		}
+		definition := func(group string, version string, kind string) *SpecType {
+			return &SpecType{XKubernetesGroupVersionKind: []*GVK{{Group: group, Version: version, Kind: kind}}}
+		}
+
+		It("cross references definitions with paths", func() {
+			spec := &KubeSpec{
+				Definitions: map[string]*SpecType{
+					"io.k8s.api.core.v1.Pod":                             definition("", "v1", "Pod"),
+					"io.k8s.api.core.v1.PodList":                         definition("", "v1", "PodList"),
+					"io.k8s.api.policy.v1.Eviction":                      definition("policy", "v1", "Eviction"),
+					"io.k8s.apimachinery.pkg.apis.meta.v1.DeleteOptions": definition("", "v1", "DeleteOptions"),
+					"io.k8s.apimachinery.pkg.apis.meta.v1.WatchEvent":    definition("", "v1", "WatchEvent"),
+					"io.k8s.api.authentication.v1.TokenReview":           definition("authentication.k8s.io", "v1", "TokenReview"),
+				},
+				Paths: map[string]interface{}{
+					"/api/v1/namespaces/{namespace}/pods": map[string]interface{}{
+						"get":  operation("list", "", "v1", "Pod"),
+						"post": operation("post", "", "v1", "Pod"),
+					},
+					"/api/v1/namespaces/{namespace}/pods/{name}/eviction": map[string]interface{}{
+						"post": operation("post", "policy", "v1", "Eviction"),
+					},
+					"/apis/authentication.k8s.io/v1/tokenreviews": map[string]interface{}{
+						"post": operation("post", "authentication.k8s.io", "v1", "TokenReview"),
+					},
+					"/version/": map[string]interface{}{"get": map[string]interface{}{"operationId": "getCodeVersion"}},
+				},
+			}
+			classes, err := spec.ClassifyKinds()
+			Expect(err).To(Succeed())
+			Expect(classes).To(Equal(map[GVK]KindClass{
+				{Version: "v1", Kind: "Pod"}:                                         KindClassResource,
+				{Version: "v1", Kind: "PodList"}:                                     KindClassList,
+				{Group: "policy", Version: "v1", Kind: "Eviction"}:                   KindClassSubresource,
+				{Version: "v1", Kind: "DeleteOptions"}:                               KindClassOptions,
+				{Version: "v1", Kind: "WatchEvent"}:                                  KindClassEvent,
+				{Group: "authentication.k8s.io", Version: "v1", Kind: "TokenReview"}: KindClassOther,
+			}))
+		})
+
+		It("adds paths for custom resources", func() {
+			documents, err := yaml.ParseMany[map[string]interface{}]([]byte(widgetCRD))
+			Expect(err).To(Succeed())
+			crd, err := parseCustomResourceDefinition(documents[0])
+			Expect(err).To(Succeed())
+
+			spec := &KubeSpec{Definitions: map[string]*SpecType{}}
+			Expect(spec.MergeCustomResourceDefinitions([]*CustomResourceDefinition{crd})).To(Succeed())
+			Expect(spec.Paths).To(HaveKey("/apis/example.com/v1/namespaces/{namespace}/widgets/{name}"))
+
+			classes, err := spec.ClassifyKinds()
+			Expect(err).To(Succeed())
+			Expect(classes[GVK{Group: "example.com", Version: "v1", Kind: "Widget"}]).To(Equal(KindClassResource))
+		})
+	})
+
+	Describe("ResourceCatalog", func() {
+		operation := func(action string, group string, version string, kind string, parameters ...interface{}) map[string]interface{} {
+			return map[string]interface{}{
+				"x-kubernetes-action":             action,
+				"x-kubernetes-group-version-kind": map[string]interface{}{"group": group, "version": version, "kind": kind},
+				"parameters":                      parameters,
+			}
+		}
+		query := func(name string) map[string]interface{} {
+			return map[string]interface{}{"name": name, "in": "query"}
+		}
+
+		It("collects plurals, scopes, verbs, subresources and query parameters", func() {
+			spec := &KubeSpec{
+				Parameters: map[string]interface{}{"pretty-abc": query("pretty")},
+				Paths: map[string]interface{}{
+					"/apis/apps/v1/namespaces/{namespace}/deployments": map[string]interface{}{
+						"get":        operation("list", "apps", "v1", "Deployment", query("labelSelector")),
+						"post":       operation("post", "apps", "v1", "Deployment", query("dryRun")),
+						"parameters": []interface{}{map[string]interface{}{"$ref": "#/parameters/pretty-abc"}},
+					},
+					"/apis/apps/v1/namespaces/{namespace}/deployments/{name}": map[string]interface{}{
+						"patch": map[string]interface{}{
+							"x-kubernetes-action":             "patch",
+							"x-kubernetes-group-version-kind": map[string]interface{}{"group": "apps", "version": "v1", "kind": "Deployment"},
+							"consumes":                        []interface{}{"application/merge-patch+json", "application/apply-patch+yaml"},
+						},
+					},
+					"/apis/apps/v1/deployments": map[string]interface{}{
+						"get": operation("list", "apps", "v1", "Deployment"),
+					},
+					"/apis/apps/v1/watch/namespaces/{namespace}/deployments/{name}": map[string]interface{}{
+						"get": operation("watch", "apps", "v1", "Deployment"),
+					},
+					"/apis/apps/v1/namespaces/{namespace}/deployments/{name}/scale": map[string]interface{}{
+						"get": operation("get", "autoscaling", "v1", "Scale"),
+						"put": operation("put", "autoscaling", "v1", "Scale"),
+					},
+					"/api/v1/namespaces/{name}": map[string]interface{}{
+						"delete": operation("delete", "", "v1", "Namespace"),
+					},
+				},
+			}
+			catalog, err := spec.ResourceCatalog()
+			Expect(err).To(Succeed())
+			Expect(catalog).To(Equal(map[GVK]*CatalogResource{
+				{Group: "apps", Version: "v1", Kind: "Deployment"}: {
+					GVK:              &GVK{Group: "apps", Version: "v1", Kind: "Deployment"},
+					Plural:           "deployments",
+					Namespaced:       true,
+					Verbs:            []string{"create", "list", "patch", "watch"},
+					Subresources:     []string{"scale"},
+					QueryParameters:  []string{"dryRun", "labelSelector", "pretty"},
+					PatchTypes:       []string{"apply-patch+yaml", "merge-patch+json"},
+					SubresourceVerbs: map[string][]string{"scale": {"get", "update"}},
+				},
+				{Version: "v1", Kind: "Namespace"}: {
+					GVK:              &GVK{Version: "v1", Kind: "Namespace"},
+					Plural:           "namespaces",
+					Verbs:            []string{"delete"},
+					Subresources:     []string{},
+					QueryParameters:  []string{},
+					PatchTypes:       []string{},
+					SubresourceVerbs: map[string][]string{},
+				},
+			}))
+
+			Expect(catalog[GVK{Group: "apps", Version: "v1", Kind: "Deployment"}].OperationNames()).To(Equal([]string{
+				"deployments:create",
+				"deployments:list",
+				"deployments:patch",
+				"deployments:watch",
+				"deployments:patch(apply-patch+yaml)",
+				"deployments:patch(merge-patch+json)",
+				"deployments/scale:get",
+				"deployments/scale:update",
+			}))
+		})
+
+		It("fails on missing parameters", func() {
+			spec := &KubeSpec{Paths: map[string]interface{}{
+				"/api/v1/pods": map[string]interface{}{
+					"parameters": []interface{}{map[string]interface{}{"$ref": "#/parameters/missing"}},
+				},
+			}}
+			_, err := spec.ResourceCatalog()
+			Expect(err).NotTo(Succeed())
+		})
+	})
+}
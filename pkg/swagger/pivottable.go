@@ -1,10 +1,12 @@
 package swagger
 
 import (
+	"encoding/csv"
 	"strings"
 
 	"github.com/mattfenwick/collections/pkg/set"
 	"github.com/mattfenwick/collections/pkg/slice"
+	"github.com/mattfenwick/kubectl-schema/pkg/diff"
 	"github.com/olekukonko/tablewriter"
 	"github.com/pkg/errors"
 	"golang.org/x/exp/maps"
@@ -17,30 +19,31 @@ type PivotTable struct {
 	columnSet         *set.Set[string]
 }
 
-func NewPivotTable(firstColumn string, restColumns []string) *PivotTable {
+func NewPivotTable(firstColumn string, restColumns []string) (*PivotTable, error) {
 	columnSet := set.FromSlice(restColumns)
 	if len(restColumns) != columnSet.Len() {
-		panic(errors.Errorf("expected unique columns, found duplicate in %+v", restColumns))
+		return nil, errors.Errorf("expected unique columns, found duplicate in %+v", restColumns)
 	}
 	return &PivotTable{
 		FirstColumnHeader: firstColumn,
 		Rows:              map[string]map[string][]string{},
 		Columns:           restColumns,
 		columnSet:         columnSet,
-	}
+	}, nil
 }
 
-func (e *PivotTable) Add(rowKey string, columnKey string, value string) {
+func (e *PivotTable) Add(rowKey string, columnKey string, value string) error {
 	if !e.columnSet.Contains(columnKey) {
-		panic(errors.Errorf("invalid column name %s, not found in %+v", columnKey, e.Columns))
+		return errors.Errorf("invalid column name %s, not found in %+v", columnKey, e.Columns)
 	}
 	if _, ok := e.Rows[rowKey]; !ok {
 		e.Rows[rowKey] = map[string][]string{}
 	}
 	e.Rows[rowKey][columnKey] = append(e.Rows[rowKey][columnKey], value)
+	return nil
 }
 
-func (e *PivotTable) ToRawTable(formatRow func(rowKey string, values [][]string) []string) *RawTable {
+func (e *PivotTable) ToRawTable(formatRow func(rowKey string, values [][]string) []string) (*RawTable, error) {
 	headers := append([]string{e.FirstColumnHeader}, e.Columns...)
 	var rows [][]string
 
@@ -52,18 +55,108 @@ func (e *PivotTable) ToRawTable(formatRow func(rowKey string, values [][]string)
 	return NewRawTable(headers, rows)
 }
 
+// FilterRows makes a copy with only the rows for which keep returns true
+func (e *PivotTable) FilterRows(keep func(rowKey string, values [][]string) bool) *PivotTable {
+	out := &PivotTable{FirstColumnHeader: e.FirstColumnHeader, Rows: map[string]map[string][]string{}, Columns: e.Columns, columnSet: e.columnSet}
+	for rowKey, row := range e.Rows {
+		if keep(rowKey, slice.Map(func(c string) []string { return row[c] }, e.Columns)) {
+			out.Rows[rowKey] = row
+		}
+	}
+	return out
+}
+
+// PivotTableData is a PivotTable's contents as typed data, for serializing: rows sorted by key, and
+// a cell for every column of every row, with its values sorted
+type PivotTableData struct {
+	FirstColumnHeader string           `json:"firstColumnHeader"`
+	Columns           []string         `json:"columns"`
+	Rows              []*PivotTableRow `json:"rows"`
+}
+
+type PivotTableRow struct {
+	Key   string            `json:"key"`
+	Cells []*PivotTableCell `json:"cells"`
+}
+
+type PivotTableCell struct {
+	Column string   `json:"column"`
+	Values []string `json:"values"`
+	// Diff is relative to the previous column's cell; it's only set in diff mode, and not for the first column
+	Diff *PivotTableCellDiff `json:"diff,omitempty"`
+}
+
+type PivotTableCellDiff struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+func (e *PivotTable) ToData() *PivotTableData {
+	data := &PivotTableData{FirstColumnHeader: e.FirstColumnHeader, Columns: e.Columns, Rows: []*PivotTableRow{}}
+	for _, rowKey := range slice.Sort(maps.Keys(e.Rows)) {
+		row := &PivotTableRow{Key: rowKey}
+		for _, column := range e.Columns {
+			row.Cells = append(row.Cells, &PivotTableCell{Column: column, Values: slice.Sort(append([]string{}, e.Rows[rowKey][column]...))})
+		}
+		data.Rows = append(data.Rows, row)
+	}
+	return data
+}
+
+// AddDiffs sets each cell's diff relative to the cell before it in the same row
+func (d *PivotTableData) AddDiffs() {
+	for _, row := range d.Rows {
+		for i := 1; i < len(row.Cells); i++ {
+			cellDiff := diff.SliceDiff(row.Cells[i-1].Values, row.Cells[i].Values)
+			row.Cells[i].Diff = &PivotTableCellDiff{
+				Added:   slice.Sort(append([]string{}, cellDiff.Added...)),
+				Removed: slice.Sort(append([]string{}, cellDiff.Removed...)),
+			}
+		}
+	}
+}
+
+// ToDelimited writes a header row and then a row per row key, with a cell's values separated by
+// spaces.  Diffs are written as "+added -removed".
+func (d *PivotTableData) ToDelimited(separator rune) (string, error) {
+	out := &strings.Builder{}
+	writer := csv.NewWriter(out)
+	writer.Comma = separator
+	if err := writer.Write(append([]string{d.FirstColumnHeader}, d.Columns...)); err != nil {
+		return "", errors.Wrapf(err, "unable to write header")
+	}
+	for _, row := range d.Rows {
+		record := []string{row.Key}
+		for _, cell := range row.Cells {
+			if cell.Diff == nil {
+				record = append(record, strings.Join(cell.Values, " "))
+			} else {
+				changes := append(
+					slice.Map(func(v string) string { return "+" + v }, cell.Diff.Added),
+					slice.Map(func(v string) string { return "-" + v }, cell.Diff.Removed)...)
+				record = append(record, strings.Join(changes, " "))
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return "", errors.Wrapf(err, "unable to write row %s", row.Key)
+		}
+	}
+	writer.Flush()
+	return out.String(), errors.Wrapf(writer.Error(), "unable to write rows")
+}
+
 type RawTable struct {
 	Headers []string
 	Rows    [][]string
 }
 
-func NewRawTable(headers []string, rows [][]string) *RawTable {
+func NewRawTable(headers []string, rows [][]string) (*RawTable, error) {
 	for i, r := range rows {
 		if len(headers) != len(r) {
-			panic(errors.Errorf("mismatch between length of headers and of row %d: %d vs. %d", i, len(headers), len(r)))
+			return nil, errors.Errorf("mismatch between length of headers and of row %d: %d vs. %d", i, len(headers), len(r))
 		}
 	}
-	return &RawTable{Headers: headers, Rows: rows}
+	return &RawTable{Headers: headers, Rows: rows}, nil
 }
 
 func (r *RawTable) ToMarkdownTable() string {
@@ -78,6 +171,17 @@ func (r *RawTable) ToMarkdownTable() string {
 	return strings.Join(lines, "\n")
 }
 
+// ToDelimited writes the table as csv, or with another separator, such as a tab
+func (r *RawTable) ToDelimited(separator rune) (string, error) {
+	out := &strings.Builder{}
+	writer := csv.NewWriter(out)
+	writer.Comma = separator
+	if err := writer.WriteAll(append([][]string{r.Headers}, r.Rows...)); err != nil {
+		return "", errors.Wrapf(err, "unable to write rows")
+	}
+	return out.String(), nil
+}
+
 func (r *RawTable) ToFormattedTable() string {
 	tableString := &strings.Builder{}
 	table := tablewriter.NewWriter(tableString)
@@ -0,0 +1,251 @@
+package swagger
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mattfenwick/collections/pkg/set"
+	"github.com/mattfenwick/collections/pkg/slice"
+	"github.com/mattfenwick/collections/pkg/yaml"
+	"github.com/mattfenwick/kubectl-schema/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/exp/maps"
+)
+
+type RBACArgs struct {
+	KubeVersion string
+	ApiVersions []string
+	Resources   []string
+	// Manifests are yaml files, or directories of them, whose resources need access
+	Manifests    []string
+	Verbs        []string
+	Subresources []string
+	Name         string
+	Namespace    string
+	CRDs         []string
+}
+
+func RunRBAC(ctx context.Context, args *RBACArgs) error {
+	if len(args.Resources) == 0 && len(args.Manifests) == 0 {
+		return utils.NewUsageError("at least one --resource or --manifests is required")
+	}
+	if len(args.Verbs) == 0 {
+		return utils.NewUsageError("at least one --verb is required")
+	}
+	kubeVersion, err := ParseKubeVersion(args.KubeVersion)
+	if err != nil {
+		return err
+	}
+	spec, err := (&SpecSource{CRDPaths: args.CRDs}).Read(ctx, kubeVersion)
+	if err != nil {
+		return err
+	}
+	catalog, err := spec.ResourceCatalog()
+	if err != nil {
+		return err
+	}
+	index, err := spec.ResourceNameIndex()
+	if err != nil {
+		return err
+	}
+
+	resources, warnings, err := SelectRBACResources(catalog, index, args.ApiVersions, args.Resources)
+	if err != nil {
+		return err
+	}
+	if len(args.Manifests) > 0 {
+		gvks, err := ReadManifestGVKs(args.Manifests)
+		if err != nil {
+			return err
+		}
+		for _, gvk := range gvks {
+			if resource, ok := catalog[*gvk]; ok {
+				resources = append(resources, resource)
+			} else {
+				warnings = append(warnings, fmt.Sprintf("no resource found for %s %s", gvk.ApiVersion(), gvk.Kind))
+			}
+		}
+	}
+
+	role, roleWarnings := BuildRBACRole(resources, args.Verbs, args.Subresources, args.Name, args.Namespace)
+	for _, warning := range append(warnings, roleWarnings...) {
+		logrus.Warnf("kube version %s: %s", kubeVersion.ToString(), warning)
+	}
+	out, err := marshalYaml(role)
+	if err != nil {
+		return err
+	}
+	fmt.Println(out)
+	return nil
+}
+
+// SelectRBACResources picks resources out of a catalog using the usual name filters, with names
+// resolved through index, warning about resource patterns which don't match anything
+func SelectRBACResources(catalog map[GVK]*CatalogResource, index *ResourceNameIndex, apiVersions []string, resources []string) ([]*CatalogResource, []string, error) {
+	if len(resources) == 0 {
+		return nil, nil, nil
+	}
+	filter, err := newGVKFilter(apiVersions, nil, resources, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	allow, err := filter.Resolve(index)
+	if err != nil {
+		return nil, nil, err
+	}
+	var selected []*CatalogResource
+	for _, gvk := range slice.SortOn(func(gvk GVK) string { return gvk.ToString() }, maps.Keys(catalog)) {
+		if allow(&gvk) {
+			selected = append(selected, catalog[gvk])
+		}
+	}
+	var warnings []string
+	for _, resource := range resources {
+		resourceFilter, err := newResourceFilter([]string{resource}, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		allowResource, err := resourceFilter.Resolve(index)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !slice.Any(func(r *CatalogResource) bool { return allowResource(r.GVK.Kind, []*GVK{r.GVK}) }, selected) {
+			warnings = append(warnings, fmt.Sprintf("no resource found for --resource %s", resource))
+		}
+	}
+	return selected, warnings, nil
+}
+
+// ReadManifestGVKs finds the apiVersion and kind of every document in yaml files.  Directories
+// are read non-recursively, as with CRDs.
+func ReadManifestGVKs(paths []string) ([]*GVK, error) {
+	var gvks []*GVK
+	for _, path := range paths {
+		files, err := yamlFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			documents, err := yaml.ParseManyFromFile[map[string]interface{}](file)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to read manifests from %s", file)
+			}
+			for _, document := range documents {
+				apiVersion, _ := document["apiVersion"].(string)
+				kind, _ := document["kind"].(string)
+				if apiVersion == "" || kind == "" {
+					logrus.Debugf("skipping document without apiVersion and kind in %s", file)
+					continue
+				}
+				gvk := &GVK{Version: apiVersion, Kind: kind}
+				if group, version, ok := strings.Cut(apiVersion, "/"); ok {
+					gvk.Group, gvk.Version = group, version
+				}
+				gvks = append(gvks, gvk)
+			}
+		}
+	}
+	return gvks, nil
+}
+
+type RBACPolicyRule struct {
+	ApiGroups []string `json:"apiGroups"`
+	Resources []string `json:"resources"`
+	Verbs     []string `json:"verbs"`
+}
+
+type RBACRole struct {
+	ApiVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace,omitempty"`
+	} `json:"metadata"`
+	Rules []*RBACPolicyRule `json:"rules"`
+}
+
+// BuildRBACRole grants verbs on resources, and on their subresources.  It's a Role if there's a
+// namespace, leaving out cluster-scoped resources, and a ClusterRole otherwise.  Verbs and
+// subresources which a resource doesn't support are left out, with a warning.  Rules are merged
+// for resources in the same api group with the same verbs.
+func BuildRBACRole(resources []*CatalogResource, verbs []string, subresources []string, name string, namespace string) (*RBACRole, []string) {
+	var warnings []string
+	// the same resource may be in the catalog under several versions
+	byGroupAndPlural := map[string]*CatalogResource{}
+	supportedVerbs := map[string]*set.Set[string]{}
+	for _, resource := range resources {
+		key := resource.GVK.Group + "/" + resource.Plural
+		if _, ok := byGroupAndPlural[key]; !ok {
+			byGroupAndPlural[key] = resource
+			supportedVerbs[key] = set.Empty[string]()
+		}
+		for _, verb := range resource.Verbs {
+			supportedVerbs[key].Add(verb)
+		}
+		for _, subresource := range resource.Subresources {
+			subresourceKey := key + "/" + subresource
+			if _, ok := supportedVerbs[subresourceKey]; !ok {
+				supportedVerbs[subresourceKey] = set.Empty[string]()
+			}
+			for _, verb := range resource.SubresourceVerbs[subresource] {
+				supportedVerbs[subresourceKey].Add(verb)
+			}
+		}
+	}
+
+	rulesByGroupAndVerbs := map[string]*RBACPolicyRule{}
+	addRule := func(group string, resourceName string, supported *set.Set[string]) {
+		allowed := slice.Filter(func(verb string) bool {
+			if verb == "*" || supported.Contains(verb) {
+				return true
+			}
+			warnings = append(warnings, fmt.Sprintf("verb %s isn't supported by %s", verb, describeRBACResource(group, resourceName)))
+			return false
+		}, slice.Sort(set.FromSlice(verbs).ToSlice()))
+		if len(allowed) == 0 {
+			return
+		}
+		key := group + " " + strings.Join(allowed, ",")
+		if _, ok := rulesByGroupAndVerbs[key]; !ok {
+			rulesByGroupAndVerbs[key] = &RBACPolicyRule{ApiGroups: []string{group}, Verbs: allowed}
+		}
+		rule := rulesByGroupAndVerbs[key]
+		rule.Resources = append(rule.Resources, resourceName)
+	}
+
+	for _, key := range slice.Sort(maps.Keys(byGroupAndPlural)) {
+		resource := byGroupAndPlural[key]
+		if namespace != "" && !resource.Namespaced {
+			warnings = append(warnings, fmt.Sprintf("%s is cluster-scoped, so it can't be in a Role", describeRBACResource(resource.GVK.Group, resource.Plural)))
+			continue
+		}
+		addRule(resource.GVK.Group, resource.Plural, supportedVerbs[key])
+		for _, subresource := range subresources {
+			supported, ok := supportedVerbs[key+"/"+subresource]
+			if !ok {
+				warnings = append(warnings, fmt.Sprintf("%s has no subresource %s", describeRBACResource(resource.GVK.Group, resource.Plural), subresource))
+				continue
+			}
+			addRule(resource.GVK.Group, resource.Plural+"/"+subresource, supported)
+		}
+	}
+
+	role := &RBACRole{ApiVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"}
+	if namespace != "" {
+		role.Kind = "Role"
+	}
+	role.Metadata.Name = name
+	role.Metadata.Namespace = namespace
+	for _, key := range slice.Sort(maps.Keys(rulesByGroupAndVerbs)) {
+		rule := rulesByGroupAndVerbs[key]
+		rule.Resources = slice.Sort(rule.Resources)
+		role.Rules = append(role.Rules, rule)
+	}
+	return role, warnings
+}
+
+func describeRBACResource(group string, resourceName string) string {
+	return fmt.Sprintf("%s in api group %s", resourceName, (&GVK{Group: group}).GroupName())
+}
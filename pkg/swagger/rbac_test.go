@@ -0,0 +1,102 @@
+package swagger
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/exp/maps"
+)
+
+func RunRBACTests() {
+	Describe("RBAC", func() {
+		pods := &CatalogResource{
+			GVK:              &GVK{Version: "v1", Kind: "Pod"},
+			Plural:           "pods",
+			Namespaced:       true,
+			Verbs:            []string{"create", "delete", "get", "list", "patch", "update", "watch"},
+			Subresources:     []string{"status"},
+			SubresourceVerbs: map[string][]string{"status": {"get", "patch", "update"}},
+		}
+		deployments := &CatalogResource{
+			GVK:        &GVK{Group: "apps", Version: "v1", Kind: "Deployment"},
+			Plural:     "deployments",
+			Namespaced: true,
+			Verbs:      []string{"create", "delete", "get", "list", "patch", "update", "watch"},
+		}
+		nodes := &CatalogResource{
+			GVK:    &GVK{Version: "v1", Kind: "Node"},
+			Plural: "nodes",
+			Verbs:  []string{"get", "list", "watch"},
+		}
+
+		It("merges rules by api group and verbs, and warns about unsupported verbs and subresources", func() {
+			role, warnings := BuildRBACRole([]*CatalogResource{pods, deployments, nodes}, []string{"get", "update"}, []string{"status"}, "operator", "")
+			Expect(role.Kind).To(Equal("ClusterRole"))
+			Expect(role.Metadata.Name).To(Equal("operator"))
+			Expect(role.Rules).To(Equal([]*RBACPolicyRule{
+				{ApiGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"get"}},
+				{ApiGroups: []string{""}, Resources: []string{"pods", "pods/status"}, Verbs: []string{"get", "update"}},
+				{ApiGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get", "update"}},
+			}))
+			Expect(warnings).To(Equal([]string{
+				"verb update isn't supported by nodes in api group core",
+				"nodes in api group core has no subresource status",
+				"deployments in api group apps has no subresource status",
+			}))
+		})
+
+		It("leaves cluster-scoped resources out of Roles", func() {
+			role, warnings := BuildRBACRole([]*CatalogResource{pods, nodes}, []string{"get"}, nil, "operator", "apps")
+			Expect(role.Kind).To(Equal("Role"))
+			Expect(role.Metadata.Namespace).To(Equal("apps"))
+			Expect(role.Rules).To(Equal([]*RBACPolicyRule{
+				{ApiGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+			}))
+			Expect(warnings).To(Equal([]string{"nodes in api group core is cluster-scoped, so it can't be in a Role"}))
+		})
+
+		It("selects resources, and warns about patterns which don't match", func() {
+			catalog := map[GVK]*CatalogResource{*pods.GVK: pods, *deployments.GVK: deployments, *nodes.GVK: nodes}
+			index := NewResourceNameIndex()
+			for _, resource := range catalog {
+				index.Add(resource.GVK, resource.Plural, shortNames[resource.GVK.Group+"/"+resource.Plural])
+			}
+			selected, warnings, err := SelectRBACResources(catalog, index, nil, []string{"deploy", "No*", "Widget"})
+			Expect(err).To(Succeed())
+			Expect(selected).To(Equal([]*CatalogResource{deployments, nodes}))
+			Expect(warnings).To(Equal([]string{"no resource found for --resource Widget"}))
+		})
+
+		It("grants get, list and watch on custom resources", func() {
+			dir := GinkgoT().TempDir()
+			Expect(os.WriteFile(filepath.Join(dir, "widgets.yaml"), []byte(widgetCRD), 0644)).To(Succeed())
+			crds, err := ReadCustomResourceDefinitions([]string{dir})
+			Expect(err).To(Succeed())
+			spec := &KubeSpec{Definitions: map[string]*SpecType{}}
+			Expect(spec.MergeCustomResourceDefinitions(crds)).To(Succeed())
+			catalog, err := spec.ResourceCatalog()
+			Expect(err).To(Succeed())
+
+			role, warnings := BuildRBACRole(maps.Values(catalog), []string{"get", "list", "watch"}, nil, "widget-reader", "")
+			Expect(role.Rules).To(Equal([]*RBACPolicyRule{
+				{ApiGroups: []string{"example.com"}, Resources: []string{"widgets"}, Verbs: []string{"get", "list", "watch"}},
+			}))
+			Expect(warnings).To(BeEmpty())
+		})
+
+		It("reads apiVersions and kinds from manifests", func() {
+			dir := GinkgoT().TempDir()
+			manifest := "apiVersion: apps/v1\nkind: Deployment\n---\napiVersion: v1\nkind: Service\n---\nfoo: bar\n"
+			Expect(os.WriteFile(filepath.Join(dir, "app.yaml"), []byte(manifest), 0644)).To(Succeed())
+
+			gvks, err := ReadManifestGVKs([]string{dir})
+			Expect(err).To(Succeed())
+			Expect(gvks).To(Equal([]*GVK{
+				{Group: "apps", Version: "v1", Kind: "Deployment"},
+				{Version: "v1", Kind: "Service"},
+			}))
+		})
+	})
+}
@@ -1,9 +1,11 @@
 package swagger
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path"
+	"strings"
 
 	"github.com/mattfenwick/collections/pkg/file"
 	"github.com/mattfenwick/collections/pkg/json"
@@ -13,35 +15,112 @@ import (
 )
 
 const (
-	DataDirEnvVar = "KUBECTL_SCHEMA_DATA_DIRECTORY"
+	DataDirEnvVar         = "KUBECTL_SCHEMA_DATA_DIRECTORY"
+	SpecURLTemplateEnvVar = "KUBECTL_SCHEMA_SPEC_URL_TEMPLATE"
+	BearerTokenEnvVar     = "KUBECTL_SCHEMA_BEARER_TOKEN"
+
+	// DefaultSpecURLTemplate is where upstream specs come from; `{version}` is replaced by the kube version
+	DefaultSpecURLTemplate = "https://raw.githubusercontent.com/kubernetes/kubernetes/v{version}/api/openapi-spec/swagger.json"
 )
 
-func getHomeDir() string {
+func GetSpecsRootDirectory() (string, error) {
+	if dataDir, ok := os.LookupEnv(DataDirEnvVar); ok {
+		return dataDir, nil
+	}
 	home, err := os.UserHomeDir()
-	utils.Die(errors.Wrapf(err, "unable to get home dir"))
-	return home
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to get home dir; set %s to choose a data directory", DataDirEnvVar)
+	}
+	return path.Join(home, ".kubectl-schema"), nil
 }
 
-func GetSpecsRootDirectory() string {
-	if dataDir, ok := os.LookupEnv(DataDirEnvVar); ok {
-		return dataDir
+// SpecDownloader fetches upstream specs which aren't in the data directory yet.  URLTemplate may
+// point at a mirror; `{version}` is replaced by the kube version.
+type SpecDownloader struct {
+	URLTemplate string
+	HTTP        *utils.HTTPClient
+}
+
+func NewSpecDownloader(urlTemplate string, options *utils.HTTPOptions) (*SpecDownloader, error) {
+	if !strings.Contains(urlTemplate, "{version}") {
+		return nil, utils.NewUsageError("invalid spec url template '%s': expected it to contain {version}", urlTemplate)
+	}
+	client, err := utils.NewHTTPClient(options)
+	if err != nil {
+		return nil, err
+	}
+	return &SpecDownloader{URLTemplate: urlTemplate, HTTP: client}, nil
+}
+
+// DefaultSpecDownloader is used by SpecSources without a Downloader.  The CLI replaces it, based on flags.
+var DefaultSpecDownloader = &SpecDownloader{URLTemplate: DefaultSpecURLTemplate, HTTP: utils.DefaultHTTPClient()}
+
+func (d *SpecDownloader) URL(version KubeVersion) string {
+	return strings.ReplaceAll(d.URLTemplate, "{version}", version.ToString())
+}
+
+func (d *SpecDownloader) Download(ctx context.Context, version KubeVersion) ([]byte, error) {
+	return d.HTTP.Get(ctx, d.URL(version))
+}
+
+// logDownloadProgress logs every quarter of a download, or every megabyte if the size isn't known.
+// Downloads under a megabyte aren't worth reporting.
+func logDownloadProgress() func(url string, read int64, total int64) {
+	reported := map[string]int64{}
+	return func(url string, read int64, total int64) {
+		var step int64
+		if total >= 0 && total < 1<<20 {
+			return
+		} else if total > 0 {
+			step = read * 4 / total
+		} else {
+			step = read / (1 << 20)
+		}
+		if step <= reported[url] {
+			return
+		}
+		reported[url] = step
+		if total > 0 {
+			logrus.Infof("downloading %s: %d%% of %.1f MB", url, step*25, float64(total)/(1<<20))
+		} else {
+			logrus.Infof("downloading %s: %.1f MB", url, float64(read)/(1<<20))
+		}
 	}
-	return path.Join(getHomeDir(), ".kubectl-schema")
 }
 
-func ReadSwaggerSpecFromGithub(version KubeVersion) (*KubeSpec, error) {
-	specPath := MakePathFromKubeVersion(version)
+// SpecSource describes where specs come from: the upstream swagger spec for a kube version,
+// plus any CustomResourceDefinitions to merge in.
+type SpecSource struct {
+	CRDPaths   []string
+	Downloader *SpecDownloader
+}
+
+func (s *SpecSource) downloader() *SpecDownloader {
+	if s.Downloader == nil {
+		return DefaultSpecDownloader
+	}
+	return s.Downloader
+}
+
+// ReadBytes reads the upstream spec for a kube version from the data directory, downloading it first
+// if necessary
+func (s *SpecSource) ReadBytes(ctx context.Context, version KubeVersion) ([]byte, error) {
+	specPath, err := MakePathFromKubeVersion(version)
+	if err != nil {
+		return nil, err
+	}
 
 	if !file.Exists(specPath) {
-		logrus.Infof("file for version %s not found (path %s); downloading instead", version, specPath)
+		downloader := s.downloader()
+		logrus.Infof("file for version %s not found (path %s); downloading from %s", version.ToString(), specPath, downloader.URL(version))
 
-		dataDir := GetSpecsRootDirectory()
+		dataDir := path.Dir(specPath)
 		err := os.MkdirAll(dataDir, 0777)
 		if err != nil {
 			return nil, errors.Wrapf(err, "unable to mkdir %s", dataDir)
 		}
 
-		err = utils.GetFileFromURL(version.SwaggerSpecURL(), specPath)
+		err = downloader.HTTP.GetFile(ctx, downloader.URL(version), specPath)
 		if err != nil {
 			return nil, err
 		}
@@ -53,24 +132,42 @@ func ReadSwaggerSpecFromGithub(version KubeVersion) (*KubeSpec, error) {
 		}
 	}
 
-	spec, err := json.ParseFile[KubeSpec](specPath)
-	utils.Die(err)
-
-	return spec, nil
+	return file.Read(specPath)
 }
 
-func MustReadSwaggerSpecFromGithub(version KubeVersion) *KubeSpec {
-	spec, err := ReadSwaggerSpecFromGithub(version)
-	utils.Die(err)
-	return spec
+// ReadUpstream reads the upstream spec for a kube version, without any CRDs
+func (s *SpecSource) ReadUpstream(ctx context.Context, version KubeVersion) (*KubeSpec, error) {
+	bytes, err := s.ReadBytes(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := json.Parse[KubeSpec](bytes)
+	return spec, errors.Wrapf(err, "unable to parse spec for kube version %s", version.ToString())
 }
 
-func MustDownloadSwaggerSpec(version KubeVersion) []byte {
-	bytes, err := utils.GetURL(version.SwaggerSpecURL())
-	utils.Die(err)
-	return bytes
+func (s *SpecSource) Read(ctx context.Context, version KubeVersion) (*KubeSpec, error) {
+	spec, err := s.ReadUpstream(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.CRDPaths) > 0 {
+		crds, err := ReadCustomResourceDefinitions(s.CRDPaths)
+		if err != nil {
+			return nil, err
+		}
+		logrus.Debugf("merging %d crds into spec for kube version %s", len(crds), version.ToString())
+		err = spec.MergeCustomResourceDefinitions(crds)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return spec, nil
 }
 
-func MakePathFromKubeVersion(version KubeVersion) string {
-	return fmt.Sprintf("%s/%s-swagger-spec.json", GetSpecsRootDirectory(), version.ToString())
+func MakePathFromKubeVersion(version KubeVersion) (string, error) {
+	dataDir, err := GetSpecsRootDirectory()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s-swagger-spec.json", dataDir, version.ToString()), nil
 }
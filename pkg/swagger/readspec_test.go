@@ -0,0 +1,54 @@
+package swagger
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	"github.com/mattfenwick/collections/pkg/file"
+	"github.com/mattfenwick/kubectl-schema/pkg/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func RunReadSpecTests() {
+	Describe("SpecSource", func() {
+		It("downloads missing specs from a mirror, and then reads them from the data directory", func() {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				Expect(r.URL.Path).To(Equal("/mirror/v1.30.2/swagger.json"))
+				_, _ = w.Write([]byte(`{"definitions": {"io.example.v1.Thing": {"type": "object"}}}`))
+			}))
+			defer server.Close()
+
+			dataDir := GinkgoT().TempDir()
+			previous, wasSet := os.LookupEnv(DataDirEnvVar)
+			Expect(os.Setenv(DataDirEnvVar, dataDir)).To(Succeed())
+			DeferCleanup(func() {
+				if wasSet {
+					_ = os.Setenv(DataDirEnvVar, previous)
+				} else {
+					_ = os.Unsetenv(DataDirEnvVar)
+				}
+			})
+
+			downloader, err := NewSpecDownloader(server.URL+"/mirror/v{version}/swagger.json", utils.DefaultHTTPOptions())
+			Expect(err).To(Succeed())
+			source := &SpecSource{Downloader: downloader}
+			for i := 0; i < 2; i++ {
+				spec, err := source.ReadUpstream(context.Background(), MustVersion("1.30.2"))
+				Expect(err).To(Succeed())
+				Expect(spec.Definitions["io.example.v1.Thing"].Type).To(Equal("object"))
+			}
+			Expect(requests).To(Equal(1))
+			Expect(file.Exists(dataDir + "/1.30.2-swagger-spec.json")).To(BeTrue())
+		})
+
+		It("rejects url templates without a version", func() {
+			_, err := NewSpecDownloader("https://example.com/swagger.json", utils.DefaultHTTPOptions())
+			Expect(utils.ExitCode(err)).To(Equal(utils.ExitCodeUsage))
+		})
+	})
+}
@@ -5,9 +5,9 @@ import (
 	"github.com/mattfenwick/collections/pkg/slice"
 	"github.com/mattfenwick/kubectl-schema/pkg/diff"
 	"github.com/mattfenwick/kubectl-schema/pkg/utils"
-	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/exp/maps"
+	"strings"
 )
 
 func CompareResolvedResources(a *ResolvedType, b *ResolvedType) *diff.JsonDiff {
@@ -27,8 +27,8 @@ func CompareResolvedResourcesHelper(a *ResolvedType, b *ResolvedType, pathContex
 	} else if b == nil {
 		diffs.Add(&diff.Node{Kind: diff.KindRemove, Old: a, New: b, Path: path})
 	} else {
-		if a.Empty {
-			if !b.Empty {
+		if !a.hasShape() {
+			if b.hasShape() {
 				diffs.Add(&diff.Node{Kind: diff.KindChange, Old: a, New: b, Path: path})
 			}
 		} else if a.Primitive != "" {
@@ -69,12 +69,82 @@ func CompareResolvedResourcesHelper(a *ResolvedType, b *ResolvedType, pathContex
 			} else {
 				diffs.Add(&diff.Node{Kind: diff.KindChange, Old: a, New: b, Path: path})
 			}
-		} else if a.Circular != "" {
-			if a.Circular != b.Circular {
-				diffs.Add(&diff.Node{Kind: diff.KindChange, Old: a, New: b, Path: path})
-			}
-		} else {
-			panic(errors.Errorf("invalid ResolvedType value: %+v", a))
+		} else if a.Circular != b.Circular {
+			diffs.Add(&diff.Node{Kind: diff.KindChange, Old: a, New: b, Path: path})
+		}
+		compareConstraints(a.Constraints, b.Constraints, path, diffs)
+	}
+}
+
+// ConstraintValue is the Old or New value of a diff.Node for a schema keyword, such as `maxLength`.
+// Keyword nodes have paths ending in `@<keyword>`.
+type ConstraintValue struct {
+	Keyword string
+	Value   interface{}
+}
+
+func compareConstraints(a *Constraints, b *Constraints, path []string, diffs *diff.JsonDiff) {
+	aKeywords, bKeywords := a.Keywords(), b.Keywords()
+	delete(aKeywords, validationsKeyword)
+	delete(bKeywords, validationsKeyword)
+	compareValidationRules(a.ValidationRules(), b.ValidationRules(), path, diffs)
+	for _, keyword := range slice.Sort(maps.Keys(aKeywords)) {
+		keywordPath := slice.Append(path, []string{"@" + keyword})
+		old := &ConstraintValue{Keyword: keyword, Value: aKeywords[keyword]}
+		if newValue, ok := bKeywords[keyword]; !ok {
+			diffs.Add(&diff.Node{Kind: diff.KindRemove, Old: old, Path: keywordPath})
+		} else if ConstraintValueString(old.Value) != ConstraintValueString(newValue) {
+			diffs.Add(&diff.Node{Kind: diff.KindChange, Old: old, New: &ConstraintValue{Keyword: keyword, Value: newValue}, Path: keywordPath})
+		}
+	}
+	for _, keyword := range slice.Sort(maps.Keys(bKeywords)) {
+		if _, ok := aKeywords[keyword]; !ok {
+			diffs.Add(&diff.Node{Kind: diff.KindAdd, New: &ConstraintValue{Keyword: keyword, Value: bKeywords[keyword]}, Path: slice.Append(path, []string{"@" + keyword})})
 		}
 	}
 }
+
+// compareValidationRules matches up rules by their CEL expression, so that reordering rules isn't a change.
+// A rule whose message, reason or field path changed is reported as a change.
+func compareValidationRules(a []*ValidationRule, b []*ValidationRule, path []string, diffs *diff.JsonDiff) {
+	rulePath := slice.Append(path, []string{"@" + validationsKeyword})
+	bRules := map[string]*ValidationRule{}
+	for _, rule := range b {
+		bRules[rule.Rule] = rule
+	}
+	aRules := map[string]*ValidationRule{}
+	for _, rule := range a {
+		aRules[rule.Rule] = rule
+		old := &ConstraintValue{Keyword: validationsKeyword, Value: rule}
+		if newRule, ok := bRules[rule.Rule]; !ok {
+			diffs.Add(&diff.Node{Kind: diff.KindRemove, Old: old, Path: rulePath})
+		} else if ConstraintValueString(rule) != ConstraintValueString(newRule) {
+			diffs.Add(&diff.Node{Kind: diff.KindChange, Old: old, New: &ConstraintValue{Keyword: validationsKeyword, Value: newRule}, Path: rulePath})
+		}
+	}
+	for _, rule := range b {
+		if _, ok := aRules[rule.Rule]; !ok {
+			diffs.Add(&diff.Node{Kind: diff.KindAdd, New: &ConstraintValue{Keyword: validationsKeyword, Value: rule}, Path: rulePath})
+		}
+	}
+}
+
+// FormatResourceChange prints a change's path, along with the old and new values for keywords
+func FormatResourceChange(e *diff.Node) string {
+	line := fmt.Sprintf("  %-20s    %+v", e.Kind.Short(), strings.Join(e.Path, "."))
+	old, isOldKeyword := e.Old.(*ConstraintValue)
+	new, isNewKeyword := e.New.(*ConstraintValue)
+	if isOldKeyword || isNewKeyword {
+		line = fmt.Sprintf("%-60s    %s -> %s", line, old.String(), new.String())
+	}
+	return line
+}
+
+func (c *ConstraintValue) String() string {
+	if c == nil {
+		return "(none)"
+	} else if rule, ok := c.Value.(*ValidationRule); ok {
+		return rule.String()
+	}
+	return ConstraintValueString(c.Value)
+}
@@ -0,0 +1,199 @@
+package swagger
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mattfenwick/collections/pkg/set"
+	"github.com/mattfenwick/collections/pkg/slice"
+	"github.com/mattfenwick/kubectl-schema/pkg/utils"
+	"golang.org/x/exp/maps"
+)
+
+// shortNames are the short names kubectl knows for built-in resources, keyed by group and plural,
+// since the spec doesn't have them
+var shortNames = map[string][]string{
+	"/componentstatuses":      {"cs"},
+	"/configmaps":             {"cm"},
+	"/endpoints":              {"ep"},
+	"/events":                 {"ev"},
+	"/limitranges":            {"limits"},
+	"/namespaces":             {"ns"},
+	"/nodes":                  {"no"},
+	"/persistentvolumeclaims": {"pvc"},
+	"/persistentvolumes":      {"pv"},
+	"/pods":                   {"po"},
+	"/replicationcontrollers": {"rc"},
+	"/resourcequotas":         {"quota"},
+	"/serviceaccounts":        {"sa"},
+	"/services":               {"svc"},
+	"apiextensions.k8s.io/customresourcedefinitions": {"crd", "crds"},
+	"apps/daemonsets":                                          {"ds"},
+	"apps/deployments":                                         {"deploy"},
+	"apps/replicasets":                                         {"rs"},
+	"apps/statefulsets":                                        {"sts"},
+	"autoscaling/horizontalpodautoscalers":                     {"hpa"},
+	"batch/cronjobs":                                           {"cj"},
+	"certificates.k8s.io/certificatesigningrequests":           {"csr"},
+	"events.k8s.io/events":                                     {"ev"},
+	"extensions/daemonsets":                                    {"ds"},
+	"extensions/deployments":                                   {"deploy"},
+	"extensions/ingresses":                                     {"ing"},
+	"extensions/networkpolicies":                               {"netpol"},
+	"extensions/podsecuritypolicies":                           {"psp"},
+	"extensions/replicasets":                                   {"rs"},
+	"networking.k8s.io/ingresses":                              {"ing"},
+	"networking.k8s.io/networkpolicies":                        {"netpol"},
+	"policy/poddisruptionbudgets":                              {"pdb"},
+	"policy/podsecuritypolicies":                               {"psp"},
+	"scheduling.k8s.io/priorityclasses":                        {"pc"},
+	"storage.k8s.io/storageclasses":                            {"sc"},
+	"admissionregistration.k8s.io/validatingadmissionpolicies": {"vap"},
+}
+
+// groupPriorities are the api server's priorities for built-in groups, which kubectl uses to choose
+// between groups with a resource of the same name.  The core group comes first; other groups, such
+// as those from CRDs, come after all of these.
+var groupPriorities = map[string]int{
+	"":                             18000,
+	"extensions":                   17900,
+	"apps":                         17800,
+	"events.k8s.io":                17750,
+	"authentication.k8s.io":        17700,
+	"authorization.k8s.io":         17600,
+	"autoscaling":                  17500,
+	"batch":                        17400,
+	"certificates.k8s.io":          17300,
+	"networking.k8s.io":            17200,
+	"policy":                       17100,
+	"rbac.authorization.k8s.io":    17000,
+	"settings.k8s.io":              16900,
+	"storage.k8s.io":               16800,
+	"apiextensions.k8s.io":         16700,
+	"admissionregistration.k8s.io": 16700,
+	"scheduling.k8s.io":            16600,
+	"coordination.k8s.io":          16500,
+	"node.k8s.io":                  16300,
+	"discovery.k8s.io":             16200,
+	"flowcontrol.apiserver.k8s.io": 16100,
+	"internal.apiserver.k8s.io":    16000,
+	"resource.k8s.io":              15900,
+	"storagemigration.k8s.io":      15800,
+}
+
+var resourceNameVersionRegex = regexp.MustCompile(`^v\d+((alpha|beta)\d+)?$`)
+
+type indexedResource struct {
+	gvk        *GVK
+	plural     string
+	shortNames []string
+}
+
+// ResourceNameIndex resolves resource names the way kubectl does: by kind, in any case, plural or
+// short name, optionally qualified by group, as in `deployments.apps`, or by version and group, as
+// in `ingresses.v1.networking.k8s.io`.  The core group is empty, as in `pods.v1`.
+type ResourceNameIndex struct {
+	resources []*indexedResource
+	kinds     *set.Set[string]
+}
+
+func NewResourceNameIndex() *ResourceNameIndex {
+	return &ResourceNameIndex{kinds: set.Empty[string]()}
+}
+
+// Add indexes a resource; plural and shortNames may be empty
+func (r *ResourceNameIndex) Add(gvk *GVK, plural string, shortNames []string) {
+	r.resources = append(r.resources, &indexedResource{gvk: gvk, plural: plural, shortNames: shortNames})
+	r.kinds.Add(gvk.Kind)
+}
+
+// HasKind is true if a kind is indexed, exactly as written
+func (r *ResourceNameIndex) HasKind(kind string) bool {
+	return r.kinds.Contains(kind)
+}
+
+// ResourceNameIndex indexes every GVK in the spec's definitions, with plurals from its paths, and
+// short names from kubectl's built-in table
+func (s *KubeSpec) ResourceNameIndex() (*ResourceNameIndex, error) {
+	catalog, err := s.ResourceCatalog()
+	if err != nil {
+		return nil, err
+	}
+	index := NewResourceNameIndex()
+	for _, name := range slice.Sort(maps.Keys(s.Definitions)) {
+		for _, gvk := range s.Definitions[name].XKubernetesGroupVersionKind {
+			var plural string
+			if resource, ok := catalog[*gvk]; ok {
+				plural = resource.Plural
+			}
+			index.Add(gvk, plural, shortNames[gvk.Group+"/"+plural])
+		}
+	}
+	return index, nil
+}
+
+// Resolve finds the GVKs a name refers to -- every version, unless the name has one.  If the name
+// refers to kinds in more than one group, the group with the highest priority wins, as in kubectl;
+// it's a usage error if that's a tie, and flag is used in the error message.  Names which aren't
+// found resolve to nothing, since resources come and go between kube versions.
+func (r *ResourceNameIndex) Resolve(flag string, name string) ([]*GVK, error) {
+	resource, qualifier, _ := strings.Cut(strings.ToLower(name), ".")
+	var version, group string
+	qualified := qualifier != ""
+	if qualified {
+		first, rest, _ := strings.Cut(qualifier, ".")
+		if resourceNameVersionRegex.MatchString(first) {
+			version, group = first, rest
+		} else {
+			group = qualifier
+		}
+	}
+
+	var matches []*indexedResource
+	for _, indexed := range r.resources {
+		if qualified && (indexed.gvk.Group != group || (version != "" && indexed.gvk.Version != version)) {
+			continue
+		}
+		if indexed.matches(resource) {
+			matches = append(matches, indexed)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, nil
+	}
+
+	priority := 0
+	for _, indexed := range matches {
+		priority = max(priority, indexed.groupPriority())
+	}
+	preferred := slice.Filter(func(indexed *indexedResource) bool { return indexed.groupPriority() == priority }, matches)
+	candidates := set.FromSlice(slice.Map(func(indexed *indexedResource) string { return indexed.describe() }, preferred))
+	if candidates.Len() > 1 {
+		return nil, utils.NewUsageError("--%s '%s' is ambiguous; it could be any of: %s", flag, name, strings.Join(slice.Sort(candidates.ToSlice()), ", "))
+	}
+	return slice.Map(func(indexed *indexedResource) *GVK { return indexed.gvk }, preferred), nil
+}
+
+func (i *indexedResource) groupPriority() int {
+	// every group gets a priority above 0
+	return max(1, groupPriorities[i.gvk.Group])
+}
+
+func (i *indexedResource) matches(resource string) bool {
+	return resource == strings.ToLower(i.gvk.Kind) ||
+		(i.plural != "" && resource == i.plural) ||
+		slice.Any(func(shortName string) bool { return resource == shortName }, i.shortNames)
+}
+
+// describe names a kind in a group the way kubectl would, as in `deployments.apps (Deployment)`
+func (i *indexedResource) describe() string {
+	name := strings.ToLower(i.gvk.Kind)
+	if i.plural != "" {
+		name = i.plural
+	}
+	if i.gvk.Group != "" {
+		name = fmt.Sprintf("%s.%s", name, i.gvk.Group)
+	}
+	return fmt.Sprintf("%s (%s)", name, i.gvk.Kind)
+}
@@ -0,0 +1,109 @@
+package swagger
+
+import (
+	"github.com/mattfenwick/kubectl-schema/pkg/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func RunResourceNamesTests() {
+	Describe("Resource names", func() {
+		pod := &GVK{Version: "v1", Kind: "Pod"}
+		deployment := &GVK{Group: "apps", Version: "v1", Kind: "Deployment"}
+		extensionsDeployment := &GVK{Group: "extensions", Version: "v1beta1", Kind: "Deployment"}
+		ingress := &GVK{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"}
+		betaIngress := &GVK{Group: "networking.k8s.io", Version: "v1beta1", Kind: "Ingress"}
+		crd := &GVK{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}
+		event := &GVK{Version: "v1", Kind: "Event"}
+		eventsEvent := &GVK{Group: "events.k8s.io", Version: "v1", Kind: "Event"}
+		comWidget := &GVK{Group: "example.com", Version: "v1", Kind: "Widget"}
+		orgWidget := &GVK{Group: "example.org", Version: "v1", Kind: "Widget"}
+
+		index := NewResourceNameIndex()
+		index.Add(pod, "pods", shortNames["/pods"])
+		index.Add(deployment, "deployments", shortNames["apps/deployments"])
+		index.Add(extensionsDeployment, "deployments", shortNames["extensions/deployments"])
+		index.Add(ingress, "ingresses", shortNames["networking.k8s.io/ingresses"])
+		index.Add(betaIngress, "ingresses", shortNames["networking.k8s.io/ingresses"])
+		index.Add(crd, "customresourcedefinitions", shortNames["apiextensions.k8s.io/customresourcedefinitions"])
+		index.Add(event, "events", shortNames["/events"])
+		index.Add(eventsEvent, "events", shortNames["events.k8s.io/events"])
+		index.Add(comWidget, "widgets", nil)
+		index.Add(orgWidget, "widgets", nil)
+
+		It("resolves kinds, plurals and short names", func() {
+			for name, expected := range map[string][]*GVK{
+				"pods":    {pod},
+				"po":      {pod},
+				"pod":     {pod},
+				"PODS":    {pod},
+				"crd":     {crd},
+				"ingress": {ingress, betaIngress},
+				"nope":    nil,
+			} {
+				resolved, err := index.Resolve("resource", name)
+				Expect(err).To(Succeed())
+				Expect(resolved).To(Equal(expected), name)
+			}
+		})
+
+		It("resolves names qualified by group, or by version and group", func() {
+			for name, expected := range map[string][]*GVK{
+				"Deployment.apps":                   {deployment},
+				"deploy.extensions":                 {extensionsDeployment},
+				"ingresses.networking.k8s.io":       {ingress, betaIngress},
+				"ingresses.v1.networking.k8s.io":    {ingress},
+				"ingress.v1beta1.networking.k8s.io": {betaIngress},
+				"pods.v1":                           {pod},
+				"events.v1":                         {event},
+			} {
+				resolved, err := index.Resolve("resource", name)
+				Expect(err).To(Succeed())
+				Expect(resolved).To(Equal(expected), name)
+			}
+		})
+
+		It("prefers the core group, and then groups the api server gives priority to, as kubectl does", func() {
+			for name, expected := range map[string][]*GVK{
+				"events":           {event},
+				"ev":               {event},
+				"deploy":           {extensionsDeployment},
+				"deployments":      {extensionsDeployment},
+				"ev.events.k8s.io": {eventsEvent},
+			} {
+				resolved, err := index.Resolve("resource", name)
+				Expect(err).To(Succeed())
+				Expect(resolved).To(Equal(expected), name)
+			}
+		})
+
+		It("lists the candidates for names which are a tie between groups", func() {
+			_, err := index.Resolve("resource", "widgets")
+			Expect(utils.ExitCode(err)).To(Equal(utils.ExitCodeUsage))
+			Expect(err).To(MatchError("--resource 'widgets' is ambiguous; it could be any of: widgets.example.com (Widget), widgets.example.org (Widget)"))
+
+			resolved, err := index.Resolve("resource", "widgets.example.org")
+			Expect(err).To(Succeed())
+			Expect(resolved).To(Equal([]*GVK{orgWidget}))
+		})
+
+		It("filters by resolved names, and treats exact kinds as kinds", func() {
+			filter, err := newResourceFilter([]string{"deploy.apps", "Event", "Ingress*"}, []string{"ingresses.v1beta1.networking.k8s.io"})
+			Expect(err).To(Succeed())
+			allow, err := filter.Resolve(index)
+			Expect(err).To(Succeed())
+			Expect(allow("Deployment", []*GVK{deployment})).To(BeTrue())
+			Expect(allow("Deployment", []*GVK{extensionsDeployment})).To(BeFalse())
+			Expect(allow("Event", []*GVK{event})).To(BeTrue())
+			Expect(allow("Event", []*GVK{eventsEvent})).To(BeTrue())
+			Expect(allow("Ingress", []*GVK{ingress})).To(BeTrue())
+			Expect(allow("Ingress", []*GVK{betaIngress})).To(BeFalse())
+			Expect(allow("Pod", []*GVK{pod})).To(BeFalse())
+
+			filter, err = newResourceFilter([]string{"widget"}, nil)
+			Expect(err).To(Succeed())
+			_, err = filter.Resolve(index)
+			Expect(err).To(MatchError(ContainSubstring("widgets.example.org (Widget)")))
+		})
+	})
+}
@@ -1,13 +1,18 @@
 package swagger
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
+	"github.com/mattfenwick/collections/pkg/json"
+	"github.com/mattfenwick/collections/pkg/set"
 	"github.com/mattfenwick/collections/pkg/slice"
 	"github.com/mattfenwick/kubectl-schema/pkg/diff"
+	"github.com/mattfenwick/kubectl-schema/pkg/utils"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/exp/maps"
 )
 
 // section: types
@@ -17,40 +22,141 @@ type ShowResourcesArgs struct {
 	KubeVersions []string
 	ApiVersions  []string
 	Resources    []string
-	Diff         bool
-	Format       string
+	// ExcludeApiVersions and ExcludeResources are patterns to leave out, even if they're included
+	ExcludeApiVersions []string
+	ExcludeResources   []string
+	Groups             []string
+	Stabilities        []string
+	KindClasses        []string
+	Diff               bool
+	// Catalog shows what the spec's paths say about each resource, instead of grouping
+	Catalog bool
+	// Operations shows each resource's verbs, subresources and patch types, instead of grouping
+	Operations bool
+	Format     string
+	CRDs       []string
 	// TODO add flag to verify parsing?  by serializing/deserializing to check if it matches input?
 }
 
-func (s *ShowResourcesArgs) GetGroupBy() ShowResourcesGroupBy {
+func (s *ShowResourcesArgs) GetGroupBy() (ShowResourcesGroupBy, error) {
 	switch s.GroupBy {
 	case "resource":
-		return ShowResourcesGroupByResource
+		return ShowResourcesGroupByResource, nil
 	case "apiversion", "api-version":
-		return ShowResourcesGroupByApiVersion
+		return ShowResourcesGroupByApiVersion, nil
+	case "group":
+		return ShowResourcesGroupByGroup, nil
+	case "stability":
+		return ShowResourcesGroupByStability, nil
 	default:
-		panic(errors.Errorf("invalid group by value: %s", s.GroupBy))
+		return "", utils.NewUsageError("invalid --group-by '%s'; valid values are: resource, api-version, group, stability", s.GroupBy)
 	}
 }
 
-func (s *ShowResourcesArgs) GetFormat() ShowResourcesFormat {
+func (s *ShowResourcesArgs) GetFormat() (ShowResourcesFormat, error) {
 	switch s.Format {
 	case "table":
-		return ShowResourcesFormatTable
+		return ShowResourcesFormatTable, nil
 	case "markdown":
-		return ShowResourcesFormatMarkdown
+		return ShowResourcesFormatMarkdown, nil
+	case "json":
+		return ShowResourcesFormatJson, nil
+	case "yaml":
+		return ShowResourcesFormatYaml, nil
+	case "csv":
+		return ShowResourcesFormatCsv, nil
+	case "tsv":
+		return ShowResourcesFormatTsv, nil
 	default:
-		panic(errors.Errorf("invalid format value: %s", s.Format))
+		return "", utils.NewUsageError("invalid --format '%s'; valid values are: table, markdown, json, yaml, csv, tsv", s.Format)
 	}
 }
 
-func RunShowResources(args *ShowResourcesArgs) {
-	fmt.Printf("\n%s\n\n",
-		ShowResources(args.GetGroupBy(),
+// ResourceIncluder builds a filter for a spec's resources, since names like `deploy` are resolved
+// against each spec
+type ResourceIncluder func(index *ResourceNameIndex) (func(*GVK, KindClass) bool, error)
+
+// GetInclude combines the api version, resource, group, stability and kind class filters
+func (s *ShowResourcesArgs) GetInclude() (ResourceIncluder, error) {
+	names, err := newGVKFilter(s.ApiVersions, s.ExcludeApiVersions, s.Resources, s.ExcludeResources)
+	if err != nil {
+		return nil, err
+	}
+	allowGroup, err := nameFilter("group", s.Groups, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, stability := range s.Stabilities {
+		if err := validateChoice("stability", stability, stabilityChoices); err != nil {
+			return nil, err
+		}
+	}
+	stabilities := set.FromSlice(s.Stabilities)
+	classes := set.FromSlice(s.KindClasses)
+	if classes.Contains("all") {
+		classes = set.Empty[string]()
+	}
+	for _, class := range classes.ToSlice() {
+		if err := validateChoice("kind-class", class, slice.Append(kindClassChoices, []string{"all"})); err != nil {
+			return nil, err
+		}
+	}
+	return func(index *ResourceNameIndex) (func(*GVK, KindClass) bool, error) {
+		allowNames, err := names.Resolve(index)
+		if err != nil {
+			return nil, err
+		}
+		return func(gvk *GVK, class KindClass) bool {
+			return allowNames(gvk) &&
+				allowGroup(gvk.GroupName()) &&
+				(stabilities.Len() == 0 || stabilities.Contains(gvk.Stability())) &&
+				(classes.Len() == 0 || classes.Contains(string(class)))
+		}, nil
+	}, nil
+}
+
+func RunShowResources(ctx context.Context, args *ShowResourcesArgs) error {
+	groupBy, err := args.GetGroupBy()
+	if err != nil {
+		return err
+	}
+	format, err := args.GetFormat()
+	if err != nil {
+		return err
+	}
+	include, err := args.GetInclude()
+	if err != nil {
+		return err
+	}
+	var out string
+	if args.Catalog && args.Operations {
+		return utils.NewUsageError("--catalog can't be used with --operations")
+	}
+	if args.Catalog {
+		if args.Diff {
+			return utils.NewUsageError("--diff can't be used with --catalog")
+		}
+		out, err = ShowResourceCatalog(ctx, &SpecSource{CRDPaths: args.CRDs}, args.KubeVersions, include, format)
+	} else if args.Operations {
+		out, err = ShowResourceOperations(ctx, &SpecSource{CRDPaths: args.CRDs}, args.KubeVersions, include, args.Diff, format)
+	} else {
+		out, err = ShowResources(ctx, &SpecSource{CRDPaths: args.CRDs},
+			groupBy,
 			args.KubeVersions,
-			apiVersionAndResourceAllower(args.ApiVersions, args.Resources),
+			include,
 			args.Diff,
-			args.GetFormat()))
+			format)
+	}
+	if err != nil {
+		return err
+	}
+	switch format {
+	case ShowResourcesFormatTable, ShowResourcesFormatMarkdown:
+		fmt.Printf("\n%s\n\n", out)
+	default:
+		fmt.Println(out)
+	}
+	return nil
 }
 
 type ShowResourcesGroupBy string
@@ -58,16 +164,22 @@ type ShowResourcesGroupBy string
 const (
 	ShowResourcesGroupByResource   ShowResourcesGroupBy = "ShowResourcesGroupByResource"
 	ShowResourcesGroupByApiVersion ShowResourcesGroupBy = "ShowResourcesGroupByApiVersion"
+	ShowResourcesGroupByGroup      ShowResourcesGroupBy = "ShowResourcesGroupByGroup"
+	ShowResourcesGroupByStability  ShowResourcesGroupBy = "ShowResourcesGroupByStability"
 )
 
-func (s ShowResourcesGroupBy) Header() string {
+func (s ShowResourcesGroupBy) Header() (string, error) {
 	switch s {
 	case ShowResourcesGroupByResource:
-		return "Resource"
+		return "Resource", nil
 	case ShowResourcesGroupByApiVersion:
-		return "API version"
+		return "API version", nil
+	case ShowResourcesGroupByGroup:
+		return "Group", nil
+	case ShowResourcesGroupByStability:
+		return "Stability", nil
 	default:
-		panic(errors.Errorf("invalid groupBy: %s", s))
+		return "", errors.Errorf("invalid groupBy: %s", s)
 	}
 }
 
@@ -76,32 +188,80 @@ type ShowResourcesFormat string
 const (
 	ShowResourcesFormatTable    ShowResourcesFormat = "ShowResourcesFormatTable"
 	ShowResourcesFormatMarkdown ShowResourcesFormat = "ShowResourcesFormatMarkdown"
+	ShowResourcesFormatJson     ShowResourcesFormat = "ShowResourcesFormatJson"
+	ShowResourcesFormatYaml     ShowResourcesFormat = "ShowResourcesFormatYaml"
+	ShowResourcesFormatCsv      ShowResourcesFormat = "ShowResourcesFormatCsv"
+	ShowResourcesFormatTsv      ShowResourcesFormat = "ShowResourcesFormatTsv"
 )
 
 // section: functionality
 
-func ShowResources(groupBy ShowResourcesGroupBy, versions []string, include func(string, string) bool, calculateDiff bool, format ShowResourcesFormat) string {
-	table := NewPivotTable(groupBy.Header(), versions)
+func ShowResources(ctx context.Context, source *SpecSource, groupBy ShowResourcesGroupBy, versions []string, include ResourceIncluder, calculateDiff bool, format ShowResourcesFormat) (string, error) {
+	table, err := BuildResourcesTable(ctx, source, groupBy, versions, include)
+	if err != nil {
+		return "", err
+	}
+	return FormatResourcesTable(table, calculateDiff, format)
+}
+
+// BuildResourcesTable has a row per resource, api version, group or stability level, and a column
+// per kube version
+func BuildResourcesTable(ctx context.Context, source *SpecSource, groupBy ShowResourcesGroupBy, versions []string, include ResourceIncluder) (*PivotTable, error) {
+	header, err := groupBy.Header()
+	if err != nil {
+		return nil, err
+	}
+	if err := validateKubeVersions(versions); err != nil {
+		return nil, err
+	}
+	table, err := NewPivotTable(header, versions)
+	if err != nil {
+		return nil, err
+	}
 	for _, version := range versions {
-		kubeVersion := MustVersion(version)
+		kubeVersion, err := ParseKubeVersion(version)
+		if err != nil {
+			return nil, err
+		}
 		logrus.Debugf("kube version: %s", version)
 
-		spec := MustReadSwaggerSpecFromGithub(kubeVersion)
+		spec, err := source.Read(ctx, kubeVersion)
+		if err != nil {
+			return nil, err
+		}
+		classes, err := spec.ClassifyKinds()
+		if err != nil {
+			return nil, err
+		}
+		index, err := spec.ResourceNameIndex()
+		if err != nil {
+			return nil, err
+		}
+		allow, err := include(index)
+		if err != nil {
+			return nil, err
+		}
 		for name, def := range spec.Definitions {
 			if len(def.XKubernetesGroupVersionKind) > 0 {
 				logrus.Debugf("%s, %s, %+v\n", name, def.Type, def.XKubernetesGroupVersionKind)
 			}
 			for _, gvk := range def.XKubernetesGroupVersionKind {
 				apiVersion := gvk.GroupVersion()
-				if include(apiVersion, gvk.Kind) {
+				if allow(gvk, classes[*gvk]) {
 					logrus.Debugf("adding gvk: %s, %s", apiVersion, gvk.Kind)
+					var rowKey, value string
 					switch groupBy {
 					case ShowResourcesGroupByResource:
-						table.Add(gvk.Kind, kubeVersion.ToString(), apiVersion)
+						rowKey, value = gvk.Kind, apiVersion
 					case ShowResourcesGroupByApiVersion:
-						table.Add(apiVersion, kubeVersion.ToString(), gvk.Kind)
-					default:
-						panic(errors.Errorf("invalid groupBy: %s", groupBy))
+						rowKey, value = apiVersion, gvk.Kind
+					case ShowResourcesGroupByGroup:
+						rowKey, value = gvk.GroupName(), fmt.Sprintf("%s.%s", gvk.Version, gvk.Kind)
+					case ShowResourcesGroupByStability:
+						rowKey, value = gvk.Stability(), gvk.ToString()
+					}
+					if err := table.Add(rowKey, kubeVersion.ToString(), value); err != nil {
+						return nil, err
 					}
 				} else {
 					logrus.Debugf("skipping gvk: %s, %s", apiVersion, gvk.Kind)
@@ -109,13 +269,46 @@ func ShowResources(groupBy ShowResourcesGroupBy, versions []string, include func
 			}
 		}
 	}
+	return table, nil
+}
+
+func validateKubeVersions(versions []string) error {
+	if len(versions) == 0 {
+		return utils.NewUsageError("at least one kube version is required")
+	}
+	if set.FromSlice(versions).Len() != len(versions) {
+		return utils.NewUsageError("kube versions must be unique, found %+v", versions)
+	}
+	return nil
+}
 
+func FormatResourcesTable(table *PivotTable, calculateDiff bool, format ShowResourcesFormat) (string, error) {
+	var raw *RawTable
+	var err error
+	data := table.ToData()
+	if calculateDiff {
+		data.AddDiffs()
+	}
 	switch format {
+	case ShowResourcesFormatJson:
+		bytes, err := json.MarshalWithOptions(data, &json.MarshalOptions{EscapeHTML: false, Indent: true})
+		if err != nil {
+			return "", errors.Wrapf(err, "unable to marshal resources to json")
+		}
+		return string(bytes), nil
+	case ShowResourcesFormatYaml:
+		return marshalYaml(data)
+	case ShowResourcesFormatCsv:
+		out, err := data.ToDelimited(',')
+		return strings.TrimSpace(out), err
+	case ShowResourcesFormatTsv:
+		out, err := data.ToDelimited('\t')
+		return strings.TrimSpace(out), err
 	case ShowResourcesFormatTable:
 		if calculateDiff {
-			return table.ToRawTable(func(rowKey string, values [][]string) []string {
+			raw, err = table.ToRawTable(func(rowKey string, values [][]string) []string {
 				if len(values) == 0 {
-					panic(errors.Errorf("unable to calculate diff for 0 versions"))
+					return []string{rowKey}
 				}
 				prev := values[0]
 				row := []string{rowKey, formatCell(prev)}
@@ -136,17 +329,21 @@ func ShowResources(groupBy ShowResourcesGroupBy, versions []string, include func
 					prev = curr
 				}
 				return row
-			}).ToFormattedTable()
+			})
 		} else {
-			return table.ToRawTable(func(rowKey string, values [][]string) []string {
+			raw, err = table.ToRawTable(func(rowKey string, values [][]string) []string {
 				return slice.Cons(rowKey, slice.Map(formatCell, values))
-			}).ToFormattedTable()
+			})
 		}
+		if err != nil {
+			return "", err
+		}
+		return raw.ToFormattedTable(), nil
 	case ShowResourcesFormatMarkdown:
 		if calculateDiff {
-			return table.ToRawTable(func(rowKey string, values [][]string) []string {
+			raw, err = table.ToRawTable(func(rowKey string, values [][]string) []string {
 				if len(values) == 0 {
-					panic(errors.Errorf("unable to calculate diff for 0 versions"))
+					return []string{rowKey}
 				}
 				prev := values[0]
 				row := []string{rowKey, formatMarkdownList(prev)}
@@ -167,14 +364,157 @@ func ShowResources(groupBy ShowResourcesGroupBy, versions []string, include func
 					prev = curr
 				}
 				return row
-			}).ToMarkdownTable()
+			})
 		} else {
-			return table.ToRawTable(func(rowKey string, values [][]string) []string {
+			raw, err = table.ToRawTable(func(rowKey string, values [][]string) []string {
 				return slice.Cons(rowKey, slice.Map(formatMarkdownList, values))
-			}).ToMarkdownTable()
+			})
+		}
+		if err != nil {
+			return "", err
+		}
+		return raw.ToMarkdownTable(), nil
+	default:
+		return "", errors.Errorf("invalid format: %s", format)
+	}
+}
+
+// ResourceCatalogRow is a resource's catalog entry for a kube version
+type ResourceCatalogRow struct {
+	KubeVersion string `json:"kubeVersion"`
+	*CatalogResource
+}
+
+func ShowResourceCatalog(ctx context.Context, source *SpecSource, versions []string, include ResourceIncluder, format ShowResourcesFormat) (string, error) {
+	rows, err := BuildResourceCatalog(ctx, source, versions, include)
+	if err != nil {
+		return "", err
+	}
+	return FormatResourceCatalog(rows, format)
+}
+
+// BuildResourceCatalog has a row per kube version and resource, sorted by api version and kind
+// within each kube version
+func BuildResourceCatalog(ctx context.Context, source *SpecSource, versions []string, include ResourceIncluder) ([]*ResourceCatalogRow, error) {
+	if err := validateKubeVersions(versions); err != nil {
+		return nil, err
+	}
+	var rows []*ResourceCatalogRow
+	for _, version := range versions {
+		kubeVersion, err := ParseKubeVersion(version)
+		if err != nil {
+			return nil, err
+		}
+		spec, err := source.Read(ctx, kubeVersion)
+		if err != nil {
+			return nil, err
+		}
+		classes, err := spec.ClassifyKinds()
+		if err != nil {
+			return nil, err
+		}
+		catalog, err := spec.ResourceCatalog()
+		if err != nil {
+			return nil, err
+		}
+		index, err := spec.ResourceNameIndex()
+		if err != nil {
+			return nil, err
+		}
+		allow, err := include(index)
+		if err != nil {
+			return nil, err
+		}
+		gvks := slice.SortOn(func(gvk GVK) string { return gvk.ToString() }, maps.Keys(catalog))
+		for _, gvk := range gvks {
+			if allow(&gvk, classes[gvk]) {
+				rows = append(rows, &ResourceCatalogRow{KubeVersion: kubeVersion.ToString(), CatalogResource: catalog[gvk]})
+			}
+		}
+	}
+	return rows, nil
+}
+
+func ShowResourceOperations(ctx context.Context, source *SpecSource, versions []string, include ResourceIncluder, calculateDiff bool, format ShowResourcesFormat) (string, error) {
+	table, err := BuildResourceOperationsTable(ctx, source, versions, include)
+	if err != nil {
+		return "", err
+	}
+	return FormatResourcesTable(table, calculateDiff, format)
+}
+
+// BuildResourceOperationsTable has a row per resource and a column per kube version, with the
+// resource's operations -- see CatalogResource.OperationNames -- as values, so that diffs show
+// when verbs, subresources and patch types come and go
+func BuildResourceOperationsTable(ctx context.Context, source *SpecSource, versions []string, include ResourceIncluder) (*PivotTable, error) {
+	rows, err := BuildResourceCatalog(ctx, source, versions, include)
+	if err != nil {
+		return nil, err
+	}
+	table, err := NewPivotTable("Resource", versions)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		for _, operation := range row.OperationNames() {
+			if err := table.Add(row.GVK.ToString(), row.KubeVersion, operation); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return table, nil
+}
+
+func FormatResourceCatalog(rows []*ResourceCatalogRow, format ShowResourcesFormat) (string, error) {
+	headers := []string{"Kube version", "API version", "Kind", "Plural", "Scope", "Verbs", "Subresources", "Query parameters"}
+	toRawTable := func(formatList func([]string) string) (*RawTable, error) {
+		return NewRawTable(headers, slice.Map(func(row *ResourceCatalogRow) []string {
+			return []string{
+				row.KubeVersion,
+				row.GVK.GroupVersion(),
+				row.GVK.Kind,
+				row.Plural,
+				row.Scope(),
+				formatList(row.Verbs),
+				formatList(row.Subresources),
+				formatList(row.QueryParameters),
+			}
+		}, rows))
+	}
+	switch format {
+	case ShowResourcesFormatJson:
+		bytes, err := json.MarshalWithOptions(rows, &json.MarshalOptions{EscapeHTML: false, Indent: true})
+		if err != nil {
+			return "", errors.Wrapf(err, "unable to marshal resource catalog to json")
+		}
+		return string(bytes), nil
+	case ShowResourcesFormatYaml:
+		return marshalYaml(rows)
+	case ShowResourcesFormatCsv, ShowResourcesFormatTsv:
+		raw, err := toRawTable(func(items []string) string { return strings.Join(items, " ") })
+		if err != nil {
+			return "", err
+		}
+		separator := ','
+		if format == ShowResourcesFormatTsv {
+			separator = '\t'
+		}
+		out, err := raw.ToDelimited(separator)
+		return strings.TrimSpace(out), err
+	case ShowResourcesFormatTable:
+		raw, err := toRawTable(formatCell)
+		if err != nil {
+			return "", err
+		}
+		return raw.ToFormattedTable(), nil
+	case ShowResourcesFormatMarkdown:
+		raw, err := toRawTable(formatMarkdownList)
+		if err != nil {
+			return "", err
 		}
+		return raw.ToMarkdownTable(), nil
 	default:
-		panic(errors.Errorf("invalid format: %s", format))
+		return "", errors.Errorf("invalid format: %s", format)
 	}
 }
 
@@ -0,0 +1,523 @@
+package swagger
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mattfenwick/collections/pkg/json"
+	"github.com/mattfenwick/collections/pkg/slice"
+	"github.com/mattfenwick/collections/pkg/yaml"
+	"github.com/mattfenwick/kubectl-schema/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/exp/maps"
+)
+
+type SampleArgs struct {
+	ApiVersions  []string
+	Resources    []string
+	KubeVersion  string
+	Count        int
+	Seed         int64
+	SeedSet      bool
+	RequiredOnly bool
+	Depth        int
+	MaxItems     int
+	Format       string
+	CRDs         []string
+	// ExcludeApiVersions and ExcludeResources are patterns to leave out, even if they're included
+	ExcludeApiVersions []string
+	ExcludeResources   []string
+}
+
+func RunSample(ctx context.Context, args *SampleArgs) error {
+	if err := validateChoice("format", args.Format, []string{"yaml", "json"}); err != nil {
+		return err
+	}
+	if args.Count <= 0 {
+		return utils.NewUsageError("--count must be positive, found %d", args.Count)
+	}
+	allowApiVersion, err := apiVersionFilter(args.ApiVersions, args.ExcludeApiVersions)
+	if err != nil {
+		return err
+	}
+	resources, err := newResourceFilter(args.Resources, args.ExcludeResources)
+	if err != nil {
+		return err
+	}
+
+	seed := args.Seed
+	if !args.SeedSet {
+		seed = time.Now().UnixNano()
+	}
+	logrus.Infof("generating samples with seed %d", seed)
+	generator := NewSampleGenerator(seed, args.RequiredOnly, args.Depth, args.MaxItems)
+
+	kubeVersion, err := ParseKubeVersion(args.KubeVersion)
+	if err != nil {
+		return err
+	}
+	spec, err := (&SpecSource{CRDPaths: args.CRDs}).Read(ctx, kubeVersion)
+	if err != nil {
+		return err
+	}
+	typesByKindByApiVersion, err := spec.ResolveStructure()
+	if err != nil {
+		return err
+	}
+	allowResource, err := resolveResourceFilter(resources, spec)
+	if err != nil {
+		return err
+	}
+
+	var documents []string
+	for _, resourceName := range slice.Sort(maps.Keys(typesByKindByApiVersion)) {
+		for _, apiVersion := range slice.Sort(maps.Keys(typesByKindByApiVersion[resourceName])) {
+			if !allowApiVersion(apiVersion) || !allowResource(resourceName, spec.DefinitionGVKs(apiVersion, resourceName)) {
+				continue
+			}
+			def, err := spec.GetDefinition(fmt.Sprintf("%s.%s", apiVersion, resourceName))
+			if err != nil {
+				return err
+			}
+			for i := 0; i < args.Count; i++ {
+				obj, err := generator.Generate(def.XKubernetesGroupVersionKind, typesByKindByApiVersion[resourceName][apiVersion])
+				if err != nil {
+					return errors.Wrapf(err, "unable to generate sample for %s.%s", apiVersion, resourceName)
+				}
+				switch args.Format {
+				case "yaml":
+					document, err := yaml.MarshalString(obj)
+					if err != nil {
+						return errors.Wrapf(err, "unable to marshal sample to yaml")
+					}
+					documents = append(documents, document)
+				case "json":
+					document, err := json.MarshalToString(obj)
+					if err != nil {
+						return errors.Wrapf(err, "unable to marshal sample to json")
+					}
+					documents = append(documents, document)
+				}
+			}
+		}
+	}
+	switch args.Format {
+	case "yaml":
+		fmt.Print(strings.Join(documents, "---\n"))
+	default:
+		fmt.Print(strings.Join(documents, ""))
+	}
+	return nil
+}
+
+// SampleGenerator builds random objects which conform to a resolved type.
+// Circular references are cut off with an empty object, as are objects nested more than
+// maxDepth levels deep; a maxDepth of 0 is treated as unlimited.
+type SampleGenerator struct {
+	random       *rand.Rand
+	requiredOnly bool
+	maxDepth     int
+	maxItems     int
+}
+
+func NewSampleGenerator(seed int64, requiredOnly bool, maxDepth int, maxItems int) *SampleGenerator {
+	return &SampleGenerator{
+		random:       rand.New(rand.NewSource(seed)),
+		requiredOnly: requiredOnly,
+		maxDepth:     maxDepth,
+		maxItems:     maxItems,
+	}
+}
+
+// Generate builds a random object.  If the type has a single GVK, `apiVersion` and `kind`
+// are filled in with real values.
+func (g *SampleGenerator) Generate(gvks []*GVK, resolved *ResolvedType) (interface{}, error) {
+	obj, err := g.value(resolved, 0)
+	if err != nil {
+		return nil, err
+	}
+	if fields, ok := obj.(map[string]interface{}); ok && len(gvks) == 1 {
+		fields["apiVersion"] = gvks[0].ApiVersion()
+		fields["kind"] = gvks[0].Kind
+	}
+	return obj, nil
+}
+
+func (g *SampleGenerator) value(resolved *ResolvedType, depth int) (interface{}, error) {
+	if resolved.Circular != "" {
+		logrus.Debugf("cutting off circular reference to %s", resolved.Circular)
+		return map[string]interface{}{}, nil
+	} else if resolved.Constraints != nil && len(resolved.Constraints.Enum) > 0 {
+		return resolved.Constraints.Enum[g.random.Intn(len(resolved.Constraints.Enum))], nil
+	} else if resolved.Primitive != "" {
+		return g.primitive(resolved.Primitive, resolved.Format, resolved.Constraints)
+	} else if resolved.Array != nil {
+		items := []interface{}{}
+		if g.maxDepth == 0 || depth < g.maxDepth {
+			count := clampInt(g.itemCount(), resolved.Constraints.minItems(), resolved.Constraints.maxItems())
+			for i := 0; i < count; i++ {
+				item, err := g.value(resolved.Array, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+			}
+		}
+		return items, nil
+	} else if resolved.Object != nil {
+		fields := map[string]interface{}{}
+		if g.maxDepth != 0 && depth >= g.maxDepth {
+			return fields, nil
+		}
+		for _, field := range slice.Sort(maps.Keys(resolved.Object.Properties)) {
+			if g.requiredOnly && !resolved.Object.IsRequired(field) {
+				continue
+			}
+			fieldValue, err := g.value(resolved.Object.Properties[field], depth+1)
+			if err != nil {
+				return nil, errors.Wrapf(err, "at %s", field)
+			}
+			fields[field] = fieldValue
+		}
+		if resolved.Object.AdditionalProperties != nil {
+			count := 0
+			if !g.requiredOnly {
+				count = g.itemCount()
+			}
+			total := clampInt(len(fields)+count, resolved.Constraints.minProperties(), resolved.Constraints.maxProperties())
+			// give up eventually, in case random keys keep running into fields which are already set
+			for attempts := 0; len(fields) < total && attempts < 10*total; attempts++ {
+				key := g.word()
+				if _, ok := fields[key]; ok {
+					continue
+				}
+				fieldValue, err := g.value(resolved.Object.AdditionalProperties, depth+1)
+				if err != nil {
+					return nil, errors.Wrapf(err, "at %s", key)
+				}
+				fields[key] = fieldValue
+			}
+		}
+		return fields, nil
+	}
+	// empty types, and types without any shape, allow anything
+	return map[string]interface{}{}, nil
+}
+
+func (g *SampleGenerator) itemCount() int {
+	if g.maxItems <= 0 {
+		return 0
+	}
+	return 1 + g.random.Intn(g.maxItems)
+}
+
+func (g *SampleGenerator) word() string {
+	return g.wordOfLength(3, 8)
+}
+
+func (g *SampleGenerator) wordOfLength(minLength int, maxLength int) string {
+	letters := "abcdefghijklmnopqrstuvwxyz"
+	chars := make([]byte, minLength+g.random.Intn(maxLength-minLength+1))
+	for i := range chars {
+		chars[i] = letters[g.random.Intn(len(letters))]
+	}
+	return string(chars)
+}
+
+// primitive builds a value which satisfies the type's format and constraints, as far as possible:
+// constraints which can't be satisfied together are ignored
+func (g *SampleGenerator) primitive(primitive string, format string, constraints *Constraints) (interface{}, error) {
+	switch primitive {
+	case "boolean":
+		return g.random.Intn(2) == 1, nil
+	case "integer":
+		defaultMax := int64(math.MaxInt32)
+		if format == "int32" {
+			defaultMax = math.MaxInt16
+		}
+		return g.integer(constraints, defaultMax), nil
+	case "number":
+		return g.number(constraints), nil
+	case "string":
+		if constraints != nil && constraints.Pattern != "" {
+			if value, ok := g.fromPattern(constraints.Pattern, constraints); ok {
+				return value, nil
+			}
+			logrus.Debugf("unable to generate a string matching %s", constraints.Pattern)
+		}
+		if value, ok := g.formatted(format); ok {
+			return value, nil
+		}
+		minLength, maxLength := 3, 8
+		if constraints != nil && constraints.MinLength != nil {
+			minLength = int(*constraints.MinLength)
+			maxLength = max(maxLength, minLength)
+		}
+		if constraints != nil && constraints.MaxLength != nil {
+			maxLength = int(*constraints.MaxLength)
+			minLength = min(minLength, maxLength)
+		}
+		return g.wordOfLength(minLength, maxLength), nil
+	default:
+		return nil, errors.Errorf("invalid primitive type: %s", primitive)
+	}
+}
+
+func (g *SampleGenerator) formatted(format string) (interface{}, bool) {
+	switch format {
+	case "date-time":
+		return time.Unix(g.random.Int63n(math.MaxInt32), 0).UTC().Format(time.RFC3339), true
+	case "date":
+		return time.Unix(g.random.Int63n(math.MaxInt32), 0).UTC().Format(time.DateOnly), true
+	case "duration":
+		return (time.Duration(1+g.random.Intn(3600)) * time.Second).String(), true
+	case "byte":
+		return base64.StdEncoding.EncodeToString([]byte(g.word())), true
+	case "int-or-string":
+		if g.random.Intn(2) == 1 {
+			return g.random.Int31n(math.MaxInt16), true
+		}
+		return g.word(), true
+	case "uuid":
+		bytes := make([]byte, 16)
+		g.random.Read(bytes)
+		return fmt.Sprintf("%x-%x-%x-%x-%x", bytes[0:4], bytes[4:6], bytes[6:8], bytes[8:10], bytes[10:]), true
+	case "email":
+		return fmt.Sprintf("%s@%s.com", g.word(), g.word()), true
+	case "hostname":
+		return fmt.Sprintf("%s.%s.com", g.word(), g.word()), true
+	case "uri":
+		return fmt.Sprintf("https://%s.com/%s", g.word(), g.word()), true
+	case "ipv4":
+		return fmt.Sprintf("10.%d.%d.%d", g.random.Intn(256), g.random.Intn(256), 1+g.random.Intn(254)), true
+	case "ipv6":
+		return fmt.Sprintf("fd00::%x:%x", g.random.Intn(65536), 1+g.random.Intn(65535)), true
+	case "cidr":
+		return fmt.Sprintf("10.%d.0.0/16", g.random.Intn(256)), true
+	case "mac":
+		bytes := make([]byte, 6)
+		g.random.Read(bytes)
+		return strings.Join(slice.Map(func(b byte) string { return fmt.Sprintf("%02x", b) }, bytes), ":"), true
+	default:
+		return nil, false
+	}
+}
+
+// integer picks a value between minimum and maximum; without them, the value is positive and less than defaultMax.
+// Bounds beyond the range of int64 are clamped to it.
+func (g *SampleGenerator) integer(constraints *Constraints, defaultMax int64) int64 {
+	low, high := int64(0), defaultMax-1
+	if constraints != nil && constraints.Minimum != nil {
+		low = floatToInt64(math.Ceil(*constraints.Minimum))
+		if isTrue(constraints.ExclusiveMinimum) && float64(low) == *constraints.Minimum {
+			low = addInt64(low, 1)
+		}
+		if constraints.Maximum == nil {
+			high = max(high, addInt64(low, defaultMax-1))
+		}
+	}
+	if constraints != nil && constraints.Maximum != nil {
+		high = floatToInt64(math.Floor(*constraints.Maximum))
+		if isTrue(constraints.ExclusiveMaximum) && float64(high) == *constraints.Maximum {
+			high = addInt64(high, -1)
+		}
+		if constraints.Minimum == nil {
+			low = min(low, addInt64(high, -(defaultMax-1)))
+		}
+	}
+	if low > high {
+		return low
+	}
+	if constraints != nil && constraints.MultipleOf != nil && *constraints.MultipleOf > 1 && *constraints.MultipleOf == math.Trunc(*constraints.MultipleOf) &&
+		*constraints.MultipleOf < math.MaxInt64 {
+		step := int64(*constraints.MultipleOf)
+		first, last := ceilDiv(low, step), floorDiv(high, step)
+		if first <= last {
+			return step * g.int64Between(first, last)
+		}
+	}
+	return g.int64Between(low, high)
+}
+
+// int64Between picks a value from low to high, inclusive, even if there are more than math.MaxInt64 of them
+func (g *SampleGenerator) int64Between(low int64, high int64) int64 {
+	// the difference can't overflow as an unsigned number, and adding it back wraps around to the right value
+	span := uint64(high) - uint64(low)
+	if span < math.MaxInt64 {
+		return low + g.random.Int63n(int64(span)+1)
+	}
+	offset := g.random.Uint64()
+	if span < math.MaxUint64 {
+		offset %= span + 1
+	}
+	return low + int64(offset)
+}
+
+// number picks a value with two decimal places between minimum and maximum; without them, the value
+// is between 0 and 1000
+func (g *SampleGenerator) number(constraints *Constraints) float64 {
+	low, high := 0.0, 1000.0
+	if constraints != nil && constraints.Minimum != nil {
+		low = *constraints.Minimum
+		if isTrue(constraints.ExclusiveMinimum) {
+			low += 0.01
+		}
+		if constraints.Maximum == nil {
+			high = math.Max(high, low+1000)
+		}
+	}
+	if constraints != nil && constraints.Maximum != nil {
+		high = *constraints.Maximum
+		if isTrue(constraints.ExclusiveMaximum) {
+			high -= 0.01
+		}
+		if constraints.Minimum == nil {
+			low = math.Min(low, high-1000)
+		}
+	}
+	if constraints != nil && constraints.MultipleOf != nil && *constraints.MultipleOf > 0 {
+		step := *constraints.MultipleOf
+		first, last := math.Ceil(low/step), math.Floor(high/step)
+		if first <= last {
+			return step * (first + float64(g.random.Int63n(int64(last-first)+1)))
+		}
+	}
+	value := math.Round((low+g.random.Float64()*(high-low))*100) / 100
+	return math.Min(math.Max(value, math.Ceil(low*100)/100), math.Floor(high*100)/100)
+}
+
+// fromPattern builds a string matching a regular expression, retrying a few times to satisfy
+// length constraints
+func (g *SampleGenerator) fromPattern(pattern string, constraints *Constraints) (string, bool) {
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", false
+	}
+	for i := 0; i < 10; i++ {
+		var value strings.Builder
+		g.writeRegexp(&value, parsed.Simplify())
+		length := int64(utf8.RuneCountInString(value.String()))
+		if constraints.MinLength != nil && length < *constraints.MinLength {
+			continue
+		} else if constraints.MaxLength != nil && length > *constraints.MaxLength {
+			continue
+		} else if compiled.MatchString(value.String()) {
+			return value.String(), true
+		}
+	}
+	return "", false
+}
+
+func (g *SampleGenerator) writeRegexp(out *strings.Builder, re *syntax.Regexp) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		out.WriteString(string(re.Rune))
+	case syntax.OpCharClass:
+		out.WriteRune(g.charClassRune(re.Rune))
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		out.WriteString(g.wordOfLength(1, 1))
+	case syntax.OpCapture:
+		g.writeRegexp(out, re.Sub[0])
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		low, high := re.Min, re.Max
+		switch re.Op {
+		case syntax.OpStar:
+			low, high = 0, 3
+		case syntax.OpPlus:
+			low, high = 1, 3
+		case syntax.OpQuest:
+			low, high = 0, 1
+		}
+		if high < 0 {
+			high = low + 3
+		}
+		for i := low + g.random.Intn(high-low+1); i > 0; i-- {
+			g.writeRegexp(out, re.Sub[0])
+		}
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			g.writeRegexp(out, sub)
+		}
+	case syntax.OpAlternate:
+		g.writeRegexp(out, re.Sub[g.random.Intn(len(re.Sub))])
+	default:
+		// anchors, word boundaries and empty matches don't contribute any characters
+	}
+}
+
+// charClassRune prefers printable ascii, since classes such as [^/] cover all of unicode
+func (g *SampleGenerator) charClassRune(ranges []rune) rune {
+	var printable []rune
+	for i := 0; i+1 < len(ranges); i += 2 {
+		for r := max(ranges[i], ' '); r <= min(ranges[i+1], '~'); r++ {
+			printable = append(printable, r)
+		}
+	}
+	if len(printable) > 0 {
+		return printable[g.random.Intn(len(printable))]
+	}
+	if len(ranges) == 0 {
+		return 'x'
+	}
+	return ranges[2*g.random.Intn(len(ranges)/2)]
+}
+
+func clampInt(value int, low *int64, high *int64) int {
+	if low != nil && value < int(*low) {
+		value = int(*low)
+	}
+	if high != nil && value > int(*high) {
+		value = int(*high)
+	}
+	return value
+}
+
+// addInt64 adds, sticking at math.MaxInt64 or math.MinInt64 instead of overflowing
+func addInt64(a int64, b int64) int64 {
+	sum := a + b
+	if b > 0 && sum < a {
+		return math.MaxInt64
+	} else if b < 0 && sum > a {
+		return math.MinInt64
+	}
+	return sum
+}
+
+// floatToInt64 converts a whole number, clamping it to the range of int64
+func floatToInt64(f float64) int64 {
+	if f >= math.MaxInt64 {
+		return math.MaxInt64
+	} else if f <= math.MinInt64 {
+		return math.MinInt64
+	}
+	return int64(f)
+}
+
+func ceilDiv(a int64, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) == (b < 0) {
+		q++
+	}
+	return q
+}
+
+func floorDiv(a int64, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,214 @@
+package swagger
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mattfenwick/kubectl-schema/pkg/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func RunSampleTests() {
+	float := func(f float64) *float64 { return &f }
+	integer := func(i int64) *int64 { return &i }
+	boolean := func(b bool) *bool { return &b }
+	spec := &KubeSpec{Definitions: map[string]*SpecType{
+		"com.example.v1.Widget": {
+			Type:     "object",
+			Required: []string{"name", "port"},
+			Properties: map[string]*SpecType{
+				"name":     {Type: "string", Pattern: `^[a-z]([-a-z0-9]*[a-z0-9])?$`, MaxLength: integer(10)},
+				"port":     {Type: "integer", Format: "int32", Minimum: float(1), Maximum: float(65535)},
+				"replicas": {Type: "integer", Minimum: float(0), ExclusiveMinimum: boolean(true), Maximum: float(5)},
+				"weight":   {Type: "integer", Minimum: float(100000), MultipleOf: float(7)},
+				"ratio":    {Type: "number", Minimum: float(0.5), Maximum: float(1), ExclusiveMaximum: boolean(true)},
+				"id":       {Type: "string", Format: "uuid"},
+				"created":  {Type: "string", Format: "date-time"},
+				"code":     {Type: "string", MinLength: integer(12), MaxLength: integer(12)},
+				"mode":     {Type: "string", Enum: []interface{}{"fast", "slow"}},
+				"tags":     {Type: "array", MinItems: integer(3), MaxItems: integer(4), Items: &SpecType{Type: "string", Pattern: `^v\d+\.\d+$`}},
+			},
+			XKubernetesGroupVersionKind: []*GVK{{Group: "example.com", Version: "v1", Kind: "Widget"}},
+		},
+	}}
+	definitions, err := spec.ResolveDefinitions()
+	if err != nil {
+		panic(err)
+	}
+	widget := definitions["com.example.v1.Widget"]
+	gvks := spec.Definitions["com.example.v1.Widget"].XKubernetesGroupVersionKind
+
+	comparator := func(exclusive bool, strict string) string {
+		if exclusive {
+			return strict
+		}
+		return strict + "="
+	}
+	uuidRegex := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	var expectConforms func(path string, value interface{}, resolved *ResolvedType)
+	expectConforms = func(path string, value interface{}, resolved *ResolvedType) {
+		c := resolved.Constraints
+		if c != nil && len(c.Enum) > 0 {
+			Expect(c.Enum).To(ContainElement(value), path)
+			return
+		}
+		switch {
+		case resolved.Primitive == "integer" || resolved.Primitive == "number":
+			var number float64
+			switch v := value.(type) {
+			case int64:
+				number = float64(v)
+			case float64:
+				number = v
+			default:
+				Fail(path + ": expected a number")
+			}
+			if resolved.Primitive == "integer" {
+				Expect(number).To(Equal(math.Trunc(number)), path)
+			}
+			if c != nil && c.Minimum != nil {
+				Expect(number).To(BeNumerically(comparator(isTrue(c.ExclusiveMinimum), ">"), *c.Minimum), path)
+			}
+			if c != nil && c.Maximum != nil {
+				Expect(number).To(BeNumerically(comparator(isTrue(c.ExclusiveMaximum), "<"), *c.Maximum), path)
+			}
+			if c != nil && c.MultipleOf != nil {
+				Expect(math.Mod(number, *c.MultipleOf)).To(BeZero(), path)
+			}
+		case resolved.Primitive == "string":
+			str, ok := value.(string)
+			Expect(ok).To(BeTrue(), path)
+			if c != nil && c.Pattern != "" {
+				Expect(str).To(MatchRegexp(c.Pattern), path)
+			}
+			if c != nil && c.MinLength != nil {
+				Expect(utf8.RuneCountInString(str)).To(BeNumerically(">=", *c.MinLength), path)
+			}
+			if c != nil && c.MaxLength != nil {
+				Expect(utf8.RuneCountInString(str)).To(BeNumerically("<=", *c.MaxLength), path)
+			}
+			switch resolved.Format {
+			case "uuid":
+				Expect(str).To(MatchRegexp(uuidRegex.String()), path)
+			case "date-time":
+				_, err := time.Parse(time.RFC3339, str)
+				Expect(err).To(Succeed(), path)
+			}
+		case resolved.Array != nil:
+			items, ok := value.([]interface{})
+			Expect(ok).To(BeTrue(), path)
+			if c != nil && c.MinItems != nil {
+				Expect(len(items)).To(BeNumerically(">=", *c.MinItems), path)
+			}
+			if c != nil && c.MaxItems != nil {
+				Expect(len(items)).To(BeNumerically("<=", *c.MaxItems), path)
+			}
+			for _, item := range items {
+				expectConforms(path+".[]", item, resolved.Array)
+			}
+		case resolved.Object != nil:
+			fields, ok := value.(map[string]interface{})
+			Expect(ok).To(BeTrue(), path)
+			for _, field := range resolved.Object.Required {
+				Expect(fields).To(HaveKey(field), path)
+			}
+			for field, prop := range resolved.Object.Properties {
+				if fieldValue, ok := fields[field]; ok {
+					expectConforms(path+"."+field, fieldValue, prop)
+				}
+			}
+		}
+	}
+
+	Describe("Sample", func() {
+		It("generates the same objects from the same seed, including 0", func() {
+			for _, seed := range []int64{0, 42} {
+				first, err := NewSampleGenerator(seed, false, 0, 2).Generate(gvks, widget)
+				Expect(err).To(Succeed())
+				Expect(NewSampleGenerator(seed, false, 0, 2).Generate(gvks, widget)).To(Equal(first))
+			}
+			first, err := NewSampleGenerator(0, false, 0, 2).Generate(gvks, widget)
+			Expect(err).To(Succeed())
+			Expect(NewSampleGenerator(42, false, 0, 2).Generate(gvks, widget)).NotTo(Equal(first))
+		})
+
+		It("generates objects which conform to the schema", func() {
+			for seed := int64(0); seed < 100; seed++ {
+				obj, err := NewSampleGenerator(seed, false, 0, 2).Generate(gvks, widget)
+				Expect(err).To(Succeed())
+				expectConforms("Widget", obj, widget)
+				Expect(obj).To(HaveKeyWithValue("kind", "Widget"))
+				Expect(obj).To(HaveKeyWithValue("apiVersion", "example.com/v1"))
+			}
+		})
+
+		It("only fills in required fields", func() {
+			obj, err := NewSampleGenerator(1, true, 0, 2).Generate(gvks, widget)
+			Expect(err).To(Succeed())
+			Expect(obj).To(HaveLen(4))
+			Expect(obj).To(HaveKey("name"))
+			Expect(obj).To(HaveKey("port"))
+		})
+
+		It("fills in types without a shape, and returns an error for unknown primitives", func() {
+			Expect(NewSampleGenerator(1, false, 0, 2).Generate(nil, &ResolvedType{})).To(Equal(map[string]interface{}{}))
+			_, err := NewSampleGenerator(1, false, 0, 2).Generate(nil, &ResolvedType{Object: &ResolvedObject{
+				Properties: map[string]*ResolvedType{"size": {Primitive: "float"}},
+			}})
+			Expect(err).To(MatchError("at size: invalid primitive type: float"))
+		})
+
+		It("picks integers within bounds at the edges of int64, without overflowing", func() {
+			generator := NewSampleGenerator(1, false, 0, 2)
+			for _, c := range []*Constraints{
+				{Minimum: float(-1e30), Maximum: float(1e30)},
+				{Minimum: float(math.MaxInt64)},
+				{Minimum: float(math.MaxInt64), ExclusiveMinimum: boolean(true)},
+				{Maximum: float(math.MinInt64), ExclusiveMaximum: boolean(true)},
+				{Minimum: float(math.MinInt64), Maximum: float(math.MaxInt64), MultipleOf: float(3)},
+				{Minimum: float(math.MinInt64), Maximum: float(math.MinInt64 + 10), MultipleOf: float(4)},
+			} {
+				for i := 0; i < 20; i++ {
+					value := generator.integer(c, math.MaxInt32)
+					if c.Minimum != nil {
+						Expect(float64(value)).To(BeNumerically(">=", math.Max(*c.Minimum, math.MinInt64)))
+					}
+					if c.Maximum != nil {
+						Expect(float64(value)).To(BeNumerically("<=", math.Min(*c.Maximum, math.MaxInt64)))
+					}
+					if c.MultipleOf != nil {
+						Expect(value % int64(*c.MultipleOf)).To(BeZero())
+					}
+				}
+			}
+		})
+
+		It("fills in maps with between minProperties and maxProperties entries", func() {
+			labels := func(minProperties int64, maxProperties int64) *ResolvedType {
+				return &ResolvedType{
+					Object:      &ResolvedObject{AdditionalProperties: &ResolvedType{Primitive: "string"}},
+					Constraints: &Constraints{MinProperties: integer(minProperties), MaxProperties: integer(maxProperties)},
+				}
+			}
+			size := func(generator *SampleGenerator, resolved *ResolvedType) int {
+				obj, err := generator.Generate(nil, resolved)
+				Expect(err).To(Succeed())
+				return len(obj.(map[string]interface{}))
+			}
+			for seed := int64(0); seed < 20; seed++ {
+				Expect(size(NewSampleGenerator(seed, false, 0, 2), labels(4, 6))).To(BeNumerically("~", 5, 1))
+				Expect(size(NewSampleGenerator(seed, false, 0, 10), labels(0, 1))).To(BeNumerically("<=", 1))
+				Expect(size(NewSampleGenerator(seed, true, 0, 2), labels(3, 5))).To(Equal(3))
+			}
+		})
+
+		It("requires a positive count", func() {
+			err := RunSample(context.Background(), &SampleArgs{Count: 0, Format: "yaml"})
+			Expect(utils.ExitCode(err)).To(Equal(utils.ExitCodeUsage))
+		})
+	})
+}
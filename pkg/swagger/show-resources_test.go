@@ -1,7 +1,9 @@
 package swagger
 
 import (
+	"context"
 	"fmt"
+	"github.com/mattfenwick/collections/pkg/json"
 	"github.com/mattfenwick/collections/pkg/set"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -10,31 +12,122 @@ import (
 func RunShowResourcesTests() {
 	versions := []string{"1.18.20", "1.20.15", "1.22.12", "1.24.0", "1.25.0-alpha.3"}
 	resources := set.FromSlice([]string{"Ingress", "CronJob", "CustomResourceDefinition"})
-	include := func(apiVersion string, resource string) bool {
-		return resources.Contains(resource)
+	allow := func(gvk *GVK, class KindClass) bool {
+		return resources.Contains(gvk.Kind)
+	}
+	include := func(index *ResourceNameIndex) (func(*GVK, KindClass) bool, error) {
+		return allow, nil
 	}
 
 	Describe("Show resource", func() {
 		It("By resource -- no diff", func() {
-			actual := ShowResources(ShowResourcesGroupByResource, versions, include, false, ShowResourcesFormatTable)
+			actual, err := ShowResources(context.Background(), &SpecSource{}, ShowResourcesGroupByResource, versions, include, false, ShowResourcesFormatTable)
+			Expect(err).To(Succeed())
 			Expect(actual).To(Equal(byResourceNoDiff[1:]))
 		})
 		It("By apiversion -- no diff", func() {
-			actual := ShowResources(ShowResourcesGroupByApiVersion, versions, include, false, ShowResourcesFormatTable)
+			actual, err := ShowResources(context.Background(), &SpecSource{}, ShowResourcesGroupByApiVersion, versions, include, false, ShowResourcesFormatTable)
+			Expect(err).To(Succeed())
 			Expect(actual).To(Equal(byApiVersionNoDiff[1:]))
 		})
 		It("By resource -- diff", func() {
-			actual := ShowResources(ShowResourcesGroupByResource, versions, include, true, ShowResourcesFormatTable)
+			actual, err := ShowResources(context.Background(), &SpecSource{}, ShowResourcesGroupByResource, versions, include, true, ShowResourcesFormatTable)
+			Expect(err).To(Succeed())
 			fmt.Printf("expect:\n%s\n", byResourceWithDiff[1:])
 			fmt.Printf("actual:\n%s\n", actual)
 			Expect(actual).To(Equal(byResourceWithDiff[1:]))
 		})
 		It("By apiversion -- diff", func() {
-			actual := ShowResources(ShowResourcesGroupByApiVersion, versions, include, true, ShowResourcesFormatTable)
+			actual, err := ShowResources(context.Background(), &SpecSource{}, ShowResourcesGroupByApiVersion, versions, include, true, ShowResourcesFormatTable)
+			Expect(err).To(Succeed())
 			fmt.Printf("actual vs. expected:\n%s\n\n%s\n\n", actual, byApiVersionWithDiff)
 			Expect(actual).To(Equal(byApiVersionWithDiff[1:]))
 		})
 	})
+
+	Describe("Resources filters", func() {
+		It("derives stability from the version", func() {
+			Expect((&GVK{Version: "v1"}).Stability()).To(Equal(StabilityGA))
+			Expect((&GVK{Version: "v2beta3"}).Stability()).To(Equal(StabilityBeta))
+			Expect((&GVK{Version: "v1alpha1"}).Stability()).To(Equal(StabilityAlpha))
+			Expect((&GVK{Version: "v1test"}).Stability()).To(Equal(StabilityUnknown))
+		})
+
+		It("filters by group and stability", func() {
+			includer, err := (&ShowResourcesArgs{Groups: []string{"core", "*.k8s.io"}, Stabilities: []string{"beta"}}).GetInclude()
+			Expect(err).To(Succeed())
+			include, err := includer(nil)
+			Expect(err).To(Succeed())
+			Expect(include(&GVK{Group: "", Version: "v1beta1", Kind: "Event"}, KindClassResource)).To(BeTrue())
+			Expect(include(&GVK{Group: "networking.k8s.io", Version: "v1beta1", Kind: "Ingress"}, KindClassResource)).To(BeTrue())
+			Expect(include(&GVK{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"}, KindClassResource)).To(BeFalse())
+			Expect(include(&GVK{Group: "batch", Version: "v1beta1", Kind: "CronJob"}, KindClassResource)).To(BeFalse())
+
+			_, err = (&ShowResourcesArgs{Stabilities: []string{"stable"}}).GetInclude()
+			Expect(err).To(MatchError(ContainSubstring("invalid --stability 'stable'")))
+		})
+	})
+
+	Describe("Resources formats", func() {
+		table, err := NewPivotTable("Resource", []string{"1.20.15", "1.22.12"})
+		if err != nil {
+			panic(err)
+		}
+		for _, cell := range [][]string{
+			{"Ingress", "1.20.15", "networking.k8s.io.v1beta1"},
+			{"Ingress", "1.20.15", "extensions.v1beta1"},
+			{"Ingress", "1.22.12", "networking.k8s.io.v1"},
+			{"PodSecurityPolicy", "1.20.15", "policy.v1beta1"},
+		} {
+			if err := table.Add(cell[0], cell[1], cell[2]); err != nil {
+				panic(err)
+			}
+		}
+
+		It("keeps values as lists in json, with added and removed sets in diff mode", func() {
+			actual, err := FormatResourcesTable(table, true, ShowResourcesFormatJson)
+			Expect(err).To(Succeed())
+			data, err := json.ParseString[PivotTableData](actual)
+			Expect(err).To(Succeed())
+			Expect(data.Columns).To(Equal([]string{"1.20.15", "1.22.12"}))
+			Expect(data.Rows).To(HaveLen(2))
+			Expect(data.Rows[0].Key).To(Equal("Ingress"))
+			Expect(data.Rows[0].Cells[0]).To(Equal(&PivotTableCell{Column: "1.20.15", Values: []string{"extensions.v1beta1", "networking.k8s.io.v1beta1"}}))
+			Expect(data.Rows[0].Cells[1]).To(Equal(&PivotTableCell{
+				Column: "1.22.12",
+				Values: []string{"networking.k8s.io.v1"},
+				Diff: &PivotTableCellDiff{
+					Added:   []string{"networking.k8s.io.v1"},
+					Removed: []string{"extensions.v1beta1", "networking.k8s.io.v1beta1"},
+				},
+			}))
+			Expect(data.Rows[1].Cells[1]).To(Equal(&PivotTableCell{Column: "1.22.12", Values: []string{}, Diff: &PivotTableCellDiff{Added: []string{}, Removed: []string{"policy.v1beta1"}}}))
+		})
+
+		It("writes csv and tsv", func() {
+			actual, err := FormatResourcesTable(table, false, ShowResourcesFormatCsv)
+			Expect(err).To(Succeed())
+			Expect(actual).To(Equal(`Resource,1.20.15,1.22.12
+Ingress,extensions.v1beta1 networking.k8s.io.v1beta1,networking.k8s.io.v1
+PodSecurityPolicy,policy.v1beta1,`))
+
+			actual, err = FormatResourcesTable(table, true, ShowResourcesFormatTsv)
+			Expect(err).To(Succeed())
+			Expect(actual).To(Equal("Resource\t1.20.15\t1.22.12\n" +
+				"Ingress\textensions.v1beta1 networking.k8s.io.v1beta1\t+networking.k8s.io.v1 -extensions.v1beta1 -networking.k8s.io.v1beta1\n" +
+				"PodSecurityPolicy\tpolicy.v1beta1\t-policy.v1beta1"))
+		})
+
+		It("returns errors for duplicate and unknown columns, and for invalid groupings", func() {
+			_, err := NewPivotTable("Resource", []string{"1.20.15", "1.20.15"})
+			Expect(err).To(MatchError(ContainSubstring("expected unique columns")))
+			Expect(table.Add("Ingress", "1.21.14", "networking.k8s.io.v1")).To(MatchError(ContainSubstring("invalid column name 1.21.14")))
+			_, err = NewRawTable([]string{"Resource", "1.20.15"}, [][]string{{"Ingress"}})
+			Expect(err).To(MatchError(ContainSubstring("mismatch between length of headers and of row 0")))
+			_, err = BuildResourcesTable(context.Background(), &SpecSource{}, ShowResourcesGroupBy("kind"), []string{"1.20.15"}, nil)
+			Expect(err).To(MatchError("invalid groupBy: kind"))
+		})
+	})
 }
 
 var (
@@ -0,0 +1,230 @@
+package swagger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mattfenwick/collections/pkg/slice"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/maps"
+	"gopkg.in/yaml.v3"
+)
+
+type SkeletonArgs struct {
+	ApiVersions  []string
+	Resources    []string
+	KubeVersion  string
+	Depth        int
+	Paths        []string
+	RequiredOnly bool
+	CRDs         []string
+	// ExcludeApiVersions, ExcludeResources and ExcludePaths are patterns to leave out, even if they're included
+	ExcludeApiVersions []string
+	ExcludeResources   []string
+	ExcludePaths       []string
+}
+
+func RunSkeleton(ctx context.Context, args *SkeletonArgs) error {
+	allowApiVersion, err := apiVersionFilter(args.ApiVersions, args.ExcludeApiVersions)
+	if err != nil {
+		return err
+	}
+	resources, err := newResourceFilter(args.Resources, args.ExcludeResources)
+	if err != nil {
+		return err
+	}
+	builder, err := newSkeletonBuilder(args.Paths, args.ExcludePaths, args.Depth, args.RequiredOnly)
+	if err != nil {
+		return err
+	}
+
+	kubeVersion, err := ParseKubeVersion(args.KubeVersion)
+	if err != nil {
+		return err
+	}
+	spec, err := (&SpecSource{CRDPaths: args.CRDs}).Read(ctx, kubeVersion)
+	if err != nil {
+		return err
+	}
+	typesByKindByApiVersion, err := spec.ResolveStructure()
+	if err != nil {
+		return err
+	}
+	allowResource, err := resolveResourceFilter(resources, spec)
+	if err != nil {
+		return err
+	}
+
+	var documents []string
+	for _, resourceName := range slice.Sort(maps.Keys(typesByKindByApiVersion)) {
+		for _, apiVersion := range slice.Sort(maps.Keys(typesByKindByApiVersion[resourceName])) {
+			if !allowApiVersion(apiVersion) || !allowResource(resourceName, spec.DefinitionGVKs(apiVersion, resourceName)) {
+				continue
+			}
+			def, err := spec.GetDefinition(fmt.Sprintf("%s.%s", apiVersion, resourceName))
+			if err != nil {
+				return err
+			}
+			document, err := builder.Build(def.XKubernetesGroupVersionKind, typesByKindByApiVersion[resourceName][apiVersion])
+			if err != nil {
+				return err
+			}
+			documents = append(documents, document)
+		}
+	}
+	fmt.Print(strings.Join(documents, "---\n"))
+	return nil
+}
+
+type skeletonBuilder struct {
+	paths        *pathFilter
+	requiredOnly bool
+}
+
+func newSkeletonBuilder(paths []string, excludePaths []string, depth int, requiredOnly bool) (*skeletonBuilder, error) {
+	filter, err := newPathFilter(paths, excludePaths, depth)
+	if err != nil {
+		return nil, err
+	}
+	return &skeletonBuilder{paths: filter, requiredOnly: requiredOnly}, nil
+}
+
+// Build renders a YAML document with a placeholder for every allowed field, and descriptions as comments.
+// If the type has a single GVK, `apiVersion` and `kind` are filled in with real values.
+func (b *skeletonBuilder) Build(gvks []*GVK, resolved *ResolvedType) (string, error) {
+	root := b.node(resolved, []string{})
+	if len(gvks) == 1 && root.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			switch root.Content[i].Value {
+			case "apiVersion":
+				root.Content[i+1].Value = gvks[0].ApiVersion()
+			case "kind":
+				root.Content[i+1].Value = gvks[0].Kind
+			}
+		}
+	}
+	document := &yaml.Node{
+		Kind:        yaml.DocumentNode,
+		HeadComment: wrapText(resolved.Description, 100),
+		Content:     []*yaml.Node{root},
+	}
+
+	out := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(document); err != nil {
+		return "", errors.Wrapf(err, "unable to encode skeleton yaml")
+	}
+	if err := encoder.Close(); err != nil {
+		return "", errors.Wrapf(err, "unable to close skeleton yaml encoder")
+	}
+	return out.String(), nil
+}
+
+// child builds the node for a path, if it's included.  Ancestors of selected paths are only kept if
+// something beneath them was.
+func (b *skeletonBuilder) child(resolved *ResolvedType, path []string) (*yaml.Node, bool) {
+	if b.paths.Allow(path) {
+		return b.node(resolved, path), true
+	}
+	if !b.paths.IsAncestor(path) {
+		return nil, false
+	}
+	node := b.node(resolved, path)
+	return node, node.Kind != yaml.ScalarNode && len(node.Content) > 0
+}
+
+func (b *skeletonBuilder) includeField(obj *ResolvedObject, path []string, field string) bool {
+	isRootTypeMeta := len(path) == 0 && (field == "apiVersion" || field == "kind")
+	return !b.requiredOnly || obj.IsRequired(field) || isRootTypeMeta
+}
+
+func (b *skeletonBuilder) node(resolved *ResolvedType, path []string) *yaml.Node {
+	if resolved.Circular != "" {
+		node := emptyYamlMapping()
+		node.LineComment = fmt.Sprintf("circular reference to %s", resolved.Circular)
+		return node
+	} else if resolved.Primitive != "" {
+		return skeletonPlaceholder(resolved.Primitive, resolved.Format)
+	} else if resolved.Array != nil {
+		itemPath := slice.Append(path, []string{"[]"})
+		item, ok := b.child(resolved.Array, itemPath)
+		if !ok {
+			return &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		}
+		return &yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{item}}
+	} else if resolved.Object != nil {
+		node := emptyYamlMapping()
+		for _, field := range slice.Sort(maps.Keys(resolved.Object.Properties)) {
+			if !b.includeField(resolved.Object, path, field) {
+				continue
+			}
+			prop := resolved.Object.Properties[field]
+			child, ok := b.child(prop, slice.Append(path, []string{field}))
+			if !ok {
+				continue
+			}
+			node.Content = append(node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: field, HeadComment: wrapText(prop.Description, 100)},
+				child)
+		}
+		// map keys are never required, so there's nothing to show in required-only mode
+		additionalPath := slice.Append(path, []string{"additionalProperties"})
+		if resolved.Object.AdditionalProperties != nil && !b.requiredOnly {
+			if child, ok := b.child(resolved.Object.AdditionalProperties, additionalPath); ok {
+				node.Content = append(node.Content,
+					&yaml.Node{Kind: yaml.ScalarNode, Value: "key", HeadComment: wrapText(resolved.Object.AdditionalProperties.Description, 100)},
+					child)
+			}
+		}
+		if len(node.Content) > 0 {
+			node.Style = 0
+		}
+		return node
+	}
+	// empty types, and types without any shape, allow anything
+	return emptyYamlMapping()
+}
+
+func emptyYamlMapping() *yaml.Node {
+	return &yaml.Node{Kind: yaml.MappingNode, Style: yaml.FlowStyle}
+}
+
+func skeletonPlaceholder(primitive string, format string) *yaml.Node {
+	var node *yaml.Node
+	switch primitive {
+	case "boolean":
+		node = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "false"}
+	case "integer":
+		node = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: "0"}
+	case "number":
+		node = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: "0.0"}
+	default:
+		node = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: ""}
+	}
+	node.LineComment = format
+	return node
+}
+
+// wrapText breaks lines on spaces so that they're no longer than width, where possible
+func wrapText(text string, width int) string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			if line != "" && len(line)+1+len(word) > width {
+				lines = append(lines, line)
+				line = ""
+			}
+			if line == "" {
+				line = word
+			} else {
+				line += " " + word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,96 @@
+package swagger
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func RunSkeletonTests() {
+	spec := &KubeSpec{Definitions: map[string]*SpecType{
+		"io.k8s.api.apps.v1.Deployment": {
+			Description: "Deployment enables declarative updates.",
+			Type:        "object",
+			Properties: map[string]*SpecType{
+				"apiVersion": {Type: "string"},
+				"kind":       {Type: "string"},
+				"spec":       {Ref: "#/definitions/io.k8s.api.apps.v1.DeploymentSpec", Description: "Desired behavior."},
+			},
+			XKubernetesGroupVersionKind: []*GVK{{Group: "apps", Version: "v1", Kind: "Deployment"}},
+		},
+		"io.k8s.api.apps.v1.DeploymentSpec": {
+			Description: "DeploymentSpec is the specification of the desired behavior of the Deployment.",
+			Type:        "object",
+			Required:    []string{"selector"},
+			Properties: map[string]*SpecType{
+				"paused":   {Type: "boolean"},
+				"replicas": {Type: "integer", Format: "int32", Description: "Number of desired pods."},
+				"selector": {Type: "object", AdditionalProperties: &SpecType{Type: "string"}},
+				"args":     {Type: "array", Items: &SpecType{Type: "string"}},
+			},
+		},
+	}}
+	structure, err := spec.ResolveStructure()
+	if err != nil {
+		panic(err)
+	}
+	deployment := structure["Deployment"]["io.k8s.api.apps.v1"]
+	gvks := spec.Definitions["io.k8s.api.apps.v1.Deployment"].XKubernetesGroupVersionKind
+
+	Describe("Skeleton", func() {
+		It("includes all fields", func() {
+			builder, err := newSkeletonBuilder(nil, nil, 0, false)
+			Expect(err).To(Succeed())
+			actual, err := builder.Build(gvks, deployment)
+			Expect(err).To(Succeed())
+			Expect(actual).To(Equal(skeletonAllFields[1:]))
+		})
+		It("includes required fields", func() {
+			builder, err := newSkeletonBuilder(nil, nil, 0, true)
+			Expect(err).To(Succeed())
+			actual, err := builder.Build(gvks, deployment)
+			Expect(err).To(Succeed())
+			Expect(actual).To(Equal(skeletonRequiredOnly[1:]))
+		})
+		It("includes paths and their ancestors", func() {
+			builder, err := newSkeletonBuilder([]string{"spec.replicas"}, nil, 0, false)
+			Expect(err).To(Succeed())
+			actual, err := builder.Build(gvks, deployment)
+			Expect(err).To(Succeed())
+			Expect(actual).To(Equal(skeletonPath[1:]))
+		})
+	})
+}
+
+var (
+	skeletonAllFields = `
+# Deployment enables declarative updates.
+
+apiVersion: apps/v1
+kind: Deployment
+# Desired behavior.
+spec:
+  args:
+    - ""
+  paused: false
+  # Number of desired pods.
+  replicas: 0 # int32
+  selector:
+    key: ""
+`
+
+	skeletonRequiredOnly = `
+# Deployment enables declarative updates.
+
+apiVersion: apps/v1
+kind: Deployment
+`
+
+	skeletonPath = `
+# Deployment enables declarative updates.
+
+# Desired behavior.
+spec:
+  # Number of desired pods.
+  replicas: 0 # int32
+`
+)
@@ -0,0 +1,193 @@
+package swagger
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/mattfenwick/collections/pkg/base"
+	"github.com/mattfenwick/collections/pkg/json"
+	"github.com/mattfenwick/collections/pkg/set"
+	"github.com/mattfenwick/collections/pkg/slice"
+	"github.com/mattfenwick/kubectl-schema/pkg/diff"
+	"github.com/mattfenwick/kubectl-schema/pkg/utils"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/maps"
+)
+
+type SpecAuditArgs struct {
+	KubeVersions []string
+	MaxPaths     int
+}
+
+func RunSpecAudit(ctx context.Context, args *SpecAuditArgs) error {
+	versions, err := ParseKubeVersions(args.KubeVersions)
+	if err != nil {
+		return err
+	}
+	problems := false
+	for i, version := range versions {
+		bytes, err := (&SpecSource{}).ReadBytes(ctx, version)
+		if err != nil {
+			return err
+		}
+
+		audit, err := AuditSpec(bytes)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("kube version %s:\n%s\n", args.KubeVersions[i], audit.Format(args.MaxPaths))
+		problems = problems || !audit.Ok()
+	}
+	if problems {
+		return utils.ErrDifferencesFound
+	}
+	return nil
+}
+
+// SpecAudit describes what's lost when a spec is parsed into a KubeSpec: keys which aren't modeled,
+// keyed by name with the paths they were found at, and differences after a round trip through
+// KubeSpec for keys which are modeled.
+type SpecAudit struct {
+	UnknownKeys          map[string][][]string
+	RoundTripDifferences []*diff.Node
+}
+
+func (a *SpecAudit) Ok() bool {
+	return len(a.UnknownKeys) == 0 && len(a.RoundTripDifferences) == 0
+}
+
+// Format lists unknown keys sorted by name, showing at most `maxPaths` paths for each; 0 means all paths
+func (a *SpecAudit) Format(maxPaths int) string {
+	lines := []string{fmt.Sprintf("  %d unmodeled keys", len(a.UnknownKeys))}
+	for _, key := range slice.Sort(maps.Keys(a.UnknownKeys)) {
+		paths := a.UnknownKeys[key]
+		lines = append(lines, fmt.Sprintf("    %-50s %d", key, len(paths)))
+		for i, path := range paths {
+			if maxPaths > 0 && i >= maxPaths {
+				lines = append(lines, fmt.Sprintf("      ... and %d more", len(paths)-maxPaths))
+				break
+			}
+			lines = append(lines, "      "+strings.Join(path, "/"))
+		}
+	}
+	lines = append(lines, fmt.Sprintf("  %d round trip differences", len(a.RoundTripDifferences)))
+	for i, node := range a.RoundTripDifferences {
+		if maxPaths > 0 && i >= maxPaths {
+			lines = append(lines, fmt.Sprintf("      ... and %d more", len(a.RoundTripDifferences)-maxPaths))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("    %-4s %s", node.Kind.Short(), strings.Join(node.Path, "/")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// AuditSpec parses a spec both generically and into a KubeSpec, to find out what KubeSpec drops
+func AuditSpec(bytes []byte) (*SpecAudit, error) {
+	raw, err := json.Parse[map[string]interface{}](bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse spec")
+	}
+	spec, err := json.Parse[KubeSpec](bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse spec into KubeSpec")
+	}
+
+	audit := &SpecAudit{UnknownKeys: map[string][][]string{}}
+	modeled := audit.findUnknownKeys(*raw)
+	for key, paths := range audit.UnknownKeys {
+		audit.UnknownKeys[key] = slice.SortBy(slice.ComparePairwiseBy(base.CompareOrdered[string]), paths)
+	}
+
+	roundTripBytes, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	roundTrip, err := json.Parse[map[string]interface{}](roundTripBytes)
+	if err != nil {
+		return nil, err
+	}
+	differences, err := diff.CompareJson(modeled, *roundTrip)
+	if err != nil {
+		return nil, err
+	}
+	audit.RoundTripDifferences = differences.Changes
+	return audit, nil
+}
+
+var (
+	kubeSpecKeys = jsonFieldNames(reflect.TypeOf(KubeSpec{}))
+	specTypeKeys = jsonFieldNames(reflect.TypeOf(SpecType{}))
+	infoKeys     = jsonFieldNames(reflect.TypeOf(KubeSpec{}.Info))
+)
+
+// findUnknownKeys records unknown keys, and returns a copy of the spec with them removed.
+// `paths` isn't inspected, since KubeSpec keeps it as generic json.
+func (a *SpecAudit) findUnknownKeys(raw map[string]interface{}) map[string]interface{} {
+	out := a.filterKeys(raw, kubeSpecKeys, []string{})
+	if info, ok := out["info"].(map[string]interface{}); ok {
+		out["info"] = a.filterKeys(info, infoKeys, []string{"info"})
+	}
+	if definitions, ok := out["definitions"].(map[string]interface{}); ok {
+		filtered := map[string]interface{}{}
+		for name, definition := range definitions {
+			filtered[name] = a.filterSchema(definition, []string{"definitions", name})
+		}
+		out["definitions"] = filtered
+	}
+	return out
+}
+
+func (a *SpecAudit) filterSchema(obj interface{}, path []string) interface{} {
+	schema, ok := obj.(map[string]interface{})
+	if !ok {
+		return obj
+	}
+	out := a.filterKeys(schema, specTypeKeys, path)
+	for _, key := range []string{"items", "additionalProperties", "not"} {
+		if child, ok := out[key]; ok {
+			out[key] = a.filterSchema(child, slice.Append(path, []string{key}))
+		}
+	}
+	if properties, ok := out["properties"].(map[string]interface{}); ok {
+		filtered := map[string]interface{}{}
+		for name, property := range properties {
+			filtered[name] = a.filterSchema(property, slice.Append(path, []string{"properties", name}))
+		}
+		out["properties"] = filtered
+	}
+	for _, key := range []string{"allOf", "anyOf", "oneOf"} {
+		if schemas, ok := out[key].([]interface{}); ok {
+			filtered := []interface{}{}
+			for i, child := range schemas {
+				filtered = append(filtered, a.filterSchema(child, slice.Append(path, []string{key, fmt.Sprintf("%d", i)})))
+			}
+			out[key] = filtered
+		}
+	}
+	return out
+}
+
+func (a *SpecAudit) filterKeys(obj map[string]interface{}, known *set.Set[string], path []string) map[string]interface{} {
+	out := map[string]interface{}{}
+	for _, key := range slice.Sort(maps.Keys(obj)) {
+		if known.Contains(key) {
+			out[key] = obj[key]
+		} else {
+			a.UnknownKeys[key] = append(a.UnknownKeys[key], slice.Append(path, []string{key}))
+		}
+	}
+	return out
+}
+
+func jsonFieldNames(t reflect.Type) *set.Set[string] {
+	names := set.NewSet[string](nil)
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			names.Add(name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,47 @@
+package swagger
+
+import (
+	"strings"
+
+	"github.com/mattfenwick/collections/pkg/slice"
+	"github.com/mattfenwick/kubectl-schema/pkg/diff"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func RunSpecAuditTests() {
+	Describe("SpecAudit", func() {
+		It("finds unmodeled keys and round trip differences", func() {
+			audit, err := AuditSpec([]byte(specAuditSpec))
+			Expect(err).To(Succeed())
+			Expect(audit.Ok()).To(BeFalse())
+			Expect(audit.UnknownKeys).To(Equal(map[string][][]string{
+				"x-example-extension": {
+					{"definitions", "io.example.v1.Thing", "properties", "count", "x-example-extension"},
+					{"definitions", "io.example.v1.Thing", "x-example-extension"},
+				},
+				"securityDefinitions": {{"securityDefinitions"}},
+			}))
+			Expect(slice.Map(func(e *diff.Node) string { return e.Kind.Short() + " " + strings.Join(e.Path, "/") }, audit.RoundTripDifferences)).
+				To(Equal([]string{"- definitions/io.example.v1.Thing/properties/count/enum"}))
+		})
+	})
+}
+
+var (
+	specAuditSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.30.2"},
+  "paths": {"/api/": {"get": {"x-unchecked": true}}},
+  "securityDefinitions": {},
+  "definitions": {
+    "io.example.v1.Thing": {
+      "type": "object",
+      "x-example-extension": 1,
+      "properties": {
+        "count": {"type": "integer", "exclusiveMinimum": false, "enum": [], "x-example-extension": 2}
+      }
+    }
+  }
+}`
+)
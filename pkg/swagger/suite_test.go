@@ -10,6 +10,24 @@ func TestMatcher(t *testing.T) {
 	gomega.RegisterFailHandler(Fail)
 
 	RunShowResourcesTests()
+	RunSkeletonTests()
+	RunSampleTests()
+	RunJsonSchemaTests()
+	RunCodegenTests()
+	RunTrimTests()
+	RunCustomResourceDefinitionTests()
+	RunCompareCustomResourceDefinitionTests()
+	RunCRDLintTests()
+	RunConstraintsTests()
+	RunSpecAuditTests()
+	RunReadSpecTests()
+	RunConfigTests()
+	RunExplainTests()
+	RunFilterTests()
+	RunPathsTests()
+	RunRBACTests()
+	RunResourceNamesTests()
+	RunCLITests()
 
 	RunSpecs(t, "swagger suite")
 }
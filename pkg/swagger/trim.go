@@ -0,0 +1,152 @@
+package swagger
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mattfenwick/collections/pkg/file"
+	"github.com/mattfenwick/collections/pkg/json"
+	"github.com/mattfenwick/collections/pkg/set"
+	"github.com/mattfenwick/collections/pkg/slice"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/exp/maps"
+)
+
+type TrimArgs struct {
+	KubeVersion string
+	ApiVersions []string
+	Resources   []string
+	Output      string
+	CRDs        []string
+	// ExcludeApiVersions and ExcludeResources are patterns to leave out, even if they're included
+	ExcludeApiVersions []string
+	ExcludeResources   []string
+}
+
+func RunTrim(ctx context.Context, args *TrimArgs) error {
+	filter, err := newGVKFilter(args.ApiVersions, args.ExcludeApiVersions, args.Resources, args.ExcludeResources)
+	if err != nil {
+		return err
+	}
+
+	kubeVersion, err := ParseKubeVersion(args.KubeVersion)
+	if err != nil {
+		return err
+	}
+	spec, err := (&SpecSource{CRDPaths: args.CRDs}).Read(ctx, kubeVersion)
+	if err != nil {
+		return err
+	}
+
+	index, err := spec.ResourceNameIndex()
+	if err != nil {
+		return err
+	}
+	include, err := filter.Resolve(index)
+	if err != nil {
+		return err
+	}
+
+	var roots []string
+	for _, name := range slice.Sort(maps.Keys(spec.Definitions)) {
+		for _, gvk := range spec.Definitions[name].XKubernetesGroupVersionKind {
+			if include(gvk) {
+				roots = append(roots, name)
+				break
+			}
+		}
+	}
+
+	trimmed, err := spec.Trim(roots)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("trimmed spec from %d to %d definitions", len(spec.Definitions), len(trimmed.Definitions))
+
+	// same options as `swagger-debug parse`, to get struct keys sorted
+	bytes, err := json.MarshalWithOptions(trimmed, &json.MarshalOptions{EscapeHTML: true, Indent: true, Sort: true})
+	if err != nil {
+		return errors.Wrapf(err, "unable to marshal trimmed spec")
+	}
+
+	if args.Output == "" {
+		fmt.Printf("%s", bytes)
+		return nil
+	}
+	return errors.Wrapf(file.Write(args.Output, bytes, 0644), "unable to write %s", args.Output)
+}
+
+// Trim builds a spec with just the given definitions, and all definitions transitively reachable
+// from them through `$ref`s, including those in subschemas such as anyOf.  Paths are dropped, since
+// they refer to definitions which may be gone.
+func (s *KubeSpec) Trim(roots []string) (*KubeSpec, error) {
+	for _, name := range roots {
+		if _, err := s.GetDefinition(name); err != nil {
+			return nil, err
+		}
+	}
+	reachable := set.Empty[string]()
+	pending := slice.Append([]string{}, roots)
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if reachable.Contains(name) {
+			continue
+		}
+		reachable.Add(name)
+		refs, err := specTypeRefs(s.Definitions[name])
+		if err != nil {
+			return nil, errors.Wrapf(err, "in definition %s", name)
+		}
+		for _, ref := range refs {
+			if _, ok := s.Definitions[ref]; !ok {
+				return nil, errors.Errorf("unable to find definition for %s, referred to by %s", ref, name)
+			}
+		}
+		pending = append(pending, refs...)
+	}
+
+	trimmed := &KubeSpec{
+		Swagger:     s.Swagger,
+		Definitions: map[string]*SpecType{},
+		Info:        s.Info,
+		Paths:       map[string]interface{}{},
+	}
+	for _, name := range reachable.ToSlice() {
+		trimmed.Definitions[name] = s.Definitions[name]
+	}
+	return trimmed, nil
+}
+
+// specTypeRefs finds the definitions referred to anywhere in a schema, without following them
+func specTypeRefs(specType *SpecType) ([]string, error) {
+	var refs []string
+	if specType.Ref != "" {
+		name, err := ParseRef(specType.Ref)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, name)
+	}
+	var subschemas []*SpecType
+	for _, field := range slice.Sort(maps.Keys(specType.Properties)) {
+		subschemas = append(subschemas, specType.Properties[field])
+	}
+	subschemas = append(subschemas, specType.AllOf...)
+	subschemas = append(subschemas, specType.AnyOf...)
+	subschemas = append(subschemas, specType.OneOf...)
+	for _, subschema := range []*SpecType{specType.Items, specType.AdditionalProperties, specType.Not} {
+		if subschema != nil {
+			subschemas = append(subschemas, subschema)
+		}
+	}
+	for _, subschema := range subschemas {
+		subRefs, err := specTypeRefs(subschema)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, subRefs...)
+	}
+	return refs, nil
+}
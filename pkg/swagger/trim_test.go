@@ -0,0 +1,95 @@
+package swagger
+
+import (
+	"github.com/mattfenwick/collections/pkg/slice"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/exp/maps"
+)
+
+func RunTrimTests() {
+	ref := func(name string) *SpecType { return &SpecType{Ref: "#/definitions/" + name} }
+	spec := &KubeSpec{
+		Swagger: "2.0",
+		Paths:   map[string]interface{}{"/apis/apps/v1/deployments": map[string]interface{}{}},
+		Definitions: map[string]*SpecType{
+			"io.k8s.api.apps.v1.Deployment": {
+				Type:       "object",
+				Properties: map[string]*SpecType{"metadata": ref("ObjectMeta"), "spec": ref("io.k8s.api.apps.v1.DeploymentSpec")},
+			},
+			"io.k8s.api.apps.v1.DeploymentSpec": {
+				Type: "object",
+				Properties: map[string]*SpecType{
+					"template":   {AllOf: []*SpecType{ref("io.k8s.api.core.v1.PodTemplateSpec")}, Description: "Template."},
+					"conditions": {Type: "array", Items: ref("Condition")},
+				},
+			},
+			"io.k8s.api.core.v1.PodTemplateSpec": {
+				Type:       "object",
+				Properties: map[string]*SpecType{"labels": {Type: "object", AdditionalProperties: ref("Label")}},
+			},
+			"ObjectMeta": {Type: "object"},
+			"Condition":  {Type: "object"},
+			"Label":      {Type: "string"},
+			"JSONSchemaProps": {
+				Type:       "object",
+				Properties: map[string]*SpecType{"items": ref("JSONSchemaProps"), "not": ref("JSONSchemaProps")},
+			},
+			"io.k8s.api.core.v1.Service": {
+				Type:       "object",
+				Properties: map[string]*SpecType{"metadata": ref("ObjectMeta"), "spec": ref("ServiceSpec")},
+			},
+			"ServiceSpec": {Type: "object"},
+			"Selector": {
+				Type:  "object",
+				AnyOf: []*SpecType{ref("LabelSelector")},
+				OneOf: []*SpecType{{Properties: map[string]*SpecType{"fields": ref("FieldSelector")}}},
+				Not:   ref("NoSelector"),
+			},
+			"LabelSelector": {Type: "object"},
+			"FieldSelector": {Type: "object"},
+			"NoSelector":    {Type: "object"},
+		},
+	}
+
+	Describe("Trim", func() {
+		It("keeps roots and definitions reachable from them, and drops everything else", func() {
+			trimmed, err := spec.Trim([]string{"io.k8s.api.apps.v1.Deployment", "JSONSchemaProps"})
+			Expect(err).To(Succeed())
+			Expect(slice.Sort(maps.Keys(trimmed.Definitions))).To(Equal([]string{
+				"Condition",
+				"JSONSchemaProps",
+				"Label",
+				"ObjectMeta",
+				"io.k8s.api.apps.v1.Deployment",
+				"io.k8s.api.apps.v1.DeploymentSpec",
+				"io.k8s.api.core.v1.PodTemplateSpec",
+			}))
+			Expect(trimmed.Definitions["io.k8s.api.apps.v1.Deployment"]).To(BeIdenticalTo(spec.Definitions["io.k8s.api.apps.v1.Deployment"]))
+			Expect(trimmed.Swagger).To(Equal("2.0"))
+			Expect(trimmed.Paths).To(BeEmpty())
+		})
+
+		It("handles roots which are reachable from each other", func() {
+			trimmed, err := spec.Trim([]string{"io.k8s.api.core.v1.PodTemplateSpec", "io.k8s.api.apps.v1.DeploymentSpec", "io.k8s.api.core.v1.PodTemplateSpec"})
+			Expect(err).To(Succeed())
+			Expect(slice.Sort(maps.Keys(trimmed.Definitions))).To(Equal([]string{
+				"Condition",
+				"Label",
+				"io.k8s.api.apps.v1.DeploymentSpec",
+				"io.k8s.api.core.v1.PodTemplateSpec",
+			}))
+		})
+
+		It("follows $refs in anyOf, oneOf and not", func() {
+			trimmed, err := spec.Trim([]string{"Selector"})
+			Expect(err).To(Succeed())
+			Expect(slice.Sort(maps.Keys(trimmed.Definitions))).To(Equal([]string{"FieldSelector", "LabelSelector", "NoSelector", "Selector"}))
+		})
+
+		It("fails if a root doesn't exist", func() {
+			_, err := spec.Trim([]string{"io.k8s.api.apps.v1.Deployment", "io.k8s.api.apps.v1.StatefulSet"})
+			Expect(err).To(MatchError(ContainSubstring("io.k8s.api.apps.v1.StatefulSet")))
+		})
+	})
+}
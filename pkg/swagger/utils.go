@@ -2,8 +2,12 @@ package swagger
 
 import (
 	"fmt"
-	"github.com/mattfenwick/collections/pkg/set"
+	"github.com/mattfenwick/collections/pkg/json"
+	"github.com/mattfenwick/collections/pkg/slice"
+	"github.com/mattfenwick/collections/pkg/yaml"
+	"github.com/mattfenwick/kubectl-schema/pkg/utils"
 	"github.com/pkg/errors"
+	"regexp"
 	"strings"
 )
 
@@ -20,45 +24,101 @@ func (g *GVK) GroupVersion() string {
 	return fmt.Sprintf("%s.%s", g.Group, g.Version)
 }
 
+// ApiVersion formats the group and version the way they appear in a manifest's apiVersion field
+func (g *GVK) ApiVersion() string {
+	if g.Group == "" {
+		return g.Version
+	}
+	return fmt.Sprintf("%s/%s", g.Group, g.Version)
+}
+
+// GroupName is the group, or "core" for the core group, whose name is empty
+func (g *GVK) GroupName() string {
+	if g.Group == "" {
+		return "core"
+	}
+	return g.Group
+}
+
+var (
+	gaVersionRegex    = regexp.MustCompile(`^v\d+$`)
+	betaVersionRegex  = regexp.MustCompile(`^v\d+beta\d*$`)
+	alphaVersionRegex = regexp.MustCompile(`^v\d+alpha\d*$`)
+	stabilityChoices  = []string{StabilityAlpha, StabilityBeta, StabilityGA}
+)
+
+const (
+	StabilityAlpha   = "alpha"
+	StabilityBeta    = "beta"
+	StabilityGA      = "ga"
+	StabilityUnknown = "unknown"
+)
+
+// Stability is derived from the version: `v1` is ga, `v1beta1` is beta and `v2alpha1` is alpha.
+// Versions which don't follow the kubernetes convention, as some CRDs' don't, are unknown.
+func (g *GVK) Stability() string {
+	switch {
+	case gaVersionRegex.MatchString(g.Version):
+		return StabilityGA
+	case betaVersionRegex.MatchString(g.Version):
+		return StabilityBeta
+	case alphaVersionRegex.MatchString(g.Version):
+		return StabilityAlpha
+	default:
+		return StabilityUnknown
+	}
+}
+
 func (g *GVK) ToString() string {
 	return fmt.Sprintf("%s.%s", g.GroupVersion(), g.Kind)
 }
 
-func ParseRef(ref string) string {
+func ParseRef(ref string) (string, error) {
 	pieces := strings.Split(ref, "/")
 	if len(pieces) != 3 {
-		panic(errors.Errorf("unable to parse ref: expected 3 pieces, found %d (%s)", len(pieces), ref))
+		return "", errors.Errorf("unable to parse ref: expected 3 pieces, found %d (%s)", len(pieces), ref)
 	}
-	return pieces[2]
+	return pieces[2], nil
 }
 
-func ParseGVK(gvk string) *GVK {
+func ParseGVK(gvk string) (*GVK, error) {
 	split := strings.Split(gvk, ".")
 	if len(split) < 3 {
-		panic(errors.Errorf("invalid gvk string: %s", gvk))
+		return nil, errors.Errorf("invalid gvk string: %s", gvk)
 	}
 	return &GVK{
 		Group:   strings.Join(split[:len(split)-2], "."),
 		Version: split[len(split)-2],
 		Kind:    split[len(split)-1],
-	}
+	}, nil
 }
 
-func shouldAllow(s string, allows *set.Set[string]) bool {
-	return allows.Len() == 0 || allows.Contains(s)
+// validateChoice returns a usage error if a flag's value isn't one of the choices
+func validateChoice(flag string, value string, choices []string) error {
+	if !slice.Any(func(choice string) bool { return choice == value }, choices) {
+		return utils.NewUsageError("invalid --%s '%s'; valid values are: %s", flag, value, strings.Join(choices, ", "))
+	}
+	return nil
 }
 
-func allower(values []string) func(string) bool {
-	valuesSet := set.FromSlice(values)
-	return func(val string) bool {
-		return shouldAllow(val, valuesSet)
+// reparse round trips a generic value, such as part of a spec, through json into a T
+func reparse[T any](obj interface{}) (*T, error) {
+	bytes, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
 	}
+	return json.Parse[T](bytes)
 }
 
-func apiVersionAndResourceAllower(apiVersions []string, resources []string) func(string, string) bool {
-	allowApiVersion := allower(apiVersions)
-	allowResource := allower(resources)
-	return func(apiVersion string, resource string) bool {
-		return allowApiVersion(apiVersion) && allowResource(resource)
+// marshalYaml round trips through json first, so that yaml uses the same field names as json
+func marshalYaml(obj interface{}) (string, error) {
+	remarshaled, err := json.Remarshal(obj)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to marshal to json")
+	}
+	out, err := yaml.MarshalString(remarshaled)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to marshal to yaml")
 	}
+	return strings.TrimSpace(out), nil
 }
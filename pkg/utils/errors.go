@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ExitCodeDifferences = 1
+	ExitCodeUsage       = 2
+	ExitCodeNetwork     = 3
+	ExitCodeError       = 4
+)
+
+var (
+	// ErrDifferencesFound is returned by commands which check or compare something, when they find
+	// a difference or a problem.  Like `diff`, they exit with status 1.
+	ErrDifferencesFound = errors.New("differences found")
+)
+
+// UsageError means the command was invoked incorrectly: bad flag values, missing arguments, and so on
+type UsageError struct {
+	Message string
+}
+
+func (u *UsageError) Error() string {
+	return u.Message
+}
+
+func NewUsageError(format string, args ...interface{}) error {
+	return &UsageError{Message: fmt.Sprintf(format, args...)}
+}
+
+// NetworkError means a spec couldn't be downloaded
+type NetworkError struct {
+	Err error
+}
+
+func (n *NetworkError) Error() string {
+	return n.Err.Error()
+}
+
+func (n *NetworkError) Unwrap() error {
+	return n.Err
+}
+
+// ExitCode picks the process exit status for an error returned by a command
+func ExitCode(err error) int {
+	var usageError *UsageError
+	var networkError *NetworkError
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, ErrDifferencesFound):
+		return ExitCodeDifferences
+	case errors.As(err, &usageError):
+		return ExitCodeUsage
+	case errors.As(err, &networkError):
+		return ExitCodeNetwork
+	default:
+		return ExitCodeError
+	}
+}
@@ -1,33 +1,155 @@
 package utils
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
 	"github.com/mattfenwick/collections/pkg/file"
 	"github.com/pkg/errors"
-	"io/ioutil"
-	"net/http"
 )
 
-func GetFileFromURL(url string, path string) error {
-	bytes, err := GetURL(url)
+// HTTPOptions configures downloads.  Proxies are picked up from the standard HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY environment variables.
+type HTTPOptions struct {
+	// Timeout applies to each attempt; 0 means no timeout
+	Timeout time.Duration
+	// Retries is how many times to retry after network errors, 429s and 5xxs
+	Retries int
+	// RetryBackoff is the wait before the first retry; it doubles after each retry
+	RetryBackoff time.Duration
+	// CABundle is a path to PEM certificates to trust, in addition to the system's
+	CABundle string
+	// BearerToken is sent in an `Authorization` header, if set
+	BearerToken string
+	// Progress is called as the body is read; total is -1 if the server didn't send a length
+	Progress func(url string, read int64, total int64)
+}
+
+func DefaultHTTPOptions() *HTTPOptions {
+	return &HTTPOptions{
+		Timeout:      2 * time.Minute,
+		Retries:      3,
+		RetryBackoff: time.Second,
+	}
+}
+
+type HTTPClient struct {
+	Options *HTTPOptions
+	client  *http.Client
+}
+
+// DefaultHTTPClient uses DefaultHTTPOptions, which don't need any setup that could fail
+func DefaultHTTPClient() *HTTPClient {
+	options := DefaultHTTPOptions()
+	return &HTTPClient{
+		Options: options,
+		client:  &http.Client{Transport: http.DefaultTransport, Timeout: options.Timeout},
+	}
+}
+
+func NewHTTPClient(options *HTTPOptions) (*HTTPClient, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if options.CABundle != "" {
+		pem, err := file.Read(options.CABundle)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read ca bundle %s", options.CABundle)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificates found in ca bundle %s", options.CABundle)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return &HTTPClient{
+		Options: options,
+		client:  &http.Client{Transport: transport, Timeout: options.Timeout},
+	}, nil
+}
+
+func (c *HTTPClient) GetFile(ctx context.Context, url string, path string) error {
+	bytes, err := c.Get(ctx, url)
 	if err != nil {
 		return err
 	}
 	return file.Write(path, bytes, 0777)
 }
 
-func GetURL(url string) ([]byte, error) {
-	response, err := http.Get(url)
+// Get retries failures which might be temporary: network errors, 429s and 5xxs
+func (c *HTTPClient) Get(ctx context.Context, url string) ([]byte, error) {
+	backoff := c.Options.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		bytes, retryable, err := c.get(ctx, url)
+		if err == nil || !retryable || attempt >= c.Options.Retries {
+			return bytes, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, &NetworkError{Err: errors.Wrapf(ctx.Err(), "gave up on GET %s after %d attempts (last error: %s)", url, attempt+1, err.Error())}
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *HTTPClient) get(ctx context.Context, url string) ([]byte, bool, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "unable to create GET request to %s", url)
+	}
+	if c.Options.BearerToken != "" {
+		request.Header.Set("Authorization", "Bearer "+c.Options.BearerToken)
+	}
+	response, err := c.client.Do(request)
 	if err != nil {
-		return nil, errors.Wrapf(err, "unable to GET %s", url)
+		return nil, ctx.Err() == nil, &NetworkError{Err: errors.Wrapf(err, "unable to GET %s", url)}
 	}
 	defer response.Body.Close()
 	if response.StatusCode < 200 || response.StatusCode > 299 {
-		return nil, errors.Errorf("GET request to %s failed with status code %d", url, response.StatusCode)
+		retryable := response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500
+		return nil, retryable, &NetworkError{Err: errors.Errorf("GET request to %s failed with status code %d", url, response.StatusCode)}
 	}
-	bytes, err := ioutil.ReadAll(response.Body)
+	var body io.Reader = response.Body
+	if c.Options.Progress != nil {
+		body = &progressReader{reader: response.Body, url: url, total: response.ContentLength, progress: c.Options.Progress}
+	}
+	bytes, err := io.ReadAll(body)
 	if err != nil {
-		return nil, errors.Wrapf(err, "unable to read body from GET to %s", url)
+		return nil, ctx.Err() == nil, &NetworkError{Err: errors.Wrapf(err, "unable to read body from GET to %s", url)}
+	}
+
+	return bytes, false, nil
+}
+
+type progressReader struct {
+	reader   io.Reader
+	url      string
+	read     int64
+	total    int64
+	progress func(url string, read int64, total int64)
+}
+
+func (p *progressReader) Read(buffer []byte) (int, error) {
+	n, err := p.reader.Read(buffer)
+	p.read += int64(n)
+	if n > 0 || err == io.EOF {
+		p.progress(p.url, p.read, p.total)
 	}
+	return n, err
+}
 
-	return bytes, nil
+// ReadBearerTokenFile reads a token from a file, ignoring surrounding whitespace
+func ReadBearerTokenFile(path string) (string, error) {
+	bytes, err := file.Read(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to read bearer token file %s", path)
+	}
+	return strings.TrimSpace(string(bytes)), nil
 }
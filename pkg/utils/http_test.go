@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"time"
+
+	"github.com/mattfenwick/collections/pkg/file"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func RunHTTPTests() {
+	Describe("HTTPClient", func() {
+		newClient := func(options *HTTPOptions) *HTTPClient {
+			client, err := NewHTTPClient(options)
+			Expect(err).To(Succeed())
+			return client
+		}
+
+		It("retries server errors, and sends the bearer token", func() {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				Expect(r.Header.Get("Authorization")).To(Equal("Bearer abc"))
+				if attempts < 3 {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				_, _ = w.Write([]byte("the spec"))
+			}))
+			defer server.Close()
+
+			var progress [][]int64
+			client := newClient(&HTTPOptions{
+				Retries:      3,
+				RetryBackoff: time.Millisecond,
+				BearerToken:  "abc",
+				Progress: func(url string, read int64, total int64) {
+					progress = append(progress, []int64{read, total})
+				},
+			})
+			bytes, err := client.Get(context.Background(), server.URL)
+			Expect(err).To(Succeed())
+			Expect(string(bytes)).To(Equal("the spec"))
+			Expect(attempts).To(Equal(3))
+			Expect(progress[len(progress)-1]).To(Equal([]int64{8, 8}))
+		})
+
+		It("doesn't retry client errors", func() {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				w.WriteHeader(http.StatusNotFound)
+			}))
+			defer server.Close()
+
+			_, err := newClient(&HTTPOptions{Retries: 3, RetryBackoff: time.Millisecond}).Get(context.Background(), server.URL)
+			Expect(err).To(HaveOccurred())
+			Expect(ExitCode(err)).To(Equal(ExitCodeNetwork))
+			Expect(attempts).To(Equal(1))
+		})
+
+		It("times out", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(200 * time.Millisecond)
+			}))
+			defer server.Close()
+
+			_, err := newClient(&HTTPOptions{Timeout: 20 * time.Millisecond}).Get(context.Background(), server.URL)
+			Expect(err).To(HaveOccurred())
+			Expect(ExitCode(err)).To(Equal(ExitCodeNetwork))
+		})
+
+		It("trusts certificates from a ca bundle", func() {
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("ok"))
+			}))
+			defer server.Close()
+
+			_, err := newClient(&HTTPOptions{}).Get(context.Background(), server.URL)
+			Expect(err).To(HaveOccurred())
+
+			caBundle := path.Join(GinkgoT().TempDir(), "ca.pem")
+			Expect(file.Write(caBundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644)).To(Succeed())
+			bytes, err := newClient(&HTTPOptions{CABundle: caBundle}).Get(context.Background(), server.URL)
+			Expect(err).To(Succeed())
+			Expect(string(bytes)).To(Equal("ok"))
+		})
+	})
+}
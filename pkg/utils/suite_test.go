@@ -0,0 +1,15 @@
+package utils
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	"testing"
+)
+
+func TestUtils(t *testing.T) {
+	gomega.RegisterFailHandler(Fail)
+
+	RunHTTPTests()
+
+	RunSpecs(t, "utils suite")
+}